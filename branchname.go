@@ -122,3 +122,13 @@ func validateBranchName(branchName string) error {
 
 	return nil
 }
+
+// defaultBranchName returns the branch name proposed for syncing sourcePath,
+// e.g. "chore/filesync-config" for "deploy/config.yaml"
+func defaultBranchName(sourcePath string) string {
+	sourceName := "filesync"
+	if sourcePath != "" {
+		sourceName = normalizeBranchName(sourcePath)
+	}
+	return fmt.Sprintf("chore/filesync-%s", sourceName)
+}
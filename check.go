@@ -0,0 +1,430 @@
+package filemirror
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// Exit codes of the check command
+const (
+	checkExitInSync = 0 // every target matches its source
+	checkExitDrift  = 1 // at least one target drifted or is missing
+	checkExitError  = 2 // invalid arguments, or a source or target could not be read
+)
+
+// driftStatus is the state of a single replica compared to its source
+type driftStatus int
+
+const (
+	statusInSync driftStatus = iota
+	statusDrifted
+	statusMissing
+	statusUnreadable
+)
+
+func (s driftStatus) String() string {
+	switch s {
+	case statusInSync:
+		return "in-sync"
+	case statusDrifted:
+		return "drifted"
+	case statusMissing:
+		return "missing"
+	default:
+		return "unreadable"
+	}
+}
+
+// checkOptions holds the parsed arguments for the check command
+type checkOptions struct {
+	WorkDir       string
+	Source        string
+	Targets       []string
+	ManifestPath  string
+	Groups        []string
+	ShowDiff      bool // print the diff of drifted targets
+	UseHash       bool // compare SHA-256 hashes and print them
+	Context       int  // context lines around changes in printed diffs
+	ShowHelp      bool
+	Section       string   // managed section to compare instead of the whole file
+	InsertMissing bool     // report targets without the section as drifted rather than unreadable
+	Keys          []string // key paths to compare instead of the whole file
+	Format        string   // format of the keyed file: json, yaml or toml
+	Merge         bool     // compare with the source merged into the target's local changes
+
+	Template *sourceTemplate // renders the source per target, set for --section, --key, --merge and templated, section, keyed or merged manifest groups
+}
+
+// checkResult is the outcome of comparing one target with its source
+type checkResult struct {
+	Path   string
+	Status driftStatus
+	Hash   string   // SHA-256 of the target, when compared by hash
+	Diff   []string // unifiedDiff output, for drifted targets
+	Err    error    // set for unreadable targets, and for drifted targets whose merge conflicts
+}
+
+// parseCheckArgs parses the arguments following "fmr check"
+// Returns an error if arguments are invalid
+func parseCheckArgs(args []string) (checkOptions, error) {
+	opts := checkOptions{Context: defaultDiffContext}
+
+	value := func(i int, flag, what string) (string, error) {
+		if i+1 >= len(args) {
+			return "", fmt.Errorf("%s requires %s", flag, what)
+		}
+		return args[i+1], nil
+	}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "-h", "--help":
+			opts.ShowHelp = true
+			return opts, nil
+		case "-p", "--path":
+			v, err := value(i, "--path", "a directory argument")
+			if err != nil {
+				return opts, err
+			}
+			opts.WorkDir = v
+			i++
+		case "-s", "--source":
+			v, err := value(i, "--source", "a file argument")
+			if err != nil {
+				return opts, err
+			}
+			opts.Source = v
+			i++
+		case "-t", "--target":
+			v, err := value(i, "--target", "a file or glob argument")
+			if err != nil {
+				return opts, err
+			}
+			opts.Targets = append(opts.Targets, v)
+			i++
+		case "--manifest":
+			v, err := value(i, "--manifest", "a file argument")
+			if err != nil {
+				return opts, err
+			}
+			opts.ManifestPath = v
+			i++
+		case "-g", "--group":
+			v, err := value(i, "--group", "a group name")
+			if err != nil {
+				return opts, err
+			}
+			opts.Groups = append(opts.Groups, v)
+			i++
+		case "-d", "--diff":
+			opts.ShowDiff = true
+		case "--hash":
+			opts.UseHash = true
+		case "-U", "--context":
+			v, err := value(i, "--context", "a number of lines")
+			if err != nil {
+				return opts, err
+			}
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return opts, fmt.Errorf("--context requires a non-negative number, got %q", v)
+			}
+			opts.Context = n
+			opts.ShowDiff = true
+			i++
+		case "--section":
+			v, err := value(i, "--section", "a section name")
+			if err != nil {
+				return opts, err
+			}
+			if err := validateSectionName(v); err != nil {
+				return opts, err
+			}
+			opts.Section = v
+			i++
+		case "--insert-missing":
+			opts.InsertMissing = true
+		case "-k", "--key":
+			v, err := value(i, "--key", "a key path")
+			if err != nil {
+				return opts, err
+			}
+			opts.Keys = append(opts.Keys, v)
+			i++
+		case "--format":
+			v, err := value(i, "--format", "json, yaml or toml")
+			if err != nil {
+				return opts, err
+			}
+			opts.Format = v
+			i++
+		case "--merge":
+			opts.Merge = true
+		default:
+			return opts, fmt.Errorf("unknown argument %q", arg)
+		}
+	}
+
+	if opts.ManifestPath != "" && (opts.Source != "" || len(opts.Targets) > 0) {
+		return opts, errors.New("--manifest cannot be combined with --source/--target")
+	}
+	if len(opts.Groups) > 0 && opts.Source != "" {
+		return opts, errors.New("--group requires a manifest")
+	}
+	if opts.Source == "" && len(opts.Targets) > 0 {
+		return opts, errors.New("--source is required")
+	}
+	if opts.Source != "" && len(opts.Targets) == 0 {
+		return opts, errors.New("at least one --target is required")
+	}
+	if opts.Section != "" && opts.Source == "" {
+		return opts, errors.New("--section requires --source (manifest groups set section instead)")
+	}
+	if opts.InsertMissing && opts.Section == "" {
+		return opts, errors.New("--insert-missing requires --section")
+	}
+	if err := validateKeyFlags(opts.Source, opts.Keys, opts.Format, opts.Section); err != nil {
+		return opts, err
+	}
+
+	return opts, nil
+}
+
+// hashBytes returns the hex-encoded SHA-256 of data
+func hashBytes(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// checkTarget compares a target file with the source content. It never writes anything.
+func checkTarget(source []byte, target string, opts checkOptions) checkResult {
+	result := checkResult{Path: target}
+
+	content, err := os.ReadFile(target)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		result.Status = statusMissing
+		return result
+	case err != nil:
+		result.Status = statusUnreadable
+		result.Err = err
+		return result
+	}
+
+	inSync := bytes.Equal(source, content)
+	if opts.UseHash {
+		result.Hash = hashBytes(content)
+		inSync = result.Hash == hashBytes(source)
+	}
+
+	if inSync {
+		result.Status = statusInSync
+		return result
+	}
+
+	result.Status = statusDrifted
+	result.Diff = unifiedDiff(string(source), string(content), opts.Context)
+	return result
+}
+
+// runCheck runs the check command and returns an exit code
+func runCheck(args []string, stdout, stderr io.Writer) int {
+	opts, err := parseCheckArgs(args)
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "Error: %v\n", err)                   //nolint:errcheck // Error writing to stderr is not actionable
+		_, _ = fmt.Fprintln(stderr, "Run 'fmr check --help' for usage.") //nolint:errcheck // Error writing to stderr is not actionable
+		return checkExitError
+	}
+
+	if opts.ShowHelp {
+		printCheckHelp(stdout)
+		return checkExitInSync
+	}
+
+	workDir := opts.WorkDir
+	if workDir == "" {
+		workDir = "."
+	}
+	workDir, err = filepath.Abs(workDir)
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "Error: invalid path %q: %v\n", opts.WorkDir, err) //nolint:errcheck // Error writing to stderr is not actionable
+		return checkExitError
+	}
+
+	if opts.Source == "" {
+		manifest, plans, err := planManifest(workDir, opts.ManifestPath, opts.Groups)
+		if err != nil {
+			_, _ = fmt.Fprintf(stderr, "Error: %v\n", err) //nolint:errcheck // Error writing to stderr is not actionable
+			return checkExitError
+		}
+
+		exitCode := checkExitInSync
+		for i, plan := range plans {
+			if i > 0 {
+				_, _ = fmt.Fprintln(stdout) //nolint:errcheck // Error writing to stdout is not actionable
+			}
+			_, _ = fmt.Fprintf(stdout, "== %s ==\n", plan.Group.Name) //nolint:errcheck // Error writing to stdout is not actionable
+
+			code := checkExitError
+			if plan.Err != nil {
+				_, _ = fmt.Fprintf(stdout, "✗ %v\n", plan.Err) //nolint:errcheck // Error writing to stdout is not actionable
+			} else {
+				groupOpts := opts
+				groupOpts.Template = plan.Template.withMerge(opts.Merge, false).withLocalBase()
+				code = executeCheck(manifest.Dir, plan.Source, plan.Targets, groupOpts, stdout)
+			}
+			exitCode = maxInt(exitCode, code)
+		}
+		return exitCode
+	}
+
+	source := opts.Source
+	if !filepath.IsAbs(source) {
+		source = filepath.Join(workDir, source)
+	}
+
+	targets, err := resolveTargets(workDir, source, opts.Targets)
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "Error: %v\n", err) //nolint:errcheck // Error writing to stderr is not actionable
+		return checkExitError
+	}
+	if opts.Section != "" {
+		opts.Template = newSectionTemplate(source, opts.Section, opts.InsertMissing)
+	}
+	if len(opts.Keys) > 0 {
+		opts.Template = newKeysTemplate(source, opts.Keys, opts.Format)
+	}
+	opts.Template = opts.Template.withMerge(opts.Merge, false).withLocalBase()
+
+	return executeCheck(workDir, source, targets, opts, stdout)
+}
+
+// executeCheck compares every target with source and prints a per-target status.
+// Returns the exit code for the worst status found.
+func executeCheck(workDir, source string, targets []string, opts checkOptions, stdout io.Writer) int {
+	w := func(format string, a ...any) {
+		_, _ = fmt.Fprintf(stdout, format, a...) //nolint:errcheck // Error writing to stdout is not actionable
+	}
+
+	content, err := os.ReadFile(source)
+	if err != nil {
+		w("✗ cannot read source %s: %v\n", relativeTo(workDir, source), err)
+		return checkExitError
+	}
+
+	w("Source: %s\n", relativeTo(workDir, source))
+	if opts.UseHash {
+		w("        sha256 %s\n", hashBytes(content))
+	}
+	w("\n")
+
+	counts := make(map[driftStatus]int)
+	for _, target := range targets {
+		// Templated sources are compared as rendered for the target, merged sources as merged
+		// with its local changes; a merge with conflicts always differs from the target
+		var result checkResult
+		var conflict *mergeConflictError
+		expected, err := opts.Template.render(content, target)
+		switch {
+		case errors.As(err, &conflict) || errors.Is(err, errNoMergeBase):
+			result = checkTarget(expected, target, opts)
+			result.Err = err
+		case err != nil:
+			result = checkResult{Path: target, Status: statusUnreadable, Err: err}
+		default:
+			result = checkTarget(expected, target, opts)
+		}
+		counts[result.Status]++
+
+		line := fmt.Sprintf("%-10s  %s", result.Status, relativeTo(workDir, target))
+		switch result.Status {
+		case statusDrifted:
+			removed, added := countChanges(result.Diff)
+			line += fmt.Sprintf(" (-%d +%d lines)", removed, added)
+			if result.Err != nil {
+				line += fmt.Sprintf(": %v", result.Err)
+			}
+		case statusUnreadable:
+			line += fmt.Sprintf(": %v", result.Err)
+		}
+		if result.Hash != "" {
+			line += fmt.Sprintf("  sha256 %s", result.Hash)
+		}
+		w("%s\n", line)
+
+		if opts.ShowDiff && result.Status == statusDrifted {
+			for _, diffLine := range result.Diff {
+				w("    %s\n", diffLine)
+			}
+		}
+	}
+
+	w("\n%d in-sync, %d drifted, %d missing, %d unreadable\n",
+		counts[statusInSync], counts[statusDrifted], counts[statusMissing], counts[statusUnreadable])
+
+	switch {
+	case counts[statusUnreadable] > 0:
+		return checkExitError
+	case counts[statusDrifted] > 0 || counts[statusMissing] > 0:
+		return checkExitDrift
+	default:
+		return checkExitInSync
+	}
+}
+
+// printCheckHelp displays the help message for the check command
+func printCheckHelp(w io.Writer) {
+	help := `fmr check - Report replicas that have drifted from their source (read-only)
+
+USAGE:
+    fmr check --source FILE --target FILE|GLOB [--target ...] [OPTIONS]
+    fmr check [--manifest FILE] [--group NAME ...] [OPTIONS]
+
+DESCRIPTION:
+    Compares every target with its canonical source and prints one of
+    in-sync, drifted, missing or unreadable per target. Never writes files
+    and never runs git, so it is safe to use in CI.
+
+OPTIONS:
+    -s, --source FILE      Canonical file to compare against
+    -t, --target PATTERN   Target file or glob pattern (repeatable)
+    -p, --path PATH        Resolve relative paths from PATH (default: current directory)
+        --manifest FILE    Check the mirror groups of a manifest
+                           Default without --source: .fmr.yaml in PATH
+    -g, --group NAME       Only check the named manifest group (repeatable)
+    -d, --diff             Print the diff of drifted targets
+    -U, --context N        Context lines around changes in the diff (default: 3, implies --diff)
+        --hash             Compare by SHA-256 and print the hashes
+        --section NAME     Only compare the region between the "BEGIN fmr:NAME" and
+                           "END fmr:NAME" marker lines
+        --insert-missing   Report targets without the section as drifted
+                           (default: unreadable)
+    -k, --key PATH         Only compare the value at a dotted key path of a JSON, YAML
+                           or TOML file (repeatable)
+        --format NAME      json, yaml or toml (default: from the source's extension)
+        --merge            Compare with the source merged into each target's local
+                           changes since the last merge sync on this machine, so
+                           that kept local changes are not drift (see 'fmr sync
+                           --merge'). Unlike sync, never reads the lockfile's source
+                           commit from git
+    -h, --help             Show this help message
+
+EXIT STATUS:
+    0  Every target is in sync
+    1  At least one target drifted or is missing
+    2  Invalid arguments, or a source or target could not be read
+
+EXAMPLES:
+    fmr check -s canonical/.golangci.yml -t 'services/*/.golangci.yml'
+    fmr check --manifest .fmr.yaml --diff
+`
+	_, _ = fmt.Fprint(w, help) //nolint:errcheck // Error writing to writer is not actionable
+}
@@ -0,0 +1,245 @@
+package filemirror
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestParseCheckArgs(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        []string
+		want        checkOptions
+		errContains string
+	}{
+		{
+			name: "source, targets and flags",
+			args: []string{"-s", "a", "-t", "b", "-t", "c/*", "--diff", "--hash"},
+			want: checkOptions{Source: "a", Targets: []string{"b", "c/*"}, ShowDiff: true, UseHash: true},
+		},
+		{
+			name: "manifest and group",
+			args: []string{"--manifest", "m.yaml", "-g", "lint"},
+			want: checkOptions{ManifestPath: "m.yaml", Groups: []string{"lint"}},
+		},
+		{
+			name:        "target without source",
+			args:        []string{"-t", "b"},
+			errContains: "--source is required",
+		},
+		{
+			name:        "insert missing without section",
+			args:        []string{"-s", "a", "-t", "b", "--insert-missing"},
+			errContains: "--insert-missing requires --section",
+		},
+		{
+			name:        "git flags are not accepted",
+			args:        []string{"-s", "a", "-t", "b", "--push"},
+			errContains: "unknown argument",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseCheckArgs(tt.args)
+			if tt.errContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+					t.Errorf("Expected error containing %q, got %v", tt.errContains, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got.Source != tt.want.Source || got.ManifestPath != tt.want.ManifestPath ||
+				got.ShowDiff != tt.want.ShowDiff || got.UseHash != tt.want.UseHash ||
+				strings.Join(got.Targets, ",") != strings.Join(tt.want.Targets, ",") ||
+				strings.Join(got.Groups, ",") != strings.Join(tt.want.Groups, ",") {
+				t.Errorf("parseCheckArgs() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckTarget(t *testing.T) {
+	tmpDir := t.TempDir()
+	source := []byte("a\nb\nc")
+
+	same := filepath.Join(tmpDir, "same.txt")
+	drifted := filepath.Join(tmpDir, "drifted.txt")
+	if err := os.WriteFile(same, source, 0o644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if err := os.WriteFile(drifted, []byte("a\nB\nc"), 0o644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		target string
+		opts   checkOptions
+		want   driftStatus
+	}{
+		{"in sync by bytes", same, checkOptions{}, statusInSync},
+		{"in sync by hash", same, checkOptions{UseHash: true}, statusInSync},
+		{"drifted", drifted, checkOptions{}, statusDrifted},
+		{"drifted by hash", drifted, checkOptions{UseHash: true}, statusDrifted},
+		{"missing", filepath.Join(tmpDir, "nope.txt"), checkOptions{}, statusMissing},
+		{"directory is unreadable", tmpDir, checkOptions{}, statusUnreadable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := checkTarget(source, tt.target, tt.opts)
+			if result.Status != tt.want {
+				t.Errorf("Status = %v, want %v", result.Status, tt.want)
+			}
+			if tt.opts.UseHash && result.Status != statusMissing && result.Hash == "" {
+				t.Error("Expected hash to be set when comparing by hash")
+			}
+			if result.Status == statusDrifted && len(result.Diff) == 0 {
+				t.Error("Expected diff for drifted target")
+			}
+		})
+	}
+}
+
+func TestRunCheck(t *testing.T) {
+	tmpDir := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	write("source.txt", "key: true\n")
+	write("a.txt", "key: true\n")
+	write("b.txt", "key: true\n")
+
+	t.Run("all in sync", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		code := runCheck([]string{"-p", tmpDir, "-s", "source.txt", "-t", "?.txt"}, &stdout, &stderr)
+		if code != checkExitInSync {
+			t.Errorf("Exit code = %d, want %d\n%s%s", code, checkExitInSync, stdout.String(), stderr.String())
+		}
+		if !strings.Contains(stdout.String(), "2 in-sync, 0 drifted") {
+			t.Errorf("Unexpected report:\n%s", stdout.String())
+		}
+	})
+
+	t.Run("drifted and missing", func(t *testing.T) {
+		write("b.txt", "key: false\n")
+		var stdout, stderr bytes.Buffer
+		code := runCheck([]string{"-p", tmpDir, "-s", "source.txt", "-t", "a.txt", "-t", "b.txt", "-t", "c.txt", "--diff"}, &stdout, &stderr)
+		if code != checkExitDrift {
+			t.Errorf("Exit code = %d, want %d", code, checkExitDrift)
+		}
+		out := stdout.String()
+		for _, want := range []string{"drifted     b.txt", "missing     c.txt", "+key: false"} {
+			if !strings.Contains(out, want) {
+				t.Errorf("Expected report to contain %q, got:\n%s", want, out)
+			}
+		}
+
+		content, _ := os.ReadFile(filepath.Join(tmpDir, "b.txt"))
+		if string(content) != "key: false\n" {
+			t.Error("check must never write files")
+		}
+	})
+
+	t.Run("unreadable target", func(t *testing.T) {
+		if runtime.GOOS == "windows" || os.Getuid() == 0 {
+			t.Skip("Permission test not reliable on Windows or as root")
+		}
+		write("locked.txt", "x")
+		locked := filepath.Join(tmpDir, "locked.txt")
+		if err := os.Chmod(locked, 0o000); err != nil {
+			t.Fatalf("Failed to chmod: %v", err)
+		}
+		defer os.Chmod(locked, 0o644)
+
+		var stdout, stderr bytes.Buffer
+		code := runCheck([]string{"-p", tmpDir, "-s", "source.txt", "-t", "a.txt", "-t", "locked.txt"}, &stdout, &stderr)
+		if code != checkExitError {
+			t.Errorf("Exit code = %d, want %d", code, checkExitError)
+		}
+		if !strings.Contains(stdout.String(), "unreadable  locked.txt") {
+			t.Errorf("Unexpected report:\n%s", stdout.String())
+		}
+	})
+
+	t.Run("invalid arguments", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		if code := RunWithArgs([]string{"check", "--bogus"}, &stdout, &stderr); code != checkExitError {
+			t.Errorf("Exit code = %d, want %d", code, checkExitError)
+		}
+	})
+}
+
+func TestRunCheckWithManifest(t *testing.T) {
+	tmpDir := writeManifestTree(t, `groups:
+  - name: config
+    source: canonical/config.yaml
+    targets: ["services/*/config.yaml"]
+    exclude: ["services/legacy/*"]
+`)
+
+	var stdout, stderr bytes.Buffer
+	if code := runCheck([]string{"-p", tmpDir}, &stdout, &stderr); code != checkExitDrift {
+		t.Errorf("Exit code = %d, want %d\n%s%s", code, checkExitDrift, stdout.String(), stderr.String())
+	}
+
+	if code := runSync([]string{"-p", tmpDir}, &stdout, &stderr); code != 0 {
+		t.Fatalf("sync failed: %s", stdout.String())
+	}
+
+	stdout.Reset()
+	if code := runCheck([]string{"-p", tmpDir, "--hash"}, &stdout, &stderr); code != checkExitInSync {
+		t.Errorf("Exit code = %d, want %d after sync\n%s", code, checkExitInSync, stdout.String())
+	}
+}
+
+func TestRunCheckMergeNeverRunsGit(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Fake git script requires a POSIX shell")
+	}
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	repo := createTestGitRepo(t)
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(repo, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	write("source.conf", "level = info\ncolor = auto\nport = 8080\n")
+	write("target.conf", "level = debug\ncolor = auto\nport = 80\n")
+	write(lockFileName, `{"files": [{"path": "target.conf", "source_repo": ".", "source_path": "source.conf", "source_commit": "HEAD", "source_hash": "x"}]}`)
+	if err := recordBase(filepath.Join(repo, "target.conf"), []byte("level = info\ncolor = auto\nport = 80\n")); err != nil {
+		t.Fatalf("recordBase() error = %v", err)
+	}
+
+	// A git on PATH that leaves a marker when run
+	bin := t.TempDir()
+	marker := filepath.Join(t.TempDir(), "git-ran")
+	script := "#!/bin/sh\n: > '" + marker + "'\nexit 1\n"
+	if err := os.WriteFile(filepath.Join(bin, "git"), []byte(script), 0o755); err != nil {
+		t.Fatalf("Failed to write fake git: %v", err)
+	}
+	t.Setenv("PATH", bin)
+
+	var stdout, stderr bytes.Buffer
+	code := runCheck([]string{"-p", repo, "-s", "source.conf", "-t", "target.conf", "--merge"}, &stdout, &stderr)
+	if code != checkExitDrift {
+		t.Errorf("Exit code = %d, want %d\n%s%s", code, checkExitDrift, stdout.String(), stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "(-1 +1 lines)") {
+		t.Errorf("Expected only the port to drift after merging, got:\n%s", stdout.String())
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Error("check --merge must never run git")
+	}
+}
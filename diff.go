@@ -0,0 +1,479 @@
+package filemirror
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// defaultDiffContext is the number of unchanged lines shown around each change
+const defaultDiffContext = 3
+
+// maxDiffEdits caps the edit distance searched by myersDiff. Texts that differ by more
+// edits are diffed as one replacement, which keeps time and memory bounded for unrelated files.
+const maxDiffEdits = 1000
+
+type diffKind int
+
+const (
+	diffEqual  diffKind = iota
+	diffDelete          // only in the old (source) text
+	diffInsert          // only in the new (target) text
+)
+
+// diffOp is one step of an edit script turning old lines into new lines
+type diffOp struct {
+	Kind     diffKind
+	Text     string
+	OldIndex int // 0-based position in the old lines where the op applies
+	NewIndex int // 0-based position in the new lines where the op applies
+}
+
+// prefix returns the unified diff prefix for the op
+func (op diffOp) prefix() string {
+	switch op.Kind {
+	case diffDelete:
+		return "-"
+	case diffInsert:
+		return "+"
+	default:
+		return " "
+	}
+}
+
+// diffHunk is a group of changes with surrounding context lines
+type diffHunk struct {
+	OldStart, OldLines int
+	NewStart, NewLines int
+	Ops                []diffOp
+}
+
+// header returns the unified diff hunk header, e.g. "@@ -1,4 +1,5 @@"
+func (h diffHunk) header() string {
+	return fmt.Sprintf("@@ -%d,%d +%d,%d @@", h.OldStart, h.OldLines, h.NewStart, h.NewLines)
+}
+
+// computeDiff returns a minimal edit script turning a into b, using Myers' O(ND) algorithm.
+// Deletions are ordered before insertions at the same position.
+func computeDiff(a, b []string) []diffOp {
+	// Common prefix and suffix are always unchanged; trimming them keeps the search small
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	ops := make([]diffOp, 0, len(a)+len(b)-prefix-suffix)
+	for i := 0; i < prefix; i++ {
+		ops = append(ops, diffOp{Kind: diffEqual, Text: a[i]})
+	}
+	ops = append(ops, myersDiff(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for i := len(a) - suffix; i < len(a); i++ {
+		ops = append(ops, diffOp{Kind: diffEqual, Text: a[i]})
+	}
+
+	// Number the ops by their position in a and b
+	oldIndex, newIndex := 0, 0
+	for i := range ops {
+		ops[i].OldIndex = oldIndex
+		ops[i].NewIndex = newIndex
+		if ops[i].Kind != diffInsert {
+			oldIndex++
+		}
+		if ops[i].Kind != diffDelete {
+			newIndex++
+		}
+	}
+
+	return ops
+}
+
+// myersDiff finds the shortest edit script between a and b, or replaces all of a with
+// all of b if they differ by more than maxDiffEdits edits
+func myersDiff(a, b []string) []diffOp {
+	n, m := len(a), len(b)
+	if n == 0 && m == 0 {
+		return nil
+	}
+
+	maxD := minInt(n+m, maxDiffEdits)
+	offset := maxD + 1
+	v := make([]int, 2*maxD+3)
+	var trace [][]int
+
+	// Walk the edit graph one edit distance at a time, recording the furthest
+	// reaching x for the diagonals -d..d so the path can be reconstructed
+	for d := 0; d <= maxD; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d:offset+d+1]...))
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1] // move down (insertion)
+			} else {
+				x = v[offset+k-1] + 1 // move right (deletion)
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+
+			if x >= n && y >= m {
+				return backtrackDiff(trace, a, b)
+			}
+		}
+	}
+
+	return replaceDiff(a, b)
+}
+
+// replaceDiff returns the edit script deleting all of a and inserting all of b
+func replaceDiff(a, b []string) []diffOp {
+	ops := make([]diffOp, 0, len(a)+len(b))
+	for _, line := range a {
+		ops = append(ops, diffOp{Kind: diffDelete, Text: line})
+	}
+	for _, line := range b {
+		ops = append(ops, diffOp{Kind: diffInsert, Text: line})
+	}
+	return ops
+}
+
+// backtrackDiff reconstructs the edit script from the recorded Myers trace, where
+// trace[d] holds the furthest reaching x of the diagonals -d..d before round d
+func backtrackDiff(trace [][]int, a, b []string) []diffOp {
+	x, y := len(a), len(b)
+	var reversed []diffOp
+
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+
+		var prevK int
+		if k == -d || (k != d && v[d+k-1] < v[d+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := 0
+		if d > 0 {
+			prevX = v[d+prevK]
+		}
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			reversed = append(reversed, diffOp{Kind: diffEqual, Text: a[x-1]})
+			x--
+			y--
+		}
+
+		if d > 0 {
+			if x == prevX {
+				reversed = append(reversed, diffOp{Kind: diffInsert, Text: b[y-1]})
+			} else {
+				reversed = append(reversed, diffOp{Kind: diffDelete, Text: a[x-1]})
+			}
+		}
+		x, y = prevX, prevY
+	}
+
+	ops := make([]diffOp, len(reversed))
+	for i, op := range reversed {
+		ops[len(reversed)-1-i] = op
+	}
+	return ops
+}
+
+// groupHunks groups an edit script into hunks with the given number of context lines.
+// Changes separated by at most 2*context unchanged lines share a hunk.
+// A negative context returns a single hunk spanning the whole file if anything changed.
+func groupHunks(ops []diffOp, context int) []diffHunk {
+	if context < 0 {
+		for _, op := range ops {
+			if op.Kind != diffEqual {
+				return []diffHunk{newHunk(ops)}
+			}
+		}
+		return nil
+	}
+
+	var hunks []diffHunk
+	for i := 0; i < len(ops); {
+		if ops[i].Kind == diffEqual {
+			i++
+			continue
+		}
+
+		start := maxInt(0, i-context)
+		lastChange := i
+		for j := i; j < len(ops); j++ {
+			if ops[j].Kind != diffEqual {
+				lastChange = j
+			} else if j-lastChange > 2*context {
+				break
+			}
+		}
+		end := minInt(len(ops), lastChange+context+1)
+
+		hunks = append(hunks, newHunk(ops[start:end]))
+		i = end
+	}
+
+	return hunks
+}
+
+// newHunk computes the line ranges covered by a slice of ops
+func newHunk(ops []diffOp) diffHunk {
+	h := diffHunk{Ops: ops}
+	for _, op := range ops {
+		if op.Kind != diffInsert {
+			h.OldLines++
+		}
+		if op.Kind != diffDelete {
+			h.NewLines++
+		}
+	}
+
+	// Ranges are 1-based; an empty range refers to the line before it
+	h.OldStart = ops[0].OldIndex
+	if h.OldLines > 0 {
+		h.OldStart++
+	}
+	h.NewStart = ops[0].NewIndex
+	if h.NewLines > 0 {
+		h.NewStart++
+	}
+	return h
+}
+
+// unifiedDiff returns the unified diff of source (old) and target (new) as lines:
+// "@@ -a,b +c,d @@" hunk headers followed by lines prefixed with ' ', '-' or '+'.
+// context is the number of unchanged lines around each change; negative shows the whole file.
+// Returns nil if the texts are identical.
+func unifiedDiff(source, target string, context int) []string {
+	ops := computeDiff(strings.Split(source, "\n"), strings.Split(target, "\n"))
+
+	var result []string
+	for _, hunk := range groupHunks(ops, context) {
+		result = append(result, hunk.header())
+		for _, op := range hunk.Ops {
+			result = append(result, op.prefix()+op.Text)
+		}
+	}
+	return result
+}
+
+// countChanges returns the number of removed and added lines in a diff from unifiedDiff
+func countChanges(diff []string) (removed, added int) {
+	for _, line := range diff {
+		switch {
+		case strings.HasPrefix(line, "-"):
+			removed++
+		case strings.HasPrefix(line, "+"):
+			added++
+		}
+	}
+	return removed, added
+}
+
+// sideBySideRow is one row of a two-column diff. A zero line number means that side is empty.
+type sideBySideRow struct {
+	OldNum, NewNum int
+	Old, New       string
+	Changed        bool // the row is part of a change rather than context
+	Separator      bool // the row marks skipped lines between two hunks
+}
+
+// sideBySideDiff pairs the lines of source (old) and target (new) into aligned rows.
+// Removed and added lines of the same change share rows; context works as in unifiedDiff.
+// Returns nil if the texts are identical.
+func sideBySideDiff(source, target string, context int) []sideBySideRow {
+	ops := computeDiff(strings.Split(source, "\n"), strings.Split(target, "\n"))
+
+	var rows []sideBySideRow
+	for i, hunk := range groupHunks(ops, context) {
+		if i > 0 {
+			rows = append(rows, sideBySideRow{Separator: true})
+		}
+
+		var deleted, inserted []diffOp
+		flush := func() {
+			for j := 0; j < maxInt(len(deleted), len(inserted)); j++ {
+				row := sideBySideRow{Changed: true}
+				if j < len(deleted) {
+					row.OldNum, row.Old = deleted[j].OldIndex+1, deleted[j].Text
+				}
+				if j < len(inserted) {
+					row.NewNum, row.New = inserted[j].NewIndex+1, inserted[j].Text
+				}
+				rows = append(rows, row)
+			}
+			deleted, inserted = nil, nil
+		}
+
+		for _, op := range hunk.Ops {
+			switch op.Kind {
+			case diffDelete:
+				if len(inserted) > 0 {
+					flush()
+				}
+				deleted = append(deleted, op)
+			case diffInsert:
+				inserted = append(inserted, op)
+			default:
+				flush()
+				rows = append(rows, sideBySideRow{
+					OldNum: op.OldIndex + 1, NewNum: op.NewIndex + 1,
+					Old: op.Text, New: op.Text,
+				})
+			}
+		}
+		flush()
+	}
+	return rows
+}
+
+// intraLineMode selects how changed line pairs are compared for highlighting
+type intraLineMode int
+
+const (
+	intraLineWord intraLineMode = iota
+	intraLineChar
+	intraLineOff
+	intraLineModeCount
+)
+
+func (im intraLineMode) String() string {
+	switch im {
+	case intraLineWord:
+		return "word"
+	case intraLineChar:
+		return "char"
+	default:
+		return "off"
+	}
+}
+
+// lineSegment is a piece of a changed line. Changed marks text that differs from the paired line.
+type lineSegment struct {
+	Text    string
+	Changed bool
+}
+
+// intraLineDiff compares a removed line with the added line replacing it and splits both
+// into segments, marking the words or characters that differ.
+// Returns nil segments if the mode is off or the lines have nothing but whitespace in common,
+// in which case highlighting single tokens would only add noise.
+func intraLineDiff(oldLine, newLine string, mode intraLineMode) (oldSegs, newSegs []lineSegment) {
+	if mode == intraLineOff {
+		return nil, nil
+	}
+
+	ops := computeDiff(splitTokens(oldLine, mode), splitTokens(newLine, mode))
+
+	common := false
+	for _, op := range ops {
+		if op.Kind == diffEqual && strings.TrimSpace(op.Text) != "" {
+			common = true
+			break
+		}
+	}
+	if !common {
+		return nil, nil
+	}
+
+	for _, op := range ops {
+		switch op.Kind {
+		case diffDelete:
+			oldSegs = appendSegment(oldSegs, op.Text, true)
+		case diffInsert:
+			newSegs = appendSegment(newSegs, op.Text, true)
+		default:
+			oldSegs = appendSegment(oldSegs, op.Text, false)
+			newSegs = appendSegment(newSegs, op.Text, false)
+		}
+	}
+	return oldSegs, newSegs
+}
+
+// appendSegment appends text to segs, merging it into the last segment if both have the same state
+func appendSegment(segs []lineSegment, text string, changed bool) []lineSegment {
+	if n := len(segs); n > 0 && segs[n-1].Changed == changed {
+		segs[n-1].Text += text
+		return segs
+	}
+	return append(segs, lineSegment{Text: text, Changed: changed})
+}
+
+// splitTokens splits a line into characters, or into words, whitespace runs and single
+// punctuation characters, so that concatenating the tokens gives back the line
+func splitTokens(line string, mode intraLineMode) []string {
+	var tokens []string
+	if mode == intraLineChar {
+		for _, r := range line {
+			tokens = append(tokens, string(r))
+		}
+		return tokens
+	}
+
+	class := func(r rune) int {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_':
+			return 1
+		case unicode.IsSpace(r):
+			return 2
+		default:
+			return 0 // punctuation is never merged
+		}
+	}
+
+	start := 0
+	prev := -1
+	for i, r := range line {
+		c := class(r)
+		if i > start && (c != prev || c == 0) {
+			tokens = append(tokens, line[start:i])
+			start = i
+		}
+		prev = c
+	}
+	if start < len(line) {
+		tokens = append(tokens, line[start:])
+	}
+	return tokens
+}
+
+// pairChangedLines pairs removed lines with the added lines directly following them in
+// unifiedDiff output and returns their intra-line segments (without the prefix) by line index
+func pairChangedLines(lines []string, mode intraLineMode) map[int][]lineSegment {
+	result := make(map[int][]lineSegment)
+	for i := 0; i < len(lines); {
+		if !strings.HasPrefix(lines[i], "-") {
+			i++
+			continue
+		}
+
+		delStart := i
+		for i < len(lines) && strings.HasPrefix(lines[i], "-") {
+			i++
+		}
+		insStart := i
+		for i < len(lines) && strings.HasPrefix(lines[i], "+") {
+			i++
+		}
+
+		for j := 0; j < minInt(insStart-delStart, i-insStart); j++ {
+			oldSegs, newSegs := intraLineDiff(lines[delStart+j][1:], lines[insStart+j][1:], mode)
+			if oldSegs != nil {
+				result[delStart+j] = oldSegs
+				result[insStart+j] = newSegs
+			}
+		}
+	}
+	return result
+}
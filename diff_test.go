@@ -0,0 +1,303 @@
+package filemirror
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestComputeDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		a    []string
+		b    []string
+		want []string // ops rendered as prefix+text
+	}{
+		{
+			name: "identical",
+			a:    []string{"a", "b"},
+			b:    []string{"a", "b"},
+			want: []string{" a", " b"},
+		},
+		{
+			name: "insert at top is a single insertion",
+			a:    []string{"a", "b", "c"},
+			b:    []string{"x", "a", "b", "c"},
+			want: []string{"+x", " a", " b", " c"},
+		},
+		{
+			name: "delete in middle",
+			a:    []string{"a", "b", "c"},
+			b:    []string{"a", "c"},
+			want: []string{" a", "-b", " c"},
+		},
+		{
+			name: "replace",
+			a:    []string{"a", "b", "c"},
+			b:    []string{"a", "B", "c"},
+			want: []string{" a", "-b", "+B", " c"},
+		},
+		{
+			name: "moved line",
+			a:    []string{"a", "b", "c", "d"},
+			b:    []string{"b", "c", "d", "a"},
+			want: []string{"-a", " b", " c", " d", "+a"},
+		},
+		{
+			name: "empty old",
+			a:    nil,
+			b:    []string{"a"},
+			want: []string{"+a"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, op := range computeDiff(tt.a, tt.b) {
+				got = append(got, op.prefix()+op.Text)
+			}
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("computeDiff() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestComputeDiffLargeUnrelated(t *testing.T) {
+	a := make([]string, 4000)
+	b := make([]string, 4000)
+	for i := range a {
+		a[i] = fmt.Sprintf("old line %d", i)
+		b[i] = fmt.Sprintf("new line %d", i)
+	}
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	ops := computeDiff(a, b)
+	runtime.ReadMemStats(&after)
+
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 64<<20 {
+		t.Errorf("computeDiff() allocated %d MB, want at most 64 MB", allocated>>20)
+	}
+	// Past the edit limit, the texts are diffed as one replacement
+	if len(ops) != len(a)+len(b) || ops[0].Kind != diffDelete || ops[len(ops)-1].Kind != diffInsert {
+		t.Errorf("computeDiff() = %d ops, want %d deletions followed by %d insertions", len(ops), len(a), len(b))
+	}
+
+	// Texts within the limit still get a minimal diff
+	b = append([]string{"inserted"}, a...)
+	b[2000] = "changed"
+	changes := 0
+	for _, op := range computeDiff(a, b) {
+		if op.Kind != diffEqual {
+			changes++
+		}
+	}
+	if changes != 3 {
+		t.Errorf("computeDiff() made %d changes, want 3", changes)
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	lines := func(n int, change map[int]string) string {
+		var out []string
+		for i := 1; i <= n; i++ {
+			if s, ok := change[i]; ok {
+				out = append(out, s)
+				continue
+			}
+			out = append(out, "line"+string(rune('a'+i-1)))
+		}
+		return strings.Join(out, "\n")
+	}
+	source := lines(20, nil)
+
+	tests := []struct {
+		name    string
+		target  string
+		context int
+		want    []string
+	}{
+		{
+			name:    "identical",
+			target:  source,
+			context: 3,
+			want:    nil,
+		},
+		{
+			name:    "single change with context",
+			target:  lines(20, map[int]string{10: "changed"}),
+			context: 1,
+			want:    []string{"@@ -9,3 +9,3 @@", " linei", "-linej", "+changed", " linek"},
+		},
+		{
+			name:    "distant changes get separate hunks",
+			target:  lines(20, map[int]string{2: "x", 18: "y"}),
+			context: 1,
+			want: []string{
+				"@@ -1,3 +1,3 @@", " linea", "-lineb", "+x", " linec",
+				"@@ -17,3 +17,3 @@", " lineq", "-liner", "+y", " lines",
+			},
+		},
+		{
+			name:    "close changes share a hunk",
+			target:  lines(20, map[int]string{5: "x", 7: "y"}),
+			context: 1,
+			want:    []string{"@@ -4,5 +4,5 @@", " lined", "-linee", "+x", " linef", "-lineg", "+y", " lineh"},
+		},
+		{
+			name:    "zero context insertion",
+			target:  strings.Replace(source, "linee\n", "linee\nnew\n", 1),
+			context: 0,
+			want:    []string{"@@ -5,0 +6,1 @@", "+new"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := unifiedDiff(source, tt.target, tt.context)
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("unifiedDiff() =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+
+	t.Run("negative context shows whole file", func(t *testing.T) {
+		got := unifiedDiff(source, lines(20, map[int]string{10: "changed"}), -1)
+		if len(got) != 22 || got[0] != "@@ -1,20 +1,20 @@" {
+			t.Errorf("Expected one hunk spanning the file, got %d lines starting %q", len(got), got[0])
+		}
+		removed, added := countChanges(got)
+		if removed != 1 || added != 1 {
+			t.Errorf("countChanges() = -%d +%d, want -1 +1", removed, added)
+		}
+	})
+}
+
+func TestSideBySideDiff(t *testing.T) {
+	source := "a\nb\nc\nd"
+	target := "a\nB\nc\nd\ne"
+
+	rows := sideBySideDiff(source, target, -1)
+	want := []sideBySideRow{
+		{OldNum: 1, NewNum: 1, Old: "a", New: "a"},
+		{OldNum: 2, NewNum: 2, Old: "b", New: "B", Changed: true},
+		{OldNum: 3, NewNum: 3, Old: "c", New: "c"},
+		{OldNum: 4, NewNum: 4, Old: "d", New: "d"},
+		{NewNum: 5, New: "e", Changed: true},
+	}
+	if len(rows) != len(want) {
+		t.Fatalf("Got %d rows, want %d: %+v", len(rows), len(want), rows)
+	}
+	for i := range want {
+		if rows[i] != want[i] {
+			t.Errorf("row %d = %+v, want %+v", i, rows[i], want[i])
+		}
+	}
+
+	if rows := sideBySideDiff(source, source, 3); rows != nil {
+		t.Errorf("Expected no rows for identical texts, got %+v", rows)
+	}
+
+	// Distant changes are split by a separator row
+	long := strings.Repeat("x\n", 20)
+	rows = sideBySideDiff("1\n"+long+"2", "one\n"+long+"two", 1)
+	separators := 0
+	for _, row := range rows {
+		if row.Separator {
+			separators++
+		}
+	}
+	if separators != 1 {
+		t.Errorf("Expected 1 separator between hunks, got %d in %+v", separators, rows)
+	}
+}
+
+func TestIntraLineDiff(t *testing.T) {
+	render := func(segs []lineSegment) string {
+		var b strings.Builder
+		for _, seg := range segs {
+			if seg.Changed {
+				b.WriteString("[" + seg.Text + "]")
+			} else {
+				b.WriteString(seg.Text)
+			}
+		}
+		return b.String()
+	}
+
+	tests := []struct {
+		name    string
+		old     string
+		new     string
+		mode    intraLineMode
+		wantOld string
+		wantNew string
+	}{
+		{
+			name:    "word change",
+			old:     "  enabled: false",
+			new:     "  enabled: true",
+			mode:    intraLineWord,
+			wantOld: "  enabled: [false]",
+			wantNew: "  enabled: [true]",
+		},
+		{
+			name:    "char change",
+			old:     "timeout: 30s",
+			new:     "timeout: 35s",
+			mode:    intraLineChar,
+			wantOld: "timeout: 3[0]s",
+			wantNew: "timeout: 3[5]s",
+		},
+		{
+			name:    "word mode marks the whole token",
+			old:     "timeout: 30s",
+			new:     "timeout: 35s",
+			mode:    intraLineWord,
+			wantOld: "timeout: [30s]",
+			wantNew: "timeout: [35s]",
+		},
+		{
+			name: "nothing in common",
+			old:  "alpha",
+			new:  "beta",
+			mode: intraLineWord,
+		},
+		{
+			name: "off",
+			old:  "a: 1",
+			new:  "a: 2",
+			mode: intraLineOff,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			oldSegs, newSegs := intraLineDiff(tt.old, tt.new, tt.mode)
+			if got := render(oldSegs); got != tt.wantOld {
+				t.Errorf("old = %q, want %q", got, tt.wantOld)
+			}
+			if got := render(newSegs); got != tt.wantNew {
+				t.Errorf("new = %q, want %q", got, tt.wantNew)
+			}
+		})
+	}
+}
+
+func TestPairChangedLines(t *testing.T) {
+	lines := []string{"@@ -1,4 +1,4 @@", " a: 1", "-b: 2", "-c: 3", "+b: 20", "+d", " e: 5"}
+
+	segments := pairChangedLines(lines, intraLineWord)
+	if len(segments) != 2 {
+		t.Fatalf("Expected the first removed/added pair only, got %v", segments)
+	}
+	if _, ok := segments[2]; !ok {
+		t.Error("Expected segments for removed line b")
+	}
+	if _, ok := segments[4]; !ok {
+		t.Error("Expected segments for added line b")
+	}
+}
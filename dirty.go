@@ -0,0 +1,250 @@
+package filemirror
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// dirtyAction is what the sync does with a target that has uncommitted changes
+type dirtyAction int
+
+const (
+	dirtyUndecided dirtyAction = iota // the user has not chosen yet; the sync cannot start
+	dirtySkip                         // leave the target out of the sync
+	dirtyStash                        // stash the local changes, then overwrite the target
+	dirtyAbort                        // do not sync at all
+)
+
+func (a dirtyAction) String() string {
+	switch a {
+	case dirtySkip:
+		return "skip"
+	case dirtyStash:
+		return "stash"
+	case dirtyAbort:
+		return "abort"
+	default:
+		return "choose"
+	}
+}
+
+// dirtyTarget is a selected target with uncommitted changes in its repository
+type dirtyTarget struct {
+	path   string // absolute target path
+	repo   string // repository root
+	status string // porcelain status code, e.g. " M" or "??"
+	action dirtyAction
+}
+
+// describe explains the status code in words
+func (d dirtyTarget) describe() string {
+	switch {
+	case d.status == "??":
+		return "untracked"
+	case strings.Contains(d.status, "D"):
+		return "deleted"
+	case strings.TrimSpace(d.status) == "A":
+		return "added, not committed"
+	case d.status[1] == ' ':
+		return "staged changes"
+	default:
+		return "modified"
+	}
+}
+
+// findDirtyTargets returns the targets of repoFiles that have uncommitted changes,
+// sorted by path. Repositories whose status cannot be read are skipped.
+func findDirtyTargets(repoFiles map[string][]string) []dirtyTarget {
+	var dirty []dirtyTarget
+	for repo, files := range repoFiles {
+		statuses, err := gitFileStatus(repo, files)
+		if err != nil {
+			continue
+		}
+		for _, file := range files {
+			if status, ok := statuses[file]; ok {
+				dirty = append(dirty, dirtyTarget{path: file, repo: repo, status: status})
+			}
+		}
+	}
+
+	sort.Slice(dirty, func(i, j int) bool {
+		return dirty[i].path < dirty[j].path
+	})
+	return dirty
+}
+
+// gitFileStatus runs git status --porcelain for files in repo and returns the
+// status code of every file that has uncommitted changes, keyed by the given path
+func gitFileStatus(repo string, files []string) (map[string]string, error) {
+	byRel := make(map[string]string, len(files))
+	args := []string{"-C", repo, "status", "--porcelain", "-z", "--untracked-files=all", "--"}
+	for _, file := range files {
+		rel, err := filepath.Rel(repo, file)
+		if err != nil {
+			return nil, err
+		}
+		rel = filepath.ToSlash(rel)
+		byRel[rel] = file
+		args = append(args, rel)
+	}
+
+	output, err := exec.Command("git", args...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("git status failed in %s: %w", repo, err)
+	}
+
+	statuses := make(map[string]string)
+	entries := strings.Split(string(output), "\x00")
+	for i := 0; i < len(entries); i++ {
+		entry := entries[i]
+		if len(entry) < 4 {
+			continue
+		}
+		code, rel := entry[:2], entry[3:]
+		if code[0] == 'R' || code[0] == 'C' {
+			i++ // renames and copies are followed by the original path
+		}
+		if file, ok := byRel[rel]; ok {
+			statuses[file] = code
+		}
+	}
+	return statuses, nil
+}
+
+// stashFile stashes the uncommitted changes of a single file, including an untracked file
+func stashFile(repo, file string) error {
+	rel, err := filepath.Rel(repo, file)
+	if err != nil {
+		return err
+	}
+	message := "fmr: local changes to " + filepath.ToSlash(rel) + " before sync"
+	cmd := exec.Command("git", "-C", repo, "stash", "push", "--include-untracked", "-m", message, "--", filepath.ToSlash(rel))
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("git stash failed: %w\n%s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// guardsDirtyTargets reports whether targets with uncommitted changes need a decision.
+// Git-only syncs never write the targets, so they need none.
+func (m model) guardsDirtyTargets() bool {
+	return len(m.dirty) > 0 && !(m.gitEnabled && m.gitOnly)
+}
+
+// applyDirtyChoices checks the decisions for targets with uncommitted changes before a sync
+// starts: an abort returns to the file list. Skipped targets stay selected; the sync leaves
+// them out (see syncTargets). Returns an error if a target is still undecided or nothing is
+// left to sync.
+func (m *model) applyDirtyChoices() error {
+	undecided := 0
+	for _, d := range m.dirty {
+		switch d.action {
+		case dirtyAbort:
+			m.mode = modeSelect
+			return fmt.Errorf("sync aborted: %s has uncommitted changes", m.displayPath(m.selected[d.path]))
+		case dirtyUndecided:
+			undecided++
+		}
+	}
+	if undecided > 0 {
+		return fmt.Errorf("%d target(s) have uncommitted changes: choose skip, stash or abort for each", undecided)
+	}
+
+	skipped := 0
+	for _, d := range m.dirty {
+		if d.action == dirtySkip {
+			skipped++
+		}
+	}
+	if skipped == len(m.selected) {
+		return fmt.Errorf("all targets are skipped, nothing to sync")
+	}
+	return nil
+}
+
+// skippedTargets returns the targets left out of the sync because of their uncommitted changes
+func (m model) skippedTargets() map[string]bool {
+	skipped := make(map[string]bool)
+	if !m.guardsDirtyTargets() {
+		return skipped
+	}
+	for _, d := range m.dirty {
+		if d.action == dirtySkip {
+			skipped[d.path] = true
+		}
+	}
+	return skipped
+}
+
+// syncTargets returns the selected targets the sync writes, without the skipped ones
+func (m model) syncTargets() []FileInfo {
+	skipped := m.skippedTargets()
+	files := m.selectedFiles()
+	return slices.DeleteFunc(files, func(f FileInfo) bool { return skipped[f.fullPath(m.workDir)] })
+}
+
+// syncRepos returns the files the git workflow commits in each repository, without the
+// skipped targets
+func (m model) syncRepos() map[string][]string {
+	skipped := m.skippedTargets()
+	repos := make(map[string][]string, len(m.gitRepos))
+	for repo, files := range m.gitRepos {
+		var kept []string
+		for _, file := range files {
+			if !skipped[file] {
+				kept = append(kept, file)
+			}
+		}
+		if len(kept) > 0 {
+			repos[repo] = kept
+		}
+	}
+	return repos
+}
+
+// stashTargets returns the targets whose local changes are stashed before copying, by repository
+func (m model) stashTargets() map[string]string {
+	stash := make(map[string]string)
+	for _, d := range m.dirty {
+		if d.action == dirtyStash {
+			stash[d.path] = d.repo
+		}
+	}
+	return stash
+}
+
+// renderDirtyTargets lists the targets with uncommitted changes and the chosen action
+func (m model) renderDirtyTargets() string {
+	var b strings.Builder
+	warnStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("11")).Bold(true)
+	b.WriteString("\n" + warnStyle.Render(fmt.Sprintf("⚠ Uncommitted changes (%d):", len(m.dirty))) + "\n")
+
+	for i, d := range m.dirty {
+		line := fmt.Sprintf("%s (%s) [%s]", m.displayPath(m.selected[d.path]), d.describe(), d.action)
+		style := lipgloss.NewStyle()
+		if d.action == dirtyUndecided || d.action == dirtyAbort {
+			style = style.Foreground(lipgloss.Color("11"))
+		}
+		if m.confirmFocus == focusDirtyTargets && i == m.dirtyCursor {
+			style = style.Background(lipgloss.Color("240")).Bold(true)
+			line = "> " + line
+		} else {
+			line = "  " + line
+		}
+		b.WriteString(style.Render(line) + "\n")
+	}
+
+	hint := "TAB here, then s: skip • t: stash • a: abort"
+	if m.confirmFocus == focusDirtyTargets {
+		hint = "↑/↓: move • s: skip • t: stash • a: abort • SPACE: cycle"
+	}
+	b.WriteString(lipgloss.NewStyle().Foreground(lipgloss.Color("240")).Render(hint) + "\n")
+	return b.String()
+}
@@ -0,0 +1,191 @@
+package filemirror
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// commitFiles writes files into repo and commits them
+func commitFiles(t *testing.T, repo string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(repo, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	for _, args := range [][]string{{"add", "-A"}, {"commit", "-q", "-m", "add files"}} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = repo
+		if output, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, output)
+		}
+	}
+}
+
+func TestFindDirtyTargets(t *testing.T) {
+	repo := createTestGitRepo(t)
+	defer os.RemoveAll(repo)
+	commitFiles(t, repo, map[string]string{"clean.txt": "clean", "modified.txt": "committed", "staged.txt": "committed"})
+
+	for name, content := range map[string]string{"modified.txt": "local edit", "staged.txt": "staged edit", "untracked.txt": "new"} {
+		if err := os.WriteFile(filepath.Join(repo, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	if err := exec.Command("git", "-C", repo, "add", "staged.txt").Run(); err != nil {
+		t.Fatalf("git add failed: %v", err)
+	}
+
+	var files []string
+	for _, name := range []string{"clean.txt", "modified.txt", "staged.txt", "untracked.txt", "missing.txt"} {
+		files = append(files, filepath.Join(repo, name))
+	}
+	dirty := findDirtyTargets(map[string][]string{repo: files})
+
+	var got []string
+	for _, d := range dirty {
+		got = append(got, filepath.Base(d.path)+"="+d.describe())
+		if d.repo != repo || d.action != dirtyUndecided {
+			t.Errorf("Unexpected dirty target %+v", d)
+		}
+	}
+	want := "modified.txt=modified,staged.txt=staged changes,untracked.txt=untracked"
+	if strings.Join(got, ",") != want {
+		t.Errorf("findDirtyTargets() = %s, want %s", strings.Join(got, ","), want)
+	}
+}
+
+func TestStashFile(t *testing.T) {
+	repo := createTestGitRepo(t)
+	defer os.RemoveAll(repo)
+	commitFiles(t, repo, map[string]string{"a.txt": "committed", "b.txt": "committed"})
+
+	for _, name := range []string{"a.txt", "b.txt"} {
+		if err := os.WriteFile(filepath.Join(repo, name), []byte("local edit"), 0o644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	if err := stashFile(repo, filepath.Join(repo, "a.txt")); err != nil {
+		t.Fatalf("stashFile failed: %v", err)
+	}
+
+	// Only a.txt is stashed
+	if content, _ := os.ReadFile(filepath.Join(repo, "a.txt")); string(content) != "committed" {
+		t.Errorf("a.txt = %q, want committed content", content)
+	}
+	if content, _ := os.ReadFile(filepath.Join(repo, "b.txt")); string(content) != "local edit" {
+		t.Errorf("b.txt = %q, want local edit kept", content)
+	}
+	stashes, _ := exec.Command("git", "-C", repo, "stash", "list").Output()
+	if !strings.Contains(string(stashes), "fmr: local changes to a.txt before sync") {
+		t.Errorf("stash list = %q", stashes)
+	}
+}
+
+func TestConfirmDirtyTargets(t *testing.T) {
+	repo := createTestGitRepo(t)
+	defer os.RemoveAll(repo)
+	commitFiles(t, repo, map[string]string{"a.txt": "committed", "b.txt": "committed", "c.txt": "committed"})
+
+	source := filepath.Join(t.TempDir(), "source.txt")
+	if err := os.WriteFile(source, []byte("synced"), 0o644); err != nil {
+		t.Fatalf("Failed to write source: %v", err)
+	}
+	for _, name := range []string{"a.txt", "b.txt"} {
+		if err := os.WriteFile(filepath.Join(repo, name), []byte("local edit"), 0o644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	newModel := func() model {
+		m := InitialModel("", repo)
+		m.sourceFile = &FileInfo{Path: source}
+		for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+			m.toggleSelected(FileInfo{Path: filepath.Join(repo, name)})
+		}
+		m.mode = modeConfirm
+		m.initGitWorkflow()
+		m.gitEnabled = false
+		m.confirmFocus = focusCancelButton
+		m.width, m.height = 160, 50
+		return m
+	}
+	press := func(m model, keys ...string) model {
+		for _, key := range keys {
+			updated, _ := m.updateConfirm(keyMsg(key))
+			m = *updated.(*model)
+		}
+		return m
+	}
+
+	t.Run("undecided targets block the sync", func(t *testing.T) {
+		m := newModel()
+		if len(m.dirty) != 2 {
+			t.Fatalf("Expected 2 dirty targets, got %d", len(m.dirty))
+		}
+		if !strings.Contains(m.viewConfirm(), "Uncommitted changes (2)") {
+			t.Error("Expected the confirm screen to list the dirty targets")
+		}
+
+		m = press(m, "tab", "tab", "enter")
+		if m.confirmFocus != focusCopyButton || m.mode != modeConfirm {
+			t.Fatalf("focus = %v, mode = %v", m.confirmFocus, m.mode)
+		}
+		if m.err == nil || !strings.Contains(m.err.Error(), "2 target(s) have uncommitted changes") {
+			t.Errorf("err = %v", m.err)
+		}
+	})
+
+	t.Run("abort returns to the file list", func(t *testing.T) {
+		m := newModel()
+		m = press(m, "tab", "a", "tab", "enter")
+		if m.mode != modeSelect || m.err == nil || !strings.Contains(m.err.Error(), "sync aborted") {
+			t.Errorf("mode = %v, err = %v", m.mode, m.err)
+		}
+	})
+
+	t.Run("skip and stash", func(t *testing.T) {
+		m := newModel()
+		// a.txt: skip, b.txt: stash
+		m = press(m, "tab", "s", "down", "t", "tab")
+		if m.dirty[0].action != dirtySkip || m.dirty[1].action != dirtyStash {
+			t.Fatalf("actions = %v, %v", m.dirty[0].action, m.dirty[1].action)
+		}
+
+		if err := m.applyDirtyChoices(); err != nil {
+			t.Fatalf("applyDirtyChoices failed: %v", err)
+		}
+		// The skipped target stays selected, in case the sync is cancelled or fails
+		if !m.isSelected(FileInfo{Path: filepath.Join(repo, "a.txt")}) || len(m.selected) != 3 {
+			t.Errorf("Expected the selection to be kept, selected = %d", len(m.selected))
+		}
+		targets := m.syncTargets()
+		if len(targets) != 2 || slices.ContainsFunc(targets, func(f FileInfo) bool { return filepath.Base(f.Path) == "a.txt" }) {
+			t.Errorf("syncTargets() = %v, want a.txt left out", targets)
+		}
+		if files := m.syncRepos()[repo]; len(files) != 2 || slices.Contains(files, filepath.Join(repo, "a.txt")) {
+			t.Errorf("syncRepos() = %v, want a.txt left out", files)
+		}
+
+		m, _ = driveSync(t, m)
+		if m.err != nil {
+			t.Fatalf("Unexpected error: %v", m.err)
+		}
+
+		want := map[string]string{"a.txt": "local edit", "b.txt": "synced", "c.txt": "synced"}
+		for name, content := range want {
+			if got, _ := os.ReadFile(filepath.Join(repo, name)); string(got) != content {
+				t.Errorf("%s = %q, want %q", name, got, content)
+			}
+		}
+		stashes, _ := exec.Command("git", "-C", repo, "stash", "list").Output()
+		if !strings.Contains(string(stashes), "local changes to b.txt") {
+			t.Errorf("Expected b.txt to be stashed, stash list = %q", stashes)
+		}
+	})
+}
@@ -59,3 +59,31 @@ func copyFile(src, dst string) error {
 
 	return nil
 }
+
+// writeFileAtomic writes content to path through a temp file in the same directory,
+// so readers never see a partially written file
+func writeFileAtomic(path string, content []byte, mode os.FileMode) error {
+	tmpFile, err := os.CreateTemp(filepath.Dir(path), ".fmr-tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temp file: %w", err)
+	}
+	tmpPath := tmpFile.Name()
+	defer func() {
+		_ = os.Remove(tmpPath) // Best effort cleanup, ignore error
+	}()
+
+	if _, err := tmpFile.Write(content); err != nil {
+		_ = tmpFile.Close() // Best effort close, ignore error
+		return fmt.Errorf("failed to write content: %w", err)
+	}
+	if err := tmpFile.Close(); err != nil {
+		return fmt.Errorf("failed to close temp file: %w", err)
+	}
+	if err := os.Chmod(tmpPath, mode); err != nil {
+		return fmt.Errorf("failed to set permissions: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("failed to rename temp file: %w", err)
+	}
+	return nil
+}
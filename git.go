@@ -1,20 +1,25 @@
 package filemirror
 
 import (
+	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
 )
 
 func getGitBranch(filePath string) string {
 	// Get the directory containing the file
-	dir := filepath.Dir(filePath)
+	return gitBranchInDir(filepath.Dir(filePath))
+}
 
+// gitBranchInDir returns the current branch of the repository containing dir, or "-"
+func gitBranchInDir(dir string) string {
 	// Run git command to get current branch
 	cmd := exec.Command("git", "-C", dir, "branch", "--show-current")
 	output, err := cmd.Output()
 	if err != nil {
-		// Not in a git repo or git not available
+		// Not in a git repository or git not available
 		return "-"
 	}
 
@@ -25,3 +30,68 @@ func getGitBranch(filePath string) string {
 
 	return branch
 }
+
+// gitInfoCache resolves git metadata once per repository instead of once per file.
+// Repository roots are cached by directory and branches by repository root.
+// It is safe for concurrent use.
+type gitInfoCache struct {
+	mu       sync.Mutex
+	roots    map[string]string          // directory -> repository root, "" if none
+	branches map[string]*gitBranchEntry // repository root -> current branch
+}
+
+// gitBranchEntry resolves the branch of one repository exactly once
+type gitBranchEntry struct {
+	once   sync.Once
+	branch string
+}
+
+func newGitInfoCache() *gitInfoCache {
+	return &gitInfoCache{
+		roots:    make(map[string]string),
+		branches: make(map[string]*gitBranchEntry),
+	}
+}
+
+// branch returns the current branch for a file, or "-" if it is not in a git repository
+func (c *gitInfoCache) branch(filePath string) string {
+	root := c.repoRoot(filepath.Dir(filePath))
+	if root == "" {
+		return "-"
+	}
+
+	c.mu.Lock()
+	entry, ok := c.branches[root]
+	if !ok {
+		entry = &gitBranchEntry{}
+		c.branches[root] = entry
+	}
+	c.mu.Unlock()
+
+	entry.once.Do(func() {
+		entry.branch = gitBranchInDir(root)
+	})
+	return entry.branch
+}
+
+// repoRoot returns the closest directory at or above dir containing a .git entry
+// (a directory, or a file for worktrees and submodules), or "" if there is none
+func (c *gitInfoCache) repoRoot(dir string) string {
+	c.mu.Lock()
+	root, ok := c.roots[dir]
+	c.mu.Unlock()
+	if ok {
+		return root
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+		root = dir
+	} else if parent := filepath.Dir(dir); parent != dir {
+		root = c.repoRoot(parent)
+	}
+
+	c.mu.Lock()
+	c.roots[dir] = root
+	c.mu.Unlock()
+	return root
+}
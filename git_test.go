@@ -221,3 +221,58 @@ func TestGetGitBranchEmptyRepo(t *testing.T) {
 		t.Logf("Note: Empty repo returned branch %q (expected %q)", branch, "-")
 	}
 }
+
+func TestGitInfoCache(t *testing.T) {
+	tmpDir := t.TempDir()
+	repo := filepath.Join(tmpDir, "repo")
+	nested := filepath.Join(repo, "vendor-lib")
+	plain := filepath.Join(tmpDir, "plain")
+
+	for _, dir := range []string{filepath.Join(repo, "sub", "deep"), nested, plain} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+	}
+	for dir, branch := range map[string]string{repo: "main", nested: "develop"} {
+		if err := exec.Command("git", "init", "-b", branch, dir).Run(); err != nil {
+			t.Fatalf("Failed to init git repo: %v", err)
+		}
+	}
+
+	cache := newGitInfoCache()
+	tests := []struct {
+		file string
+		want string
+	}{
+		{filepath.Join(repo, "a.txt"), "main"},
+		{filepath.Join(repo, "sub", "deep", "b.txt"), "main"},
+		{filepath.Join(nested, "c.txt"), "develop"},
+		{filepath.Join(plain, "d.txt"), "-"},
+	}
+	for _, tt := range tests {
+		if got := cache.branch(tt.file); got != tt.want {
+			t.Errorf("branch(%q) = %q, want %q", tt.file, got, tt.want)
+		}
+	}
+
+	if got := cache.repoRoot(filepath.Join(repo, "sub", "deep")); got != repo {
+		t.Errorf("repoRoot(sub/deep) = %q, want %q", got, repo)
+	}
+	if _, ok := cache.roots[filepath.Join(repo, "sub")]; !ok {
+		t.Error("Expected parent directories to be cached while walking up")
+	}
+	if len(cache.branches) != 2 {
+		t.Errorf("Expected one branch lookup per repository, got %d", len(cache.branches))
+	}
+
+	// The branch is resolved once; later switches are not seen by the same cache
+	if err := exec.Command("git", "-C", repo, "checkout", "-q", "-b", "feature").Run(); err != nil {
+		t.Fatalf("Failed to switch branch: %v", err)
+	}
+	if got := cache.branch(filepath.Join(repo, "a.txt")); got != "main" {
+		t.Errorf("Cached branch = %q, want %q", got, "main")
+	}
+	if got := newGitInfoCache().branch(filepath.Join(repo, "a.txt")); got != "feature" {
+		t.Errorf("Fresh cache branch = %q, want %q", got, "feature")
+	}
+}
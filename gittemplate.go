@@ -0,0 +1,85 @@
+package filemirror
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// GitTemplates are text/template branch name and commit message templates, see gitTemplateData.
+// Empty templates keep the defaults.
+type GitTemplates struct {
+	Branch        string `yaml:"branch"`
+	CommitMessage string `yaml:"commit_message"`
+}
+
+// validate parses the templates, so mistakes surface before anything is copied
+func (g GitTemplates) validate() error {
+	for name, text := range map[string]string{"branch": g.Branch, "commit message": g.CommitMessage} {
+		if _, err := template.New(name).Parse(text); err != nil {
+			return fmt.Errorf("invalid %s template: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// gitTemplateData is available to the branch name and commit message templates
+type gitTemplateData struct {
+	SourceName   string   // source file name made branch-safe, e.g. "golangci" for ".golangci.yml"
+	SourceFile   string   // source file name
+	SourcePath   string   // source path as shown to the user
+	SourceRepo   string   // directory name of the source's git repository, if any
+	SourceCommit string   // short commit hash of the source repository's HEAD, if any
+	Date         string   // today, as 2006-01-02
+	Targets      []string // target paths as shown to the user
+	Ticket       string   // ticket ID from --ticket or the confirm screen
+}
+
+// newGitTemplateData collects the template variables for syncing sourcePath, shown to the
+// user as displaySource, into targets
+func newGitTemplateData(sourcePath, displaySource string, targets []string, ticket string) gitTemplateData {
+	data := gitTemplateData{
+		SourceName: normalizeBranchName(sourcePath),
+		SourceFile: filepath.Base(sourcePath),
+		SourcePath: displaySource,
+		Date:       time.Now().Format("2006-01-02"),
+		Targets:    targets,
+		Ticket:     ticket,
+	}
+	if root, err := detectGitRoot(sourcePath); err == nil {
+		data.SourceRepo = filepath.Base(root)
+		if output, err := exec.Command("git", "-C", root, "rev-parse", "--short", "HEAD").Output(); err == nil {
+			data.SourceCommit = strings.TrimSpace(string(output))
+		}
+	}
+	return data
+}
+
+// renderGitTemplate executes a branch name or commit message template
+func renderGitTemplate(name, text string, data gitTemplateData) (string, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s template: %w", name, err)
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("%s template: %w", name, err)
+	}
+	return b.String(), nil
+}
+
+// renderBranchName renders a branch name template and validates the result
+func renderBranchName(text string, data gitTemplateData) (string, error) {
+	branch, err := renderGitTemplate("branch", text, data)
+	if err != nil {
+		return "", err
+	}
+	branch = strings.TrimSpace(branch)
+	if err := validateBranchName(branch); err != nil {
+		return branch, fmt.Errorf("%q: %w", branch, err)
+	}
+	return branch, nil
+}
@@ -0,0 +1,147 @@
+package filemirror
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderGitTemplates(t *testing.T) {
+	repo := createTestGitRepo(t)
+	defer os.RemoveAll(repo)
+	source := filepath.Join(repo, ".golangci.yml")
+
+	commit, err := exec.Command("git", "-C", repo, "rev-parse", "--short", "HEAD").Output()
+	if err != nil {
+		t.Fatalf("git rev-parse failed: %v", err)
+	}
+	data := newGitTemplateData(source, "canonical/.golangci.yml", []string{"a/.golangci.yml", "b/.golangci.yml"}, "OPS-42")
+	if data.SourceRepo != filepath.Base(repo) || data.SourceCommit != strings.TrimSpace(string(commit)) {
+		t.Errorf("SourceRepo = %q, SourceCommit = %q", data.SourceRepo, data.SourceCommit)
+	}
+
+	tests := []struct {
+		name        string
+		template    string
+		want        string
+		errContains string
+	}{
+		{name: "literal branch", template: "chore/sync", want: "chore/sync"},
+		{name: "ticket and source name", template: "fix/{{.Ticket}}-{{.SourceName}}", want: "fix/OPS-42-golangci"},
+		{name: "date", template: "sync/{{.Date}}", want: "sync/" + time.Now().Format("2006-01-02")},
+		{name: "trailing newline from YAML", template: "sync/{{.SourceName}}\n", want: "sync/golangci"},
+		{name: "renders an invalid name", template: "sync {{.SourceFile}}", errContains: "cannot contain ' '"},
+		{name: "unknown field", template: "{{.Tikcet}}", errContains: "can't evaluate field Tikcet"},
+		{name: "parse error", template: "{{.Ticket", errContains: "invalid branch template"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := renderBranchName(tt.template, data)
+			if tt.errContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+					t.Errorf("renderBranchName() error = %v, want %q", err, tt.errContains)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("renderBranchName() failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("renderBranchName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	message, err := renderGitTemplate("commit message", "{{.Ticket}}: sync {{.SourceFile}}\n\n{{range .Targets}}- {{.}}\n{{end}}", data)
+	if err != nil {
+		t.Fatalf("renderGitTemplate() failed: %v", err)
+	}
+	if want := "OPS-42: sync .golangci.yml\n\n- a/.golangci.yml\n- b/.golangci.yml\n"; message != want {
+		t.Errorf("Commit message = %q, want %q", message, want)
+	}
+}
+
+func TestRunSyncWithTemplates(t *testing.T) {
+	repo := createTestGitRepo(t)
+	defer os.RemoveAll(repo)
+	for name, content := range map[string]string{"source.txt": "canonical", "target.txt": "old"} {
+		if err := os.WriteFile(filepath.Join(repo, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	code := runSync([]string{"-p", repo, "-s", "source.txt", "-t", "target.txt", "--ticket", "OPS-7",
+		"-b", "{{.Ticket}}/{{.SourceName}}", "-m", "{{.Ticket}}: sync {{.SourcePath}} into {{len .Targets}} file(s)"}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("Exit code = %d\nstdout: %s\nstderr: %s", code, stdout.String(), stderr.String())
+	}
+
+	subject, err := exec.Command("git", "-C", repo, "log", "-1", "--format=%s", "OPS-7/source").Output()
+	if err != nil {
+		t.Fatalf("Expected branch OPS-7/source: %v\n%s", err, stdout.String())
+	}
+	if got := strings.TrimSpace(string(subject)); got != "OPS-7: sync source.txt into 1 file(s)" {
+		t.Errorf("Commit subject = %q", got)
+	}
+
+	// A template rendering an invalid branch name fails before committing
+	stdout.Reset()
+	code = runSync([]string{"-p", repo, "-s", "source.txt", "-t", "target.txt", "-b", "sync/{{.Ticket}}"}, &stdout, &stderr)
+	if code != 1 || !strings.Contains(stdout.String(), "Invalid branch name") {
+		t.Errorf("Exit code = %d, want 1 for an empty ticket\n%s", code, stdout.String())
+	}
+
+	verifyWorktreesCleanedUp(t, repo)
+}
+
+func TestConfirmTemplatePreview(t *testing.T) {
+	tmpDir := writeManifestTree(t, `git:
+  branch: "{{.Ticket}}/sync-{{.SourceName}}"
+  commit_message: "{{.Ticket}}: sync {{.SourceFile}}"
+groups:
+  - source: canonical/config.yaml
+    targets: [services/api/config.yaml]
+`)
+	manifest, err := loadManifest(filepath.Join(tmpDir, manifestFileName))
+	if err != nil {
+		t.Fatalf("loadManifest failed: %v", err)
+	}
+
+	m := InitialModel("", tmpDir)
+	m.manifest = manifest
+	m.sourceFile = &FileInfo{Path: filepath.Join("canonical", "config.yaml")}
+	m.toggleSelected(FileInfo{Path: filepath.Join("services", "api", "config.yaml")})
+	m.mode = modeConfirm
+	m.initGitWorkflow()
+	m.gitEnabled = true // the tree is not a repository, but the templates are rendered all the same
+	m.width, m.height = 160, 60
+
+	if got := m.branchNameInput.Value(); got != manifest.Git.Branch {
+		t.Errorf("branch input = %q, want the project template", got)
+	}
+	// Without a ticket the branch name is invalid
+	if !strings.Contains(m.viewConfirm(), "✗ Invalid branch name") {
+		t.Error("Expected the preview to flag the invalid branch name")
+	}
+	m.confirmFocus = focusCopyButton
+	updated, _ := m.updateConfirm(keyMsg("enter"))
+	m = *updated.(*model)
+	if m.mode != modeConfirm || m.err == nil {
+		t.Fatalf("Expected the sync to be blocked, mode = %v, err = %v", m.mode, m.err)
+	}
+
+	m.ticketInput.SetValue("OPS-9")
+	view := m.viewConfirm()
+	if !strings.Contains(view, "Preview: OPS-9/sync-config") || !strings.Contains(view, "OPS-9: sync config.yaml") {
+		t.Errorf("Expected a rendered preview, got:\n%s", view)
+	}
+	if branch, message, err := m.renderGitFields(); branch != "OPS-9/sync-config" || message != "OPS-9: sync config.yaml" || err != nil {
+		t.Errorf("renderGitFields() = %q, %q, %v", branch, message, err)
+	}
+}
@@ -7,7 +7,11 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 )
 
 // detectGitRoot finds the git repository root for a file
@@ -46,6 +50,15 @@ func groupFilesByRepo(files []string) map[string][]string {
 	return repos
 }
 
+// countFiles returns the number of files in all repositories of repos
+func countFiles(repos map[string][]string) int {
+	n := 0
+	for _, files := range repos {
+		n += len(files)
+	}
+	return n
+}
+
 // generateWorktreeID generates a random ID for worktree paths
 func generateWorktreeID() string {
 	bytes := make([]byte, 8)
@@ -83,6 +96,48 @@ func getDefaultBranch(repoPath string) (string, error) {
 	return "", fmt.Errorf("could not determine default branch")
 }
 
+// refExists reports whether ref names a commit in repoPath
+func refExists(repoPath, ref string) bool {
+	return exec.Command("git", "-C", repoPath, "rev-parse", "--verify", "--quiet", ref+"^{commit}").Run() == nil
+}
+
+// resolveBaseRef returns the ref a new sync branch in repoPath starts from. An empty base
+// selects the default branch, preferring origin's copy of it if remote is set (after a fetch);
+// "HEAD" selects the current HEAD and anything else is used as given.
+// Repositories without a recognizable default branch fall back to HEAD.
+func resolveBaseRef(repoPath, base string, remote bool) (string, error) {
+	if base != "" {
+		if !refExists(repoPath, base) {
+			return "", fmt.Errorf("base ref %q not found", base)
+		}
+		return base, nil
+	}
+
+	branch, err := getDefaultBranch(repoPath)
+	if err != nil {
+		return "HEAD", nil
+	}
+	candidates := []string{branch, "origin/" + branch}
+	if remote {
+		candidates = []string{"origin/" + branch, branch}
+	}
+	for _, ref := range candidates {
+		if refExists(repoPath, ref) {
+			return ref, nil
+		}
+	}
+	return "HEAD", nil
+}
+
+// fetchOrigin updates the remote-tracking branches of origin in repoPath
+func fetchOrigin(repoPath string) error {
+	cmd := exec.Command("git", "-C", repoPath, "fetch", "--quiet", "origin")
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("failed to fetch: %w\n%s", err, string(output))
+	}
+	return nil
+}
+
 // getChangedFilesInBranch returns the list of files changed in a branch compared to its base
 func getChangedFilesInBranch(repoPath, branchName string) ([]string, error) {
 	// Get the default branch to use as base
@@ -90,9 +145,12 @@ func getChangedFilesInBranch(repoPath, branchName string) ([]string, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to determine base branch: %w", err)
 	}
+	return getChangedFilesSince(repoPath, baseBranch, branchName)
+}
 
-	// Get list of changed files in the branch
-	cmd := exec.Command("git", "-C", repoPath, "diff", "--name-only", fmt.Sprintf("%s...%s", baseBranch, branchName))
+// getChangedFilesSince returns the list of files changed in a branch since it forked from base
+func getChangedFilesSince(repoPath, base, branchName string) ([]string, error) {
+	cmd := exec.Command("git", "-C", repoPath, "diff", "--name-only", fmt.Sprintf("%s...%s", base, branchName))
 	output, err := cmd.Output()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get changed files: %w", err)
@@ -112,6 +170,11 @@ func getChangedFilesInBranch(repoPath, branchName string) ([]string, error) {
 // canReuseBranch checks if a branch can be safely reused
 // Returns true if the branch exists and only the same file(s) were changed
 func canReuseBranch(repoPath, branchName string, targetFiles []string) (bool, error) {
+	return canReuseBranchFrom(repoPath, branchName, "", targetFiles)
+}
+
+// canReuseBranchFrom is canReuseBranch comparing the branch with base instead of the default branch
+func canReuseBranchFrom(repoPath, branchName, base string, targetFiles []string) (bool, error) {
 	// Check if branch exists
 	checkCmd := exec.Command("git", "-C", repoPath, "rev-parse", "--verify", branchName)
 	if checkCmd.Run() != nil {
@@ -120,10 +183,18 @@ func canReuseBranch(repoPath, branchName string, targetFiles []string) (bool, er
 	}
 
 	// Branch exists, check what files were changed
-	changedFiles, err := getChangedFilesInBranch(repoPath, branchName)
+	var changedFiles []string
+	var err error
+	if base == "" {
+		changedFiles, err = getChangedFilesInBranch(repoPath, branchName)
+	} else {
+		changedFiles, err = getChangedFilesSince(repoPath, base, branchName)
+	}
 	if err != nil {
 		return false, err
 	}
+	// The lockfile is committed along with the targets
+	changedFiles = slices.DeleteFunc(changedFiles, func(file string) bool { return file == lockFileName })
 
 	// Convert target files to relative paths for comparison
 	targetRelPaths := make([]string, 0, len(targetFiles))
@@ -157,13 +228,20 @@ func canReuseBranch(repoPath, branchName string, targetFiles []string) (bool, er
 	return true, nil
 }
 
-// createWorktreeAndBranch creates a new git worktree with branch
+// createWorktreeAndBranch creates a new git worktree with branch, starting a new branch from
+// the current HEAD. The sync workflow starts from the ref chosen by resolveBaseRef instead.
 func createWorktreeAndBranch(repoPath, branchName string, targetFiles []string) (string, error) {
+	return createWorktreeFromBase(repoPath, branchName, "", targetFiles)
+}
+
+// createWorktreeFromBase creates a new git worktree with branch. A new branch starts from
+// base (HEAD if empty); an existing branch is reused as is if it only changes targetFiles.
+func createWorktreeFromBase(repoPath, branchName, base string, targetFiles []string) (string, error) {
 	// Generate unique worktree path
 	worktreePath := filepath.Join(os.TempDir(), fmt.Sprintf("fmr-worktree-%s", generateWorktreeID()))
 
 	// Validate if branch can be reused
-	canReuse, err := canReuseBranch(repoPath, branchName, targetFiles)
+	canReuse, err := canReuseBranchFrom(repoPath, branchName, base, targetFiles)
 	if err != nil {
 		return "", err
 	}
@@ -180,6 +258,9 @@ func createWorktreeAndBranch(repoPath, branchName string, targetFiles []string)
 	if branchExists {
 		// Branch exists and is safe to reuse, check it out in the worktree
 		cmd = exec.Command("git", "-C", repoPath, "worktree", "add", worktreePath, branchName)
+	} else if base != "" {
+		// Create new branch from base, without tracking it as upstream
+		cmd = exec.Command("git", "-C", repoPath, "worktree", "add", "--no-track", "-b", branchName, worktreePath, base)
 	} else {
 		// Create new branch in worktree
 		cmd = exec.Command("git", "-C", repoPath, "worktree", "add", "-b", branchName, worktreePath)
@@ -269,28 +350,179 @@ func copyFileToWorktree(sourceFilePath, worktreePath, repoRoot string) error {
 	return nil
 }
 
-// performGitWorkflow executes the complete git workflow for changed files
+// writeFileToWorktree writes content to the worktree copy of file, keeping the mode of
+// the committed file. The user's working tree copy of file is never read or written.
+func writeFileToWorktree(file string, content []byte, worktreePath, repoRoot string) error {
+	relPath, err := filepath.Rel(repoRoot, file)
+	if err != nil {
+		return fmt.Errorf("failed to get relative path: %w", err)
+	}
+	targetPath := filepath.Join(worktreePath, relPath)
+
+	mode := os.FileMode(0o644)
+	if info, err := os.Stat(targetPath); err == nil {
+		mode = info.Mode().Perm()
+	} else if err := os.MkdirAll(filepath.Dir(targetPath), 0o750); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	if err := os.WriteFile(targetPath, content, mode); err != nil {
+		return fmt.Errorf("failed to write target: %w", err)
+	}
+	return nil
+}
+
+// defaultGitWorkers is the number of repositories processed at the same time
+const defaultGitWorkers = 4
+
+// performGitWorkflow executes the complete git workflow for changed files. New branches start
+// from each repository's local default branch (see resolveBaseRef), not from the checked-out HEAD.
 func performGitWorkflow(repos map[string][]string, branchName, commitMessage string, shouldPush bool) ([]string, []error) {
+	return performGitWorkflowWithWorkers(repos, branchName, commitMessage, shouldPush, defaultGitWorkers)
+}
+
+// performGitWorkflowWithWorkers executes the git workflow for up to workers repositories at a time.
+// Each repository gets its own worktree; results and errors are ordered by repository path.
+func performGitWorkflowWithWorkers(repos map[string][]string, branchName, commitMessage string, shouldPush bool, workers int) ([]string, []error) {
 	successRepos := make([]string, 0, len(repos))
 	var errors []error
-
-	for repoPath, files := range repos {
-		success, err := processRepo(repoPath, files, branchName, commitMessage, shouldPush)
-		if err != nil {
-			errors = append(errors, err)
+	opts := gitWorkflowOptions{Branch: branchName, Message: commitMessage, Push: shouldPush, Workers: workers}
+	for _, res := range runGitWorkflow(repos, opts) {
+		if res.Err != nil {
+			errors = append(errors, res.Err)
 		}
-		if success {
-			successRepos = append(successRepos, repoPath)
+		if res.Success {
+			successRepos = append(successRepos, res.Repo)
 		}
 	}
 
 	return successRepos, errors
 }
 
-// processRepo processes a single repository
+// repoResult is the outcome of the git workflow in one repository
+type repoResult struct {
+	Repo    string
+	Success bool   // committed, even if pushing or opening the pull request failed
+	PRURL   string // pull request opened for the branch, if requested
+	Err     error
+}
+
+// gitWorkflowOptions configures the git workflow run in every repository
+type gitWorkflowOptions struct {
+	Branch  string
+	Message string
+	Push    bool
+	Workers int       // repositories processed at the same time
+	PR      PROptions // pull requests to open for pushed branches
+	Base    string    // ref new branches start from (see resolveBaseRef; empty: default branch)
+	Fetch   bool      // fetch origin before resolving Base
+
+	// Content, if set, is written to every file in the worktree instead of copying the
+	// file from the user's working tree (git-only mode), rendered per file if Template is set
+	Content  []byte
+	Template *sourceTemplate
+
+	// Lock, if set, is the provenance of the source, recorded for the files in the lockfile
+	// committed in every repository
+	Lock *lockSource
+}
+
+// runGitWorkflow executes the git workflow for up to opts.Workers repositories at a time and
+// opens pull requests for pushed branches if enabled. Results are ordered by repository path.
+func runGitWorkflow(repos map[string][]string, opts gitWorkflowOptions) []repoResult {
+	repoPaths := sortedRepos(repos)
+	results := make([]repoResult, len(repoPaths))
+	forEachConcurrently(len(repoPaths), opts.Workers, func(i int) {
+		results[i] = processRepoWithPR(repoPaths[i], repos[repoPaths[i]], opts, func(string) {})
+	})
+	return results
+}
+
+// processRepoWithPR processes a single repository and opens a pull request once the branch is pushed
+func processRepoWithPR(repoPath string, files []string, opts gitWorkflowOptions, report func(stage string)) repoResult {
+	success, err := processRepoWithProgress(repoPath, files, opts, report)
+	result := repoResult{Repo: repoPath, Success: success, Err: err}
+	if err != nil || !opts.Push || !opts.PR.Enabled {
+		return result
+	}
+
+	// The pull request targets the branch the sync branch started from
+	base, err := resolveBaseRef(repoPath, opts.Base, opts.Fetch)
+	if err != nil {
+		result.Err = fmt.Errorf("repo %s (pull request failed): %w", repoPath, err)
+		return result
+	}
+
+	report("opening pull request")
+	result.PRURL, err = openPullRequest(opts.PR, repoPath, prBaseBranch(repoPath, base), opts.Branch, opts.Message, files)
+	if err != nil {
+		result.Err = fmt.Errorf("repo %s (pull request failed): %w", repoPath, err)
+	}
+	return result
+}
+
+// parseJobs parses the number of repositories to process at the same time
+func parseJobs(value string) (int, error) {
+	jobs, err := strconv.Atoi(value)
+	if err != nil || jobs < 1 {
+		return 0, fmt.Errorf("jobs must be a positive number, got %q", value)
+	}
+	return jobs, nil
+}
+
+// sortedRepos returns the repository paths of repos in sorted order
+func sortedRepos(repos map[string][]string) []string {
+	paths := make([]string, 0, len(repos))
+	for repo := range repos {
+		paths = append(paths, repo)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// forEachConcurrently calls fn for 0..n-1 with at most workers calls running at once
+// and returns when all calls are done. A limit below 1 runs one call at a time.
+func forEachConcurrently(n, workers int, fn func(i int)) {
+	if workers < 1 {
+		workers = 1
+	}
+
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, workers)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			fn(i)
+		}(i)
+	}
+	wg.Wait()
+}
+
+// processRepo processes a single repository, starting a new branch from its default branch
 func processRepo(repoPath string, files []string, branchName, commitMessage string, shouldPush bool) (bool, error) {
+	opts := gitWorkflowOptions{Branch: branchName, Message: commitMessage, Push: shouldPush}
+	return processRepoWithProgress(repoPath, files, opts, func(string) {})
+}
+
+// processRepoWithProgress processes a single repository, calling report with the name of each stage as it starts
+func processRepoWithProgress(repoPath string, files []string, opts gitWorkflowOptions, report func(stage string)) (bool, error) {
+	if opts.Fetch {
+		report("fetching")
+		if err := fetchOrigin(repoPath); err != nil {
+			return false, fmt.Errorf("repo %s: %w", repoPath, err)
+		}
+	}
+	base, err := resolveBaseRef(repoPath, opts.Base, opts.Fetch)
+	if err != nil {
+		return false, fmt.Errorf("repo %s: %w", repoPath, err)
+	}
+
 	// Create worktree with branch validation
-	worktreePath, err := createWorktreeAndBranch(repoPath, branchName, files)
+	report("creating worktree")
+	worktreePath, err := createWorktreeFromBase(repoPath, opts.Branch, base, files)
 	if err != nil {
 		return false, fmt.Errorf("repo %s: %w", repoPath, err)
 	}
@@ -303,24 +535,57 @@ func processRepo(repoPath string, files []string, branchName, commitMessage stri
 		}
 	}()
 
-	// Copy files to worktree
+	// Copy files to worktree, or write the source content in git-only mode
+	report("copying files")
 	for _, file := range files {
-		if err := copyFileToWorktree(file, worktreePath, repoPath); err != nil {
+		var err error
+		if opts.Content != nil {
+			// Managed sections are spliced into the file as committed on the branch
+			current := file
+			if relPath, relErr := filepath.Rel(repoPath, file); relErr == nil {
+				current = filepath.Join(worktreePath, relPath)
+			}
+			var content []byte
+			if content, err = opts.Template.renderOver(opts.Content, file, current); err == nil {
+				err = writeFileToWorktree(file, content, worktreePath, repoPath)
+			}
+		} else {
+			err = copyFileToWorktree(file, worktreePath, repoPath)
+		}
+		if err != nil {
 			return false, fmt.Errorf("repo %s: %w", repoPath, err)
 		}
 	}
+	if err := recordWorktreeLock(opts.Lock, repoPath, worktreePath, files); err != nil {
+		return false, fmt.Errorf("repo %s: failed to update %s: %w", repoPath, lockFileName, err)
+	}
 
 	// Commit changes
-	if err := commitChanges(worktreePath, commitMessage); err != nil {
+	report("committing")
+	if err := commitChanges(worktreePath, opts.Message); err != nil {
 		return false, fmt.Errorf("repo %s: %w", repoPath, err)
 	}
 
 	// Push if requested
-	if shouldPush {
-		if err := pushBranch(worktreePath, branchName); err != nil {
+	if opts.Push {
+		report("pushing")
+		if err := pushBranch(worktreePath, opts.Branch); err != nil {
 			return true, fmt.Errorf("repo %s (push failed): %w", repoPath, err)
 		}
 	}
 
 	return true, nil
 }
+
+// defaultCommitMessage returns the commit message proposed for syncing sourcePath
+// into the given target files
+func defaultCommitMessage(sourcePath string, targets []string) string {
+	var msg strings.Builder
+	msg.WriteString(fmt.Sprintf("chore: Sync %s from source\n\nSynchronized from %s\nTarget files:\n",
+		filepath.Base(sourcePath),
+		sourcePath))
+	for _, target := range targets {
+		msg.WriteString(fmt.Sprintf("- %s\n", target))
+	}
+	return msg.String()
+}
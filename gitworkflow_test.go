@@ -6,8 +6,11 @@ import (
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 // DEVELOPER NOTE: Default Branch Detection in Tests
@@ -2228,3 +2231,226 @@ func TestGetChangedFilesInBranchWithNoChanges(t *testing.T) {
 		t.Errorf("Expected no changed files, got %d", len(files))
 	}
 }
+
+// TestPerformGitWorkflowWithWorkers tests concurrent processing with ordered results
+func TestPerformGitWorkflowWithWorkers(t *testing.T) {
+	repos := make(map[string][]string)
+	var paths []string
+	for i := 0; i < 5; i++ {
+		repo := createTestGitRepo(t)
+		defer os.RemoveAll(repo)
+
+		file := filepath.Join(repo, "shared.txt")
+		if err := os.WriteFile(file, []byte("content"), 0o644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+		repos[repo] = []string{file}
+		paths = append(paths, repo)
+	}
+
+	// One repository already has the branch with an unrelated change, so it must fail
+	failing := paths[2]
+	for _, args := range [][]string{
+		{"checkout", "-q", "-b", "parallel-branch"},
+		{"commit", "-q", "--allow-empty", "-m", "placeholder"},
+	} {
+		if err := exec.Command("git", append([]string{"-C", failing}, args...)...).Run(); err != nil {
+			t.Fatalf("git %v failed: %v", args, err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(failing, "other.txt"), []byte("x"), 0o644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	for _, args := range [][]string{{"add", "other.txt"}, {"commit", "-q", "-m", "unrelated"}, {"checkout", "-q", "main"}} {
+		if err := exec.Command("git", append([]string{"-C", failing}, args...)...).Run(); err != nil {
+			t.Fatalf("git %v failed: %v", args, err)
+		}
+	}
+
+	successRepos, errs := performGitWorkflowWithWorkers(repos, "parallel-branch", "Parallel commit", false, 3)
+
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), failing) {
+		t.Errorf("Expected one error for %s, got %v", failing, errs)
+	}
+	if len(successRepos) != 4 {
+		t.Fatalf("Expected 4 successful repos, got %d", len(successRepos))
+	}
+	if !sort.StringsAreSorted(successRepos) {
+		t.Errorf("Expected results ordered by repository path, got %v", successRepos)
+	}
+	for _, repo := range successRepos {
+		verifyWorktreesCleanedUp(t, repo)
+	}
+}
+
+func TestForEachConcurrently(t *testing.T) {
+	tests := []struct {
+		name    string
+		n       int
+		workers int
+		wantMax int32
+	}{
+		{name: "bounded by workers", n: 20, workers: 3, wantMax: 3},
+		{name: "fewer items than workers", n: 2, workers: 8, wantMax: 2},
+		{name: "invalid limit runs sequentially", n: 5, workers: 0, wantMax: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var running, maxRunning int32
+			done := make([]bool, tt.n)
+
+			forEachConcurrently(tt.n, tt.workers, func(i int) {
+				current := atomic.AddInt32(&running, 1)
+				for {
+					seen := atomic.LoadInt32(&maxRunning)
+					if current <= seen || atomic.CompareAndSwapInt32(&maxRunning, seen, current) {
+						break
+					}
+				}
+				time.Sleep(5 * time.Millisecond)
+				done[i] = true
+				atomic.AddInt32(&running, -1)
+			})
+
+			for i, ok := range done {
+				if !ok {
+					t.Errorf("Item %d was not processed", i)
+				}
+			}
+			if maxRunning > tt.wantMax {
+				t.Errorf("Up to %d calls ran at once, want at most %d", maxRunning, tt.wantMax)
+			}
+		})
+	}
+}
+
+func TestResolveBaseRef(t *testing.T) {
+	repo := createTestGitRepo(t)
+	defer os.RemoveAll(repo)
+	for _, args := range [][]string{{"tag", "v1"}, {"checkout", "-q", "-b", "feature"}} {
+		if output, err := exec.Command("git", append([]string{"-C", repo}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, output)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		base    string
+		remote  bool
+		want    string
+		wantErr bool
+	}{
+		{name: "default branch", base: "", want: "main"},
+		{name: "default branch without a remote copy", base: "", remote: true, want: "main"},
+		{name: "current HEAD", base: "HEAD", want: "HEAD"},
+		{name: "named tag", base: "v1", want: "v1"},
+		{name: "missing ref", base: "release/9", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveBaseRef(repo, tt.base, tt.remote)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveBaseRef() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("resolveBaseRef() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestPerformGitWorkflowIgnoresCheckedOutBranch checks that without a chosen base, the sync
+// branch starts from the default branch rather than the feature branch that is checked out
+func TestPerformGitWorkflowIgnoresCheckedOutBranch(t *testing.T) {
+	repo := createTestGitRepo(t)
+	defer os.RemoveAll(repo)
+	if output, err := exec.Command("git", "-C", repo, "checkout", "-q", "-b", "feature").CombinedOutput(); err != nil {
+		t.Fatalf("git checkout failed: %v\n%s", err, output)
+	}
+	commitFiles(t, repo, map[string]string{"feature.txt": "unrelated work"})
+
+	target := filepath.Join(repo, "target.txt")
+	if err := os.WriteFile(target, []byte("synced"), 0o644); err != nil {
+		t.Fatalf("Failed to write target: %v", err)
+	}
+	if _, errs := performGitWorkflow(map[string][]string{repo: {target}}, "sync-branch", "sync", false); len(errs) > 0 {
+		t.Fatalf("performGitWorkflow failed: %v", errs)
+	}
+
+	output, err := exec.Command("git", "-C", repo, "ls-tree", "--name-only", "sync-branch").Output()
+	if err != nil {
+		t.Fatalf("git ls-tree failed: %v", err)
+	}
+	if got := strings.Join(strings.Fields(string(output)), ","); got != "initial.txt,target.txt" {
+		t.Errorf("Branch files = %s, want initial.txt,target.txt", got)
+	}
+	verifyWorktreesCleanedUp(t, repo)
+}
+
+func TestProcessRepoStartsFromBase(t *testing.T) {
+	origin := createTestGitRepo(t)
+	defer os.RemoveAll(origin)
+	repo := filepath.Join(t.TempDir(), "clone")
+	if output, err := exec.Command("git", "clone", "-q", origin, repo).CombinedOutput(); err != nil {
+		t.Fatalf("git clone failed: %v\n%s", err, output)
+	}
+	for _, args := range [][]string{{"config", "user.email", "test@example.com"}, {"config", "user.name", "Test User"}, {"checkout", "-q", "-b", "feature"}} {
+		if output, err := exec.Command("git", append([]string{"-C", repo}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, output)
+		}
+	}
+	commitFiles(t, repo, map[string]string{"feature.txt": "unrelated work"})
+	// origin moves on after the clone
+	commitFiles(t, origin, map[string]string{"upstream.txt": "newer"})
+
+	target := filepath.Join(repo, "target.txt")
+	if err := os.WriteFile(target, []byte("synced"), 0o644); err != nil {
+		t.Fatalf("Failed to write target: %v", err)
+	}
+
+	// branchFiles lists the files of branch
+	branchFiles := func(branch string) string {
+		output, err := exec.Command("git", "-C", repo, "ls-tree", "--name-only", branch).Output()
+		if err != nil {
+			t.Fatalf("git ls-tree %s failed: %v", branch, err)
+		}
+		return strings.Join(strings.Fields(string(output)), ",")
+	}
+
+	tests := []struct {
+		name string
+		opts gitWorkflowOptions
+		want string
+	}{
+		{name: "default branch", opts: gitWorkflowOptions{Branch: "sync-default"}, want: "initial.txt,target.txt"},
+		{name: "current HEAD", opts: gitWorkflowOptions{Branch: "sync-head", Base: "HEAD"}, want: "feature.txt,initial.txt,target.txt"},
+		{name: "fetched default branch", opts: gitWorkflowOptions{Branch: "sync-fetched", Fetch: true}, want: "initial.txt,target.txt,upstream.txt"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.Message = "sync"
+			var stages []string
+			if _, err := processRepoWithProgress(repo, []string{target}, tt.opts, func(stage string) { stages = append(stages, stage) }); err != nil {
+				t.Fatalf("processRepoWithProgress failed: %v", err)
+			}
+			if got := branchFiles(tt.opts.Branch); got != tt.want {
+				t.Errorf("Branch files = %s, want %s", got, tt.want)
+			}
+			if tt.opts.Fetch && stages[0] != "fetching" {
+				t.Errorf("Stages = %v, want fetching first", stages)
+			}
+		})
+	}
+
+	t.Run("missing base", func(t *testing.T) {
+		_, err := processRepoWithProgress(repo, []string{target}, gitWorkflowOptions{Branch: "sync-missing", Base: "nope", Message: "sync"}, func(string) {})
+		if err == nil || !strings.Contains(err.Error(), `base ref "nope" not found`) {
+			t.Errorf("err = %v, want missing base ref", err)
+		}
+	})
+
+	verifyWorktreesCleanedUp(t, repo)
+}
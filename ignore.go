@@ -0,0 +1,163 @@
+package filemirror
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Files holding gitignore-syntax rules, loaded from every scanned directory
+const (
+	gitignoreFileName = ".gitignore"
+	fmrignoreFileName = ".fmrignore"
+)
+
+// ignoreRule is one pattern line of a gitignore-syntax file
+type ignoreRule struct {
+	base    string // directory of the ignore file; the rule only applies below it
+	pattern string // slash-separated pattern relative to base
+	negate  bool   // "!pattern" re-includes a previously ignored path
+	dirOnly bool   // "pattern/" only matches directories
+}
+
+// ignoreMatcher decides which paths are ignored. Rules are kept in load order,
+// parents before children, so the last matching rule wins like in git.
+// A matcher is not modified once directories below it are being scanned; withDir
+// derives the matcher of a subdirectory, so concurrent scans can share parents.
+type ignoreMatcher struct {
+	rules []ignoreRule
+}
+
+// parseIgnoreRules parses the content of a gitignore-syntax file located in base
+func parseIgnoreRules(data []byte, base string) []ignoreRule {
+	var rules []ignoreRule
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+
+		// Trailing spaces are ignored unless escaped
+		if !strings.HasSuffix(line, "\\ ") {
+			line = strings.TrimRight(line, " ")
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		rule := ignoreRule{base: base}
+		switch {
+		case strings.HasPrefix(line, "!"):
+			rule.negate = true
+			line = line[1:]
+		case strings.HasPrefix(line, `\!`), strings.HasPrefix(line, `\#`):
+			line = line[1:]
+		}
+
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+
+		// A pattern with a slash is relative to base; otherwise it matches at any depth
+		if strings.Contains(line, "/") {
+			line = strings.TrimPrefix(line, "/")
+		} else {
+			line = "**/" + line
+		}
+		rule.pattern = line
+
+		rules = append(rules, rule)
+	}
+
+	return rules
+}
+
+// loadFile adds the rules of an ignore file, if it exists
+func (im *ignoreMatcher) loadFile(path, base string) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return // Missing or unreadable ignore files are skipped
+	}
+	im.rules = append(im.rules, parseIgnoreRules(data, base)...)
+}
+
+// loadDir adds the ignore files found in dir: .git/info/exclude for repository roots,
+// then .gitignore and .fmrignore
+func (im *ignoreMatcher) loadDir(dir string) {
+	if info, err := os.Stat(filepath.Join(dir, ".git")); err == nil && info.IsDir() {
+		im.loadFile(filepath.Join(dir, ".git", "info", "exclude"), dir)
+	}
+	im.loadFile(filepath.Join(dir, gitignoreFileName), dir)
+	im.loadFile(filepath.Join(dir, fmrignoreFileName), dir)
+}
+
+// isRepoRoot reports whether dir has a .git entry: a directory for a repository, or a
+// file for a worktree or submodule
+func isRepoRoot(dir string) bool {
+	_, err := os.Lstat(filepath.Join(dir, ".git"))
+	return err == nil
+}
+
+// withDir returns the matcher for the contents of dir: the receiver's rules followed by
+// the rules of dir's own ignore files. The receiver is returned unchanged if dir has none.
+// A nested repository or worktree does not inherit the rules of the repository around it.
+func (im *ignoreMatcher) withDir(dir string) *ignoreMatcher {
+	child := &ignoreMatcher{}
+	child.loadDir(dir)
+	if isRepoRoot(dir) {
+		return child
+	}
+	if len(child.rules) == 0 {
+		return im
+	}
+
+	rules := make([]ignoreRule, 0, len(im.rules)+len(child.rules))
+	rules = append(rules, im.rules...)
+	return &ignoreMatcher{rules: append(rules, child.rules...)}
+}
+
+// loadParents adds the ignore files of the enclosing git repository above dir,
+// from the repository root down to the parent of dir. Nothing is added if dir is itself
+// the root of a repository or worktree.
+func (im *ignoreMatcher) loadParents(dir string) {
+	if isRepoRoot(dir) {
+		return
+	}
+	var parents []string
+	for current := filepath.Dir(dir); ; current = filepath.Dir(current) {
+		parents = append(parents, current)
+		if isRepoRoot(current) {
+			break // Found the repository root
+		}
+		if filepath.Dir(current) == current {
+			return // dir is not inside a repository
+		}
+	}
+
+	for i := len(parents) - 1; i >= 0; i-- {
+		im.loadDir(parents[i])
+	}
+}
+
+// ignored reports whether the absolute path is ignored by the loaded rules
+func (im *ignoreMatcher) ignored(path string, isDir bool) bool {
+	ignored := false
+	for _, rule := range im.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		rel, err := filepath.Rel(rule.base, path)
+		if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		if matchPathPattern(rule.pattern, filepath.ToSlash(rel)) {
+			ignored = !rule.negate
+		}
+	}
+	return ignored
+}
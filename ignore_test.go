@@ -0,0 +1,186 @@
+package filemirror
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseIgnoreRules(t *testing.T) {
+	data := []byte(`# comment
+
+*.log
+!keep.log
+build/
+/root-only.txt
+docs/*.md
+\#hash
+trailing   
+`)
+
+	rules := parseIgnoreRules(data, "/repo")
+	want := []ignoreRule{
+		{base: "/repo", pattern: "**/*.log"},
+		{base: "/repo", pattern: "**/keep.log", negate: true},
+		{base: "/repo", pattern: "**/build", dirOnly: true},
+		{base: "/repo", pattern: "root-only.txt"},
+		{base: "/repo", pattern: "docs/*.md"},
+		{base: "/repo", pattern: "**/#hash"},
+		{base: "/repo", pattern: "**/trailing"},
+	}
+
+	if len(rules) != len(want) {
+		t.Fatalf("Got %d rules, want %d: %+v", len(rules), len(want), rules)
+	}
+	for i := range want {
+		if rules[i] != want[i] {
+			t.Errorf("rule %d = %+v, want %+v", i, rules[i], want[i])
+		}
+	}
+}
+
+func TestIgnoreMatcher(t *testing.T) {
+	root := filepath.FromSlash("/repo")
+	im := &ignoreMatcher{}
+	im.rules = append(im.rules, parseIgnoreRules([]byte("*.log\n!keep.log\nout/\n/top.txt\n"), root)...)
+	im.rules = append(im.rules, parseIgnoreRules([]byte("!debug.log\nlocal.yaml\n"), filepath.Join(root, "svc"))...)
+
+	tests := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{"app.log", false, true},
+		{"a/b/app.log", false, true},
+		{"keep.log", false, false},
+		{"out", true, true},
+		{"out", false, false}, // dir-only rule does not match files
+		{"top.txt", false, true},
+		{"a/top.txt", false, false}, // anchored to the root
+		{"svc/debug.log", false, false},
+		{"debug.log", false, true}, // nested negation only applies below svc
+		{"svc/local.yaml", false, true},
+		{"other/local.yaml", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			path := filepath.Join(root, filepath.FromSlash(tt.path))
+			if got := im.ignored(path, tt.isDir); got != tt.want {
+				t.Errorf("ignored(%q, %v) = %v, want %v", tt.path, tt.isDir, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScanFilesRespectsIgnoreFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(tmpDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	write(".git/info/exclude", "secret.txt\n")
+	write(".gitignore", "*.gen.go\ngenerated/\n")
+	write(".fmrignore", "fixtures/\n")
+	write("main.go", "")
+	write("api.gen.go", "")
+	write("secret.txt", "")
+	write("generated/types.go", "")
+	write("fixtures/config.yaml", "")
+	write("svc/.gitignore", "!keep.gen.go\n")
+	write("svc/keep.gen.go", "")
+	write("svc/other.gen.go", "")
+
+	files, err := scanFiles(tmpDir, "")
+	if err != nil {
+		t.Fatalf("scanFiles failed: %v", err)
+	}
+
+	found := make(map[string]bool)
+	for _, f := range files {
+		found[filepath.ToSlash(f.Path)] = true
+	}
+
+	for _, want := range []string{"main.go", "svc/keep.gen.go", ".gitignore", ".fmrignore"} {
+		if !found[want] {
+			t.Errorf("Expected %s to be scanned, got %v", want, found)
+		}
+	}
+	for _, unwanted := range []string{"api.gen.go", "secret.txt", "generated/types.go", "fixtures/config.yaml", "svc/other.gen.go"} {
+		if found[unwanted] {
+			t.Errorf("Expected %s to be ignored", unwanted)
+		}
+	}
+
+	// Scanning a subdirectory still applies the repository's ignore files
+	files, err = scanFiles(filepath.Join(tmpDir, "svc"), "")
+	if err != nil {
+		t.Fatalf("scanFiles failed: %v", err)
+	}
+	for _, f := range files {
+		if f.Path == "other.gen.go" {
+			t.Error("Expected root .gitignore to apply when scanning a subdirectory")
+		}
+	}
+}
+
+func TestScanFilesNestedRepository(t *testing.T) {
+	tmpDir := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(tmpDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	write(".git/HEAD", "ref: refs/heads/main\n")
+	write(".gitignore", "*.log\n")
+	write("app.log", "")
+	write("nested/.git/HEAD", "ref: refs/heads/main\n")
+	write("nested/app.log", "")
+	write("worktree/.git", "gitdir: /elsewhere/.git/worktrees/worktree\n")
+	write("worktree/app.log", "")
+	write("worktree/.gitignore", "*.tmp\n")
+	write("worktree/scratch.tmp", "")
+
+	for _, dir := range []string{tmpDir, filepath.Join(tmpDir, "worktree")} {
+		files, err := scanFiles(dir, "")
+		if err != nil {
+			t.Fatalf("scanFiles failed: %v", err)
+		}
+		found := make(map[string]bool)
+		for _, f := range files {
+			found[filepath.ToSlash(f.Path)] = true
+		}
+
+		if dir == tmpDir {
+			if found["app.log"] {
+				t.Error("Expected app.log to be ignored by the root .gitignore")
+			}
+			for _, want := range []string{"nested/app.log", "worktree/app.log"} {
+				if !found[want] {
+					t.Errorf("Expected %s in a nested repository to be scanned, got %v", want, found)
+				}
+			}
+			if found["worktree/scratch.tmp"] {
+				t.Error("Expected the worktree's own .gitignore to apply")
+			}
+			continue
+		}
+		// Scanning a worktree directly does not load the enclosing repository's rules
+		if !found["app.log"] || found["scratch.tmp"] {
+			t.Errorf("Expected app.log scanned and scratch.tmp ignored in the worktree, got %v", found)
+		}
+	}
+}
@@ -0,0 +1,496 @@
+package filemirror
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// journalFile is the name of the run record inside a journal directory
+const journalFile = "journal.json"
+
+// journal records the original state of every target a sync run overwrites,
+// so the run can be undone even for files outside git.
+// A journal is written to disk with its first entry; runs that change nothing leave no trace.
+type journal struct {
+	ID       string         `json:"id"`
+	Started  time.Time      `json:"started"`
+	WorkDir  string         `json:"work_dir"`
+	Entries  []journalEntry `json:"entries"`
+	UndoneAt *time.Time     `json:"undone_at,omitempty"`
+
+	dir string // run directory; the original contents are stored next to journalFile
+}
+
+// journalEntry is the state of one target before the run overwrote it
+type journalEntry struct {
+	Path    string      `json:"path"`
+	Source  string      `json:"source"`
+	Existed bool        `json:"existed"`
+	Mode    os.FileMode `json:"mode,omitempty"`
+	Hash    string      `json:"hash,omitempty"`   // SHA-256 of the original content
+	Backup  string      `json:"backup,omitempty"` // file holding the original content, relative to the run directory
+	NewHash string      `json:"new_hash"`         // SHA-256 of the content the run wrote; empty until written
+}
+
+// undoOutcome is what undo did, or would have to do, for one target
+type undoOutcome int
+
+const (
+	undoRestored  undoOutcome = iota // original content and mode written back
+	undoRemoved                      // target did not exist before the run
+	undoUnchanged                    // target already has its original content
+	undoConflict                     // target changed after the run
+	undoFailed
+)
+
+// undoResult is the outcome of undoing one journal entry
+type undoResult struct {
+	Path    string
+	Outcome undoOutcome
+	Err     error
+}
+
+// stateDir returns the directory fmr keeps its state in:
+// $XDG_STATE_HOME/fmr, or ~/.local/state/fmr
+func stateDir() (string, error) {
+	if dir := os.Getenv("XDG_STATE_HOME"); dir != "" {
+		return filepath.Join(dir, "fmr"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("cannot locate the state directory: %w", err)
+	}
+	return filepath.Join(home, ".local", "state", "fmr"), nil
+}
+
+// journalsDir returns the directory holding one subdirectory per journaled run
+func journalsDir() (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "runs"), nil
+}
+
+// newJournal starts the journal of a run. Nothing is written until the first backup.
+func newJournal(workDir string) *journal {
+	return &journal{Started: time.Now(), WorkDir: workDir}
+}
+
+// backup records the current state of target before it is overwritten; written records
+// the new content once the write succeeded. A target backed up twice in one run keeps its
+// first original. A nil journal does nothing.
+func (j *journal) backup(target, source string) error {
+	if j == nil {
+		return nil
+	}
+	for i := range j.Entries {
+		if j.Entries[i].Path == target {
+			return nil
+		}
+	}
+
+	if err := j.create(); err != nil {
+		return err
+	}
+
+	entry := journalEntry{Path: target, Source: source}
+	info, err := os.Stat(target)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return fmt.Errorf("failed to journal %s: %w", target, err)
+	default:
+		original, err := os.ReadFile(target)
+		if err != nil {
+			return fmt.Errorf("failed to journal %s: %w", target, err)
+		}
+		entry.Existed = true
+		entry.Mode = info.Mode().Perm()
+		entry.Hash = hashBytes(original)
+		entry.Backup = fmt.Sprintf("%04d.orig", len(j.Entries)+1)
+		if err := os.WriteFile(filepath.Join(j.dir, entry.Backup), original, 0o600); err != nil {
+			return fmt.Errorf("failed to journal %s: %w", target, err)
+		}
+	}
+
+	j.Entries = append(j.Entries, entry)
+	return j.save()
+}
+
+// written records content as written to target, which must have been backed up.
+// A target whose write failed keeps its previous new content, if any.
+func (j *journal) written(target string, content []byte) error {
+	if j == nil {
+		return nil
+	}
+	for i := range j.Entries {
+		if j.Entries[i].Path == target {
+			j.Entries[i].NewHash = hashBytes(content)
+			return j.save()
+		}
+	}
+	return fmt.Errorf("failed to journal %s: not backed up", target)
+}
+
+// create allocates the run directory on first use. Run IDs are timestamps,
+// suffixed with a counter when several runs start within the same second.
+func (j *journal) create() error {
+	if j.dir != "" {
+		return nil
+	}
+
+	root, err := journalsDir()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(root, 0o700); err != nil {
+		return fmt.Errorf("failed to create journal directory: %w", err)
+	}
+
+	base := j.Started.Format("20060102-150405")
+	for n := 1; ; n++ {
+		id := base
+		if n > 1 {
+			id = fmt.Sprintf("%s-%d", base, n)
+		}
+		err := os.Mkdir(filepath.Join(root, id), 0o700)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to create journal directory: %w", err)
+		}
+		j.ID = id
+		j.dir = filepath.Join(root, id)
+		return nil
+	}
+}
+
+// save writes the run record, replacing the previous one atomically
+func (j *journal) save() error {
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode journal: %w", err)
+	}
+	if err := writeFileAtomic(filepath.Join(j.dir, journalFile), data, 0o600); err != nil {
+		return fmt.Errorf("failed to write journal: %w", err)
+	}
+	return nil
+}
+
+// loadJournal reads the journal of the run with the given ID
+func loadJournal(id string) (*journal, error) {
+	root, err := journalsDir()
+	if err != nil {
+		return nil, err
+	}
+	if id == "" || strings.ContainsAny(id, `/\`) || id == "." || id == ".." {
+		return nil, fmt.Errorf("invalid run ID %q", id)
+	}
+
+	dir := filepath.Join(root, id)
+	data, err := os.ReadFile(filepath.Join(dir, journalFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("no run with ID %q (see 'fmr history')", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read journal: %w", err)
+	}
+
+	var j journal
+	if err := json.Unmarshal(data, &j); err != nil {
+		return nil, fmt.Errorf("invalid journal %s: %w", id, err)
+	}
+	j.dir = dir
+	return &j, nil
+}
+
+// listJournals returns every journaled run, newest first. Unreadable runs are skipped.
+func listJournals() ([]*journal, error) {
+	root, err := journalsDir()
+	if err != nil {
+		return nil, err
+	}
+	dirs, err := os.ReadDir(root)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read journal directory: %w", err)
+	}
+
+	var journals []*journal
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+		j, err := loadJournal(dir.Name())
+		if err != nil {
+			continue
+		}
+		journals = append(journals, j)
+	}
+
+	sort.SliceStable(journals, func(a, b int) bool {
+		if !journals[a].Started.Equal(journals[b].Started) {
+			return journals[a].Started.After(journals[b].Started)
+		}
+		return journals[a].ID > journals[b].ID
+	})
+	return journals, nil
+}
+
+// undo restores every target of the run, newest entry first. Targets changed since the
+// run are left alone unless force is set. The run is marked undone if nothing failed.
+func (j *journal) undo(force bool) []undoResult {
+	results := make([]undoResult, 0, len(j.Entries))
+	clean := true
+	for i := len(j.Entries) - 1; i >= 0; i-- {
+		result := j.undoEntry(j.Entries[i], force)
+		if result.Outcome == undoConflict || result.Outcome == undoFailed {
+			clean = false
+		}
+		results = append(results, result)
+	}
+
+	if clean {
+		now := time.Now()
+		j.UndoneAt = &now
+		if err := j.save(); err != nil {
+			results = append(results, undoResult{Path: filepath.Join(j.dir, journalFile), Outcome: undoFailed, Err: err})
+		}
+	}
+	return results
+}
+
+// undoEntry puts one target back into the state recorded in entry
+func (j *journal) undoEntry(entry journalEntry, force bool) undoResult {
+	result := undoResult{Path: entry.Path}
+
+	current, err := os.ReadFile(entry.Path)
+	exists := err == nil
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		result.Outcome = undoFailed
+		result.Err = err
+		return result
+	}
+
+	switch {
+	case !entry.Existed && !exists, exists && entry.Existed && hashBytes(current) == entry.Hash:
+		result.Outcome = undoUnchanged
+		return result
+	case !force && (!exists || hashBytes(current) != entry.NewHash):
+		result.Outcome = undoConflict
+		result.Err = errors.New("changed since the run (use --force to restore anyway)")
+		return result
+	}
+
+	if !entry.Existed {
+		if err := os.Remove(entry.Path); err != nil {
+			result.Outcome = undoFailed
+			result.Err = err
+			return result
+		}
+		result.Outcome = undoRemoved
+		return result
+	}
+
+	original, err := os.ReadFile(filepath.Join(j.dir, entry.Backup))
+	if err == nil && hashBytes(original) != entry.Hash {
+		err = errors.New("backup does not match the recorded hash")
+	}
+	if err == nil {
+		err = writeFileAtomic(entry.Path, original, entry.Mode)
+	}
+	if err != nil {
+		result.Outcome = undoFailed
+		result.Err = fmt.Errorf("failed to restore: %w", err)
+		return result
+	}
+	result.Outcome = undoRestored
+	return result
+}
+
+// sources returns the distinct sources of the run in the order they were synced
+func (j *journal) sources() []string {
+	var sources []string
+	seen := make(map[string]bool)
+	for _, entry := range j.Entries {
+		if !seen[entry.Source] {
+			seen[entry.Source] = true
+			sources = append(sources, entry.Source)
+		}
+	}
+	return sources
+}
+
+// runUndo runs the undo command and returns an exit code
+func runUndo(args []string, stdout, stderr io.Writer) int {
+	var id string
+	force := false
+	for _, arg := range args {
+		switch {
+		case arg == "-h" || arg == "--help":
+			printUndoHelp(stdout)
+			return 0
+		case arg == "-f" || arg == "--force":
+			force = true
+		case strings.HasPrefix(arg, "-") || id != "":
+			_, _ = fmt.Fprintf(stderr, "Error: unknown argument %q\n", arg) //nolint:errcheck // Error writing to stderr is not actionable
+			_, _ = fmt.Fprintln(stderr, "Run 'fmr undo --help' for usage.") //nolint:errcheck // Error writing to stderr is not actionable
+			return 1
+		default:
+			id = arg
+		}
+	}
+
+	j, err := undoTarget(id, force)
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "Error: %v\n", err) //nolint:errcheck // Error writing to stderr is not actionable
+		return 1
+	}
+
+	w := func(format string, a ...any) {
+		_, _ = fmt.Fprintf(stdout, format, a...) //nolint:errcheck // Error writing to stdout is not actionable
+	}
+
+	w("Undoing run %s (%s)\n\n", j.ID, j.Started.Format("2006-01-02 15:04:05"))
+	failed := 0
+	for _, result := range j.undo(force) {
+		rel := relativeTo(j.WorkDir, result.Path)
+		switch result.Outcome {
+		case undoRestored:
+			w("✓ %s restored\n", rel)
+		case undoRemoved:
+			w("✓ %s removed (did not exist before)\n", rel)
+		case undoUnchanged:
+			w("• %s already has its original content\n", rel)
+		default:
+			failed++
+			w("✗ %s: %v\n", rel, result.Err)
+		}
+	}
+
+	if failed > 0 {
+		w("\n%d target(s) were not restored; run %s stays undoable\n", failed, j.ID)
+		return 1
+	}
+	w("\nRun %s undone\n", j.ID)
+	return 0
+}
+
+// undoTarget returns the run to undo: the one with the given ID, or the newest run
+// that has not been undone yet
+func undoTarget(id string, force bool) (*journal, error) {
+	if id != "" {
+		j, err := loadJournal(id)
+		if err != nil {
+			return nil, err
+		}
+		if j.UndoneAt != nil && !force {
+			return nil, fmt.Errorf("run %s was already undone at %s (use --force to restore it again)", id, j.UndoneAt.Format("2006-01-02 15:04:05"))
+		}
+		return j, nil
+	}
+
+	journals, err := listJournals()
+	if err != nil {
+		return nil, err
+	}
+	for _, j := range journals {
+		if j.UndoneAt == nil {
+			return j, nil
+		}
+	}
+	return nil, errors.New("no run to undo (see 'fmr history')")
+}
+
+// runHistory runs the history command and returns an exit code
+func runHistory(args []string, stdout, stderr io.Writer) int {
+	for _, arg := range args {
+		if arg == "-h" || arg == "--help" {
+			printHistoryHelp(stdout)
+			return 0
+		}
+		_, _ = fmt.Fprintf(stderr, "Error: unknown argument %q\n", arg)    //nolint:errcheck // Error writing to stderr is not actionable
+		_, _ = fmt.Fprintln(stderr, "Run 'fmr history --help' for usage.") //nolint:errcheck // Error writing to stderr is not actionable
+		return 1
+	}
+
+	journals, err := listJournals()
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "Error: %v\n", err) //nolint:errcheck // Error writing to stderr is not actionable
+		return 1
+	}
+
+	w := func(format string, a ...any) {
+		_, _ = fmt.Fprintf(stdout, format, a...) //nolint:errcheck // Error writing to stdout is not actionable
+	}
+
+	if len(journals) == 0 {
+		w("No sync runs recorded yet\n")
+		return 0
+	}
+
+	for _, j := range journals {
+		status := ""
+		if j.UndoneAt != nil {
+			status = "  (undone)"
+		}
+		w("%-20s  %s  %d target(s)%s\n", j.ID, j.Started.Format("2006-01-02 15:04"), len(j.Entries), status)
+		for _, source := range j.sources() {
+			w("    from %s\n", relativeTo(j.WorkDir, source))
+		}
+	}
+	return 0
+}
+
+// printUndoHelp displays the help message for the undo command
+func printUndoHelp(w io.Writer) {
+	help := `fmr undo - Restore the targets of a previous sync run
+
+USAGE:
+    fmr undo [RUN-ID] [OPTIONS]
+
+DESCRIPTION:
+    Every sync records the original content, mode and hash of the targets
+    it overwrites in ~/.local/state/fmr ($XDG_STATE_HOME/fmr if set).
+    Undo writes them back and removes targets the run created. Without a
+    RUN-ID the newest run that was not undone yet is restored.
+
+    Targets that changed after the run are left alone unless --force is given.
+
+OPTIONS:
+    -f, --force   Restore targets that changed since the run, and runs already undone
+    -h, --help    Show this help message
+
+EXAMPLES:
+    fmr history
+    fmr undo
+    fmr undo 20261016-153012
+`
+	_, _ = fmt.Fprint(w, help) //nolint:errcheck // Error writing to writer is not actionable
+}
+
+// printHistoryHelp displays the help message for the history command
+func printHistoryHelp(w io.Writer) {
+	help := `fmr history - List previous sync runs that can be undone
+
+USAGE:
+    fmr history
+
+DESCRIPTION:
+    Lists the journaled sync runs, newest first, with their run ID, start
+    time, number of targets and sources. Pass a run ID to 'fmr undo' to
+    restore its targets.
+`
+	_, _ = fmt.Fprint(w, help) //nolint:errcheck // Error writing to writer is not actionable
+}
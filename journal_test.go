@@ -0,0 +1,220 @@
+package filemirror
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestJournalUndo(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	tmpDir := t.TempDir()
+
+	source := filepath.Join(tmpDir, "source.txt")
+	existing := filepath.Join(tmpDir, "existing.sh")
+	created := filepath.Join(tmpDir, "created.txt")
+	edited := filepath.Join(tmpDir, "edited.txt")
+	if err := os.WriteFile(source, []byte("new\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write source: %v", err)
+	}
+	if err := os.WriteFile(existing, []byte("original\n"), 0o755); err != nil {
+		t.Fatalf("Failed to write target: %v", err)
+	}
+	if err := os.WriteFile(edited, []byte("original\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write target: %v", err)
+	}
+
+	j := newJournal(tmpDir)
+	for _, target := range []string{existing, created, edited} {
+		if _, err := copyToTarget(source, []byte("new\n"), target, nil, j); err != nil {
+			t.Fatalf("copyToTarget(%s) failed: %v", target, err)
+		}
+	}
+	if j.ID == "" {
+		t.Fatal("Expected the journal to be written")
+	}
+	if err := os.WriteFile(edited, []byte("edited after the run\n"), 0o644); err != nil {
+		t.Fatalf("Failed to edit target: %v", err)
+	}
+
+	// The journal on disk has the originals
+	loaded, err := loadJournal(j.ID)
+	if err != nil {
+		t.Fatalf("loadJournal failed: %v", err)
+	}
+	if len(loaded.Entries) != 3 || !loaded.Entries[0].Existed || loaded.Entries[1].Existed {
+		t.Fatalf("Unexpected entries: %+v", loaded.Entries)
+	}
+	if loaded.Entries[0].Hash != hashBytes([]byte("original\n")) || loaded.Entries[0].Mode != 0o755 {
+		t.Errorf("Entry = %+v, want original hash and mode", loaded.Entries[0])
+	}
+
+	// Without force the edited target is a conflict and the run stays undoable
+	outcomes := make(map[string]undoOutcome)
+	for _, result := range loaded.undo(false) {
+		outcomes[result.Path] = result.Outcome
+	}
+	want := map[string]undoOutcome{existing: undoRestored, created: undoRemoved, edited: undoConflict}
+	for path, outcome := range want {
+		if outcomes[path] != outcome {
+			t.Errorf("%s outcome = %v, want %v", filepath.Base(path), outcomes[path], outcome)
+		}
+	}
+	if loaded.UndoneAt != nil {
+		t.Error("Expected a run with conflicts not to be marked undone")
+	}
+
+	content, _ := os.ReadFile(existing)
+	info, _ := os.Stat(existing)
+	if string(content) != "original\n" || info.Mode().Perm() != 0o755 {
+		t.Errorf("existing = %q (%v), want original content and mode", content, info.Mode().Perm())
+	}
+	if _, err := os.Stat(created); !os.IsNotExist(err) {
+		t.Error("Expected the created target to be removed")
+	}
+
+	// Forcing restores the edited target; the others are already back
+	outcomes = make(map[string]undoOutcome)
+	for _, result := range loaded.undo(true) {
+		outcomes[result.Path] = result.Outcome
+	}
+	want = map[string]undoOutcome{existing: undoUnchanged, created: undoUnchanged, edited: undoRestored}
+	for path, outcome := range want {
+		if outcomes[path] != outcome {
+			t.Errorf("forced %s outcome = %v, want %v", filepath.Base(path), outcomes[path], outcome)
+		}
+	}
+	if reloaded, _ := loadJournal(j.ID); reloaded == nil || reloaded.UndoneAt == nil {
+		t.Error("Expected the run to be marked undone")
+	}
+}
+
+func TestJournalKeepsFirstOriginal(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	target := filepath.Join(t.TempDir(), "target.txt")
+	if err := os.WriteFile(target, []byte("original"), 0o644); err != nil {
+		t.Fatalf("Failed to write target: %v", err)
+	}
+
+	j := newJournal(filepath.Dir(target))
+	for _, content := range []string{"first", "second"} {
+		if err := j.backup(target, "source"); err != nil {
+			t.Fatalf("backup failed: %v", err)
+		}
+		if err := os.WriteFile(target, []byte(content), 0o644); err != nil {
+			t.Fatalf("Failed to write target: %v", err)
+		}
+		if err := j.written(target, []byte(content)); err != nil {
+			t.Fatalf("written failed: %v", err)
+		}
+	}
+
+	if len(j.Entries) != 1 {
+		t.Fatalf("Expected 1 entry, got %d", len(j.Entries))
+	}
+	if j.Entries[0].Hash != hashBytes([]byte("original")) || j.Entries[0].NewHash != hashBytes([]byte("second")) {
+		t.Errorf("Entry = %+v, want first original and last written content", j.Entries[0])
+	}
+}
+
+func TestJournalFailedWrite(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	dir := t.TempDir()
+	source := filepath.Join(dir, "source.txt")
+	target := filepath.Join(dir, "locked", "target.txt")
+	if err := os.WriteFile(source, []byte("new"), 0o644); err != nil {
+		t.Fatalf("Failed to write source: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+	if err := os.WriteFile(target, []byte("original"), 0o444); err != nil {
+		t.Fatalf("Failed to write target: %v", err)
+	}
+	if err := os.Chmod(filepath.Dir(target), 0o555); err != nil {
+		t.Fatalf("Failed to lock dir: %v", err)
+	}
+	defer os.Chmod(filepath.Dir(target), 0o755) //nolint:errcheck // Best effort so the temp dir can be removed
+
+	j := newJournal(dir)
+	if err := mirrorTarget(source, []byte("new"), target, &sourceTemplate{name: "source.txt"}, j); err == nil {
+		t.Skip("Target could be written despite its permissions")
+	}
+	if len(j.Entries) != 1 || j.Entries[0].NewHash != "" {
+		t.Fatalf("Entries = %+v, want one entry without new content", j.Entries)
+	}
+
+	// The unwritten target is reported unchanged, not as changed since the run
+	if results := j.undo(false); len(results) != 1 || results[0].Outcome != undoUnchanged {
+		t.Errorf("undo() = %+v, want the target unchanged", results)
+	}
+}
+
+func TestUndoAndHistoryCommands(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	tmpDir := t.TempDir()
+
+	source := filepath.Join(tmpDir, "source.txt")
+	target := filepath.Join(tmpDir, "target.txt")
+	if err := os.WriteFile(source, []byte("new"), 0o644); err != nil {
+		t.Fatalf("Failed to write source: %v", err)
+	}
+	if err := os.WriteFile(target, []byte("old"), 0o644); err != nil {
+		t.Fatalf("Failed to write target: %v", err)
+	}
+
+	run := func(args ...string) (int, string, string) {
+		var stdout, stderr bytes.Buffer
+		code := RunWithArgs(args, &stdout, &stderr)
+		return code, stdout.String(), stderr.String()
+	}
+
+	// Nothing to undo yet
+	if code, out, _ := run("history"); code != 0 || !strings.Contains(out, "No sync runs") {
+		t.Errorf("history = %d %q", code, out)
+	}
+	if code, _, errOut := run("undo"); code != 1 || !strings.Contains(errOut, "no run to undo") {
+		t.Errorf("undo = %d %q", code, errOut)
+	}
+
+	// Dry runs are not journaled
+	if code, out, _ := run("sync", "-p", tmpDir, "-s", "source.txt", "-t", "target.txt", "--dry-run"); code != 0 || strings.Contains(out, "fmr undo") {
+		t.Errorf("dry run = %d %q", code, out)
+	}
+
+	code, out, _ := run("sync", "-p", tmpDir, "-s", "source.txt", "-t", "target.txt")
+	if code != 0 || !strings.Contains(out, "Undo with: fmr undo ") {
+		t.Fatalf("sync = %d %q", code, out)
+	}
+	journals, err := listJournals()
+	if err != nil || len(journals) != 1 {
+		t.Fatalf("listJournals() = %d runs, err = %v", len(journals), err)
+	}
+	id := journals[0].ID
+
+	code, out, _ = run("history")
+	if code != 0 || !strings.Contains(out, id) || !strings.Contains(out, "1 target(s)") || !strings.Contains(out, "from source.txt") {
+		t.Errorf("history = %d %q", code, out)
+	}
+
+	code, out, _ = run("undo")
+	if code != 0 || !strings.Contains(out, "✓ target.txt restored") {
+		t.Errorf("undo = %d %q", code, out)
+	}
+	if content, _ := os.ReadFile(target); string(content) != "old" {
+		t.Errorf("target = %q, want restored content", content)
+	}
+
+	// The run is undone now
+	if code, out, _ = run("history"); !strings.Contains(out, "(undone)") {
+		t.Errorf("history = %d %q", code, out)
+	}
+	if code, _, errOut := run("undo", id); code != 1 || !strings.Contains(errOut, "already undone") {
+		t.Errorf("undo again = %d %q", code, errOut)
+	}
+	if code, _, errOut := run("undo", "../etc"); code != 1 || !strings.Contains(errOut, "invalid run ID") {
+		t.Errorf("undo ../etc = %d %q", code, errOut)
+	}
+}
@@ -0,0 +1,158 @@
+package filemirror
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// lockFileName is the lockfile in the root of every target repository that records where
+// its mirrored files come from
+const lockFileName = ".fmr.lock"
+
+// lockHeader starts every lockfile written
+const lockHeader = "# Written by fmr: the source of every mirrored file in this repository.\n# Run 'fmr status' to find the files that are behind their source.\n"
+
+// lockEntry records the provenance of one mirrored file
+type lockEntry struct {
+	Path         string `yaml:"path"`                    // target, relative to the repository root
+	SourceRepo   string `yaml:"source_repo"`             // root of the source repository, relative to the repository root
+	SourcePath   string `yaml:"source_path"`             // source, relative to the source repository root
+	SourceCommit string `yaml:"source_commit,omitempty"` // HEAD of the source repository when synced, if in git
+	SourceHash   string `yaml:"source_hash"`             // SHA-256 of the source when synced
+	Hash         string `yaml:"hash"`                    // SHA-256 of the target as written
+}
+
+// lockFile is the content of a lockfile
+type lockFile struct {
+	Files []lockEntry `yaml:"files"`
+}
+
+// lockSource is the provenance of a sync's source, recorded for each of its targets
+type lockSource struct {
+	path   string // absolute source path
+	root   string // root of the source's git repository, or its directory outside git
+	commit string // HEAD of the source repository, if any
+	hash   string // SHA-256 of the source content
+}
+
+// newLockSource collects the provenance of source
+func newLockSource(source string) (*lockSource, error) {
+	path, err := filepath.Abs(source)
+	if err != nil {
+		return nil, err
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read source: %w", err)
+	}
+
+	s := &lockSource{path: path, root: filepath.Dir(path), hash: hashBytes(content)}
+	if root, err := detectGitRoot(path); err == nil {
+		s.root = root
+		if output, err := exec.Command("git", "-C", root, "rev-parse", "HEAD").Output(); err == nil {
+			s.commit = strings.TrimSpace(string(output))
+		}
+	}
+	return s, nil
+}
+
+// entry returns the lock entry of target, as found at written, in the repository at repoRoot
+func (s *lockSource) entry(repoRoot, target, written string) (lockEntry, error) {
+	content, err := os.ReadFile(written)
+	if err != nil {
+		return lockEntry{}, fmt.Errorf("failed to read %s: %w", target, err)
+	}
+	relTarget, err := filepath.Rel(repoRoot, target)
+	if err != nil {
+		return lockEntry{}, err
+	}
+	relRepo, err := filepath.Rel(repoRoot, s.root)
+	if err != nil {
+		return lockEntry{}, err
+	}
+	relSource, err := filepath.Rel(s.root, s.path)
+	if err != nil {
+		return lockEntry{}, err
+	}
+	return lockEntry{
+		Path:         filepath.ToSlash(relTarget),
+		SourceRepo:   filepath.ToSlash(relRepo),
+		SourcePath:   filepath.ToSlash(relSource),
+		SourceCommit: s.commit,
+		SourceHash:   s.hash,
+		Hash:         hashBytes(content),
+	}, nil
+}
+
+// readLockFile reads the lockfile at path; a missing lockfile has no entries
+func readLockFile(path string) (lockFile, error) {
+	var lock lockFile
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return lock, nil
+	}
+	if err != nil {
+		return lock, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if err := yaml.Unmarshal(data, &lock); err != nil {
+		return lock, fmt.Errorf("invalid lockfile %s: %w", path, err)
+	}
+	return lock, nil
+}
+
+// updateLockFile adds entries to the lockfile at path, replacing those of the same targets,
+// and keeps the entries sorted by target
+func updateLockFile(path string, entries []lockEntry) error {
+	lock, err := readLockFile(path)
+	if err != nil {
+		return err
+	}
+	byPath := make(map[string]lockEntry, len(lock.Files)+len(entries))
+	for _, entry := range lock.Files {
+		byPath[entry.Path] = entry
+	}
+	for _, entry := range entries {
+		byPath[entry.Path] = entry
+	}
+	lock.Files = lock.Files[:0]
+	for _, entry := range byPath {
+		lock.Files = append(lock.Files, entry)
+	}
+	sort.Slice(lock.Files, func(a, b int) bool { return lock.Files[a].Path < lock.Files[b].Path })
+
+	data, err := yaml.Marshal(lock)
+	if err != nil {
+		return fmt.Errorf("failed to encode lockfile: %w", err)
+	}
+	data = append([]byte(lockHeader), data...)
+	return writeFileAtomic(path, data, 0o644)
+}
+
+// recordWorktreeLock updates the lockfile in the worktree of the repository at repoPath
+// with the provenance of files as written to the worktree, so the commit includes it.
+// The lockfile is only written by the git workflow, never into the user's working tree.
+func recordWorktreeLock(s *lockSource, repoPath, worktreePath string, files []string) error {
+	if s == nil {
+		return nil
+	}
+	entries := make([]lockEntry, 0, len(files))
+	for _, file := range files {
+		relPath, err := filepath.Rel(repoPath, file)
+		if err != nil {
+			return fmt.Errorf("failed to get relative path: %w", err)
+		}
+		entry, err := s.entry(repoPath, file, filepath.Join(worktreePath, relPath))
+		if err != nil {
+			return err
+		}
+		entries = append(entries, entry)
+	}
+	return updateLockFile(filepath.Join(worktreePath, lockFileName), entries)
+}
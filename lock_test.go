@@ -0,0 +1,124 @@
+package filemirror
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestUpdateLockFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), lockFileName)
+
+	first := []lockEntry{
+		{Path: "b.txt", SourceRepo: "../src", SourcePath: "b.txt", SourceHash: "s1", Hash: "h1"},
+		{Path: "a.txt", SourceRepo: "../src", SourcePath: "a.txt", SourceHash: "s1", Hash: "h1"},
+	}
+	if err := updateLockFile(path, first); err != nil {
+		t.Fatalf("updateLockFile() error = %v", err)
+	}
+	if err := updateLockFile(path, []lockEntry{{Path: "b.txt", SourceRepo: "../src", SourcePath: "b.txt", SourceHash: "s2", Hash: "h2"}}); err != nil {
+		t.Fatalf("updateLockFile() error = %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read lockfile: %v", err)
+	}
+	if !strings.HasPrefix(string(data), lockHeader) {
+		t.Errorf("Lockfile does not start with its header:\n%s", data)
+	}
+	lock, err := readLockFile(path)
+	if err != nil {
+		t.Fatalf("readLockFile() error = %v", err)
+	}
+	if len(lock.Files) != 2 || lock.Files[0].Path != "a.txt" || lock.Files[1].Path != "b.txt" {
+		t.Fatalf("Lockfile entries = %+v, want a.txt and b.txt", lock.Files)
+	}
+	if lock.Files[1].Hash != "h2" || lock.Files[1].SourceHash != "s2" {
+		t.Errorf("b.txt = %+v, want it replaced", lock.Files[1])
+	}
+}
+
+func TestRunSyncLockfile(t *testing.T) {
+	sourceRepo := createTestGitRepo(t)
+	defer os.RemoveAll(sourceRepo)
+	targetRepo := createTestGitRepo(t)
+	defer os.RemoveAll(targetRepo)
+	commitFiles(t, sourceRepo, map[string]string{"config.yaml": "level: info\n"})
+	commitFiles(t, targetRepo, map[string]string{"config.yaml": "old\n"})
+	head, err := exec.Command("git", "-C", sourceRepo, "rev-parse", "HEAD").Output()
+	if err != nil {
+		t.Fatalf("git rev-parse failed: %v", err)
+	}
+
+	source := filepath.Join(sourceRepo, "config.yaml")
+	target := filepath.Join(targetRepo, "config.yaml")
+	args := []string{"-s", source, "-t", target, "--branch", "chore/sync-config"}
+	for i := 0; i < 2; i++ {
+		// The second run reuses the branch, which changes the lockfile besides the target
+		var stdout, stderr bytes.Buffer
+		if code := runSync(args, &stdout, &stderr); code != 0 {
+			t.Fatalf("Exit code = %d\nstdout: %s\nstderr: %s", code, stdout.String(), stderr.String())
+		}
+	}
+
+	relRepo, err := filepath.Rel(targetRepo, sourceRepo)
+	if err != nil {
+		t.Fatalf("Failed to get relative path: %v", err)
+	}
+	want := lockEntry{
+		Path:         "config.yaml",
+		SourceRepo:   filepath.ToSlash(relRepo),
+		SourcePath:   "config.yaml",
+		SourceCommit: strings.TrimSpace(string(head)),
+		SourceHash:   hashBytes([]byte("level: info\n")),
+		Hash:         hashBytes([]byte("level: info\n")),
+	}
+
+	// The lockfile is committed along with the target
+	committed, err := exec.Command("git", "-C", targetRepo, "show", "chore/sync-config:"+lockFileName).Output()
+	if err != nil {
+		t.Fatalf("Expected the lockfile on the branch: %v", err)
+	}
+	var lock lockFile
+	if err := yaml.Unmarshal(committed, &lock); err != nil || len(lock.Files) != 1 || lock.Files[0] != want {
+		t.Errorf("Committed lockfile = %+v, %v, want %+v", lock.Files, err, want)
+	}
+	if _, err := os.Stat(filepath.Join(targetRepo, lockFileName)); !os.IsNotExist(err) {
+		t.Errorf("Expected no lockfile in the working tree, got %v", err)
+	}
+
+	verifyWorktreesCleanedUp(t, targetRepo)
+}
+
+func TestRunSyncWithoutGitLeavesNoLockfile(t *testing.T) {
+	sourceRepo := createTestGitRepo(t)
+	defer os.RemoveAll(sourceRepo)
+	targetRepo := createTestGitRepo(t)
+	defer os.RemoveAll(targetRepo)
+	commitFiles(t, sourceRepo, map[string]string{"config.yaml": "level: info\n"})
+	commitFiles(t, targetRepo, map[string]string{"config.yaml": "old\n"})
+
+	source := filepath.Join(sourceRepo, "config.yaml")
+	target := filepath.Join(targetRepo, "config.yaml")
+	for _, args := range [][]string{{"-s", source, "-t", target}, {"-s", source, "-t", target, "--transactional"}} {
+		var stdout, stderr bytes.Buffer
+		if code := runSync(args, &stdout, &stderr); code != 0 {
+			t.Fatalf("Exit code = %d\nstdout: %s\nstderr: %s", code, stdout.String(), stderr.String())
+		}
+	}
+
+	// Only the target changed: no untracked lockfile dirties the checkout
+	status, err := exec.Command("git", "-C", targetRepo, "status", "--porcelain").Output()
+	if err != nil {
+		t.Fatalf("git status failed: %v", err)
+	}
+	if got := strings.TrimSpace(string(status)); got != "M config.yaml" {
+		t.Errorf("git status = %q, want only config.yaml modified", got)
+	}
+}
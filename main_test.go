@@ -0,0 +1,20 @@
+package filemirror
+
+import (
+	"os"
+	"testing"
+)
+
+// TestMain keeps the sync journals and merge bases of all tests out of the real state directory
+func TestMain(m *testing.M) {
+	stateHome, err := os.MkdirTemp("", "fmr-state-*")
+	if err != nil {
+		panic(err)
+	}
+	_ = os.Setenv("XDG_STATE_HOME", stateHome)
+
+	code := m.Run()
+
+	_ = os.RemoveAll(stateHome)
+	os.Exit(code)
+}
@@ -0,0 +1,339 @@
+package filemirror
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// manifestFileName is the manifest picked up automatically from the working directory
+const manifestFileName = ".fmr.yaml"
+
+// Manifest describes canonical files and the replicas they are mirrored into.
+// All paths in a manifest are relative to the directory containing it.
+type Manifest struct {
+	Groups []MirrorGroup `yaml:"groups"`
+	Scan   ScanConfig    `yaml:"scan"`
+	Roots  []string      `yaml:"roots"` // directories scanned besides the manifest's own, e.g. sibling checkouts
+	Git    GitTemplates  `yaml:"git"`   // project-wide branch and commit message templates
+
+	// Dir is the absolute directory of the manifest file
+	Dir string `yaml:"-"`
+}
+
+// MirrorGroup is one canonical source file and the targets it is mirrored into
+type MirrorGroup struct {
+	Name          string   `yaml:"name"`
+	Source        string   `yaml:"source"`
+	Targets       []string `yaml:"targets"`        // file paths or glob patterns (** matches any depth)
+	Exclude       []string `yaml:"exclude"`        // glob patterns removed from the matched targets
+	Branch        string   `yaml:"branch"`         // template, overrides the manifest's git.branch
+	CommitMessage string   `yaml:"commit_message"` // template, overrides the manifest's git.commit_message
+
+	// Templated sources are rendered per target, see sourceTemplate
+	Template   bool              `yaml:"template"`
+	Values     map[string]string `yaml:"values"`      // values for every target
+	ValuesFile string            `yaml:"values_file"` // per-directory values file (default: .fmr-values.yaml)
+
+	// Section mirrors only the "BEGIN fmr:NAME" ... "END fmr:NAME" region, see spliceSection
+	Section       string `yaml:"section"`
+	InsertMissing bool   `yaml:"insert_missing"` // append the section to targets without its markers
+
+	// Keys mirrors only these dotted key paths of a JSON, YAML or TOML file, see mergeKeys
+	Keys   []string `yaml:"keys"`
+	Format string   `yaml:"format"` // json, yaml or toml (default: from the source's extension)
+
+	// Merge keeps local changes to the targets by merging three-way with the last synced version, see merge3
+	Merge bool `yaml:"merge"`
+}
+
+// gitTemplates returns the branch and commit message templates for group, falling back
+// to the manifest's project-wide templates
+func (mf *Manifest) gitTemplates(group MirrorGroup) GitTemplates {
+	templates := mf.Git
+	if group.Branch != "" {
+		templates.Branch = group.Branch
+	}
+	if group.CommitMessage != "" {
+		templates.CommitMessage = group.CommitMessage
+	}
+	return templates
+}
+
+// ScanConfig overrides the scope of file scanning. Unset fields keep the defaults.
+type ScanConfig struct {
+	Depth         *int     `yaml:"depth"`          // directory levels to scan, -1 for unlimited
+	Exclude       []string `yaml:"exclude"`        // names or path patterns to skip
+	IncludeHidden bool     `yaml:"include_hidden"` // also scan hidden directories like .cache
+}
+
+// findManifest returns the path of the manifest in dir, or "" if there is none
+func findManifest(dir string) string {
+	manifestPath := filepath.Join(dir, manifestFileName)
+	if info, err := os.Stat(manifestPath); err == nil && info.Mode().IsRegular() {
+		return manifestPath
+	}
+	return ""
+}
+
+// loadManifest reads and validates a manifest file
+func loadManifest(manifestPath string) (*Manifest, error) {
+	absPath, err := filepath.Abs(manifestPath)
+	if err != nil {
+		return nil, fmt.Errorf("invalid manifest path %q: %w", manifestPath, err)
+	}
+
+	data, err := os.ReadFile(absPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+
+	manifest, err := parseManifest(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", manifestPath, err)
+	}
+	manifest.Dir = filepath.Dir(absPath)
+
+	return manifest, nil
+}
+
+// parseManifest decodes and validates manifest YAML
+func parseManifest(data []byte) (*Manifest, error) {
+	var manifest Manifest
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&manifest); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("invalid manifest: %w", err)
+	}
+
+	if len(manifest.Groups) == 0 {
+		return nil, errors.New("manifest defines no mirror groups")
+	}
+	if manifest.Scan.Depth != nil && *manifest.Scan.Depth < -1 {
+		return nil, fmt.Errorf("scan: depth must be -1 (unlimited) or at least 0, got %d", *manifest.Scan.Depth)
+	}
+	if err := manifest.Git.validate(); err != nil {
+		return nil, fmt.Errorf("git: %w", err)
+	}
+
+	names := make(map[string]bool)
+	for i := range manifest.Groups {
+		group := &manifest.Groups[i]
+		if group.Source == "" {
+			return nil, fmt.Errorf("group %d: source is required", i+1)
+		}
+		if len(group.Targets) == 0 {
+			return nil, fmt.Errorf("group %d: at least one target is required", i+1)
+		}
+		if group.Name == "" {
+			group.Name = group.Source
+		}
+		if names[group.Name] {
+			return nil, fmt.Errorf("duplicate group name %q", group.Name)
+		}
+		if err := (GitTemplates{Branch: group.Branch, CommitMessage: group.CommitMessage}).validate(); err != nil {
+			return nil, fmt.Errorf("group %q: %w", group.Name, err)
+		}
+		if !group.Template && (len(group.Values) > 0 || group.ValuesFile != "") {
+			return nil, fmt.Errorf("group %q: values and values_file require template: true", group.Name)
+		}
+		if group.Section != "" {
+			if err := validateSectionName(group.Section); err != nil {
+				return nil, fmt.Errorf("group %q: %w", group.Name, err)
+			}
+		} else if group.InsertMissing {
+			return nil, fmt.Errorf("group %q: insert_missing requires section", group.Name)
+		}
+		if err := validateKeys(group.Source, group.Keys, group.Format, group.Section); err != nil {
+			return nil, fmt.Errorf("group %q: %w", group.Name, err)
+		}
+		names[group.Name] = true
+	}
+
+	return &manifest, nil
+}
+
+// manifestPlan is a manifest group resolved to absolute source and target paths
+type manifestPlan struct {
+	Group    MirrorGroup
+	Source   string
+	Targets  []string
+	Template *sourceTemplate // renders the source per target, for templated and section groups
+	Err      error           // set if the group could not be resolved
+}
+
+// planManifest loads a manifest and resolves the named groups (all groups if names is empty).
+// Without manifestPath, the manifest in workDir is used; relative paths are resolved from workDir.
+func planManifest(workDir, manifestPath string, names []string) (*Manifest, []manifestPlan, error) {
+	if manifestPath == "" {
+		manifestPath = findManifest(workDir)
+		if manifestPath == "" {
+			return nil, nil, fmt.Errorf("--source is required (or a %s manifest in %s)", manifestFileName, workDir)
+		}
+	} else if !filepath.IsAbs(manifestPath) {
+		manifestPath = filepath.Join(workDir, manifestPath)
+	}
+
+	manifest, err := loadManifest(manifestPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	groups, err := manifest.selectGroups(names)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	files, err := scanRoots(manifest.scanRoots(), "", defaultScanOptions().withConfig(manifest.Scan))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	plans := make([]manifestPlan, 0, len(groups))
+	for _, group := range groups {
+		source, targets, err := manifest.resolveGroup(group, manifest.Dir, files)
+		plans = append(plans, manifestPlan{
+			Group:    group,
+			Source:   source,
+			Targets:  targets,
+			Template: newSourceTemplate(manifest, group),
+			Err:      err,
+		})
+	}
+
+	return manifest, plans, nil
+}
+
+// scanRoots returns the manifest directory followed by the extra roots as absolute paths
+func (mf *Manifest) scanRoots() []string {
+	roots := []string{mf.Dir}
+	for _, root := range mf.Roots {
+		roots = append(roots, mf.absPath(root))
+	}
+	return roots
+}
+
+// selectGroups returns the groups with the given names, or all groups if names is empty
+func (mf *Manifest) selectGroups(names []string) ([]MirrorGroup, error) {
+	if len(names) == 0 {
+		return mf.Groups, nil
+	}
+
+	groups := make([]MirrorGroup, 0, len(names))
+	for _, name := range names {
+		found := false
+		for _, group := range mf.Groups {
+			if group.Name == name {
+				groups = append(groups, group)
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("manifest has no group named %q", name)
+		}
+	}
+	return groups, nil
+}
+
+// resolveGroup resolves a group's source and targets to absolute paths.
+// Glob targets are matched against files discovered by scanFiles; files without
+// a Root are relative to baseDir. Plain targets are used as-is, even if they don't exist yet.
+func (mf *Manifest) resolveGroup(group MirrorGroup, baseDir string, files []FileInfo) (string, []string, error) {
+	source := mf.absPath(group.Source)
+	if _, err := os.Stat(source); err != nil {
+		return "", nil, fmt.Errorf("group %q: cannot read source: %w", group.Name, err)
+	}
+
+	seen := map[string]bool{source: true}
+	var targets []string
+	add := func(target string) {
+		if seen[target] || mf.excluded(group, target) {
+			return
+		}
+		seen[target] = true
+		targets = append(targets, target)
+	}
+
+	for _, pattern := range group.Targets {
+		// Path captures like {name} match like *
+		pattern = capturePattern.ReplaceAllString(pattern, "*")
+		if !strings.ContainsAny(pattern, "*?[") {
+			add(mf.absPath(pattern))
+			continue
+		}
+
+		absPattern := filepath.ToSlash(mf.absPath(pattern))
+		for _, file := range files {
+			absFile := file.fullPath(baseDir)
+			if matchPathPattern(absPattern, filepath.ToSlash(absFile)) {
+				add(absFile)
+			}
+		}
+	}
+
+	if len(targets) == 0 {
+		return "", nil, fmt.Errorf("group %q: no targets matched", group.Name)
+	}
+
+	sort.Strings(targets)
+	return source, targets, nil
+}
+
+// excluded reports whether target matches one of the group's exclude patterns
+func (mf *Manifest) excluded(group MirrorGroup, target string) bool {
+	for _, pattern := range group.Exclude {
+		if matchPathPattern(filepath.ToSlash(mf.absPath(pattern)), filepath.ToSlash(target)) {
+			return true
+		}
+	}
+	return false
+}
+
+// absPath resolves a manifest-relative path
+func (mf *Manifest) absPath(p string) string {
+	if filepath.IsAbs(p) {
+		return filepath.Clean(p)
+	}
+	return filepath.Join(mf.Dir, filepath.FromSlash(p))
+}
+
+// matchPathPattern matches a slash-separated path against a glob pattern.
+// In addition to path.Match syntax, a "**" segment matches zero or more directories.
+func matchPathPattern(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			// Try to match the rest of the pattern at every remaining depth
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(name) == 0 {
+			return false
+		}
+		matched, err := path.Match(pattern[0], name[0])
+		if err != nil || !matched {
+			return false
+		}
+		pattern = pattern[1:]
+		name = name[1:]
+	}
+	return len(name) == 0
+}
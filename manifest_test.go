@@ -0,0 +1,331 @@
+package filemirror
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseManifest(t *testing.T) {
+	tests := []struct {
+		name        string
+		yaml        string
+		wantGroups  []string
+		errContains string
+	}{
+		{
+			name: "valid manifest",
+			yaml: `groups:
+  - name: lint
+    source: canonical/.golangci.yml
+    targets: ["services/*/.golangci.yml"]
+    exclude: ["services/legacy/*"]
+    branch: chore/sync-lint
+    commit_message: "chore: sync lint config"
+  - source: LICENSE
+    targets: [a/LICENSE, b/LICENSE]
+`,
+			wantGroups: []string{"lint", "LICENSE"},
+		},
+		{
+			name:        "empty manifest",
+			yaml:        "",
+			errContains: "no mirror groups",
+		},
+		{
+			name:        "missing source",
+			yaml:        "groups:\n  - targets: [a]\n",
+			errContains: "source is required",
+		},
+		{
+			name:        "missing targets",
+			yaml:        "groups:\n  - source: a\n",
+			errContains: "at least one target",
+		},
+		{
+			name:        "duplicate names",
+			yaml:        "groups:\n  - {name: x, source: a, targets: [b]}\n  - {name: x, source: c, targets: [d]}\n",
+			errContains: "duplicate group name",
+		},
+		{
+			name:       "scan section",
+			yaml:       "scan:\n  depth: 8\n  exclude: [fixtures]\n  include_hidden: true\ngroups:\n  - {source: a, targets: [b]}\n",
+			wantGroups: []string{"a"},
+		},
+		{
+			name:        "invalid scan depth",
+			yaml:        "scan: {depth: -2}\ngroups:\n  - {source: a, targets: [b]}\n",
+			errContains: "depth must be",
+		},
+		{
+			name:       "git templates",
+			yaml:       "git:\n  branch: \"sync/{{.Ticket}}\"\n  commit_message: \"{{.Ticket}}: sync\"\ngroups:\n  - {source: a, targets: [b]}\n",
+			wantGroups: []string{"a"},
+		},
+		{
+			name:        "invalid git template",
+			yaml:        "git: {branch: \"sync/{{.Ticket\"}\ngroups:\n  - {source: a, targets: [b]}\n",
+			errContains: "git: invalid branch template",
+		},
+		{
+			name:        "invalid group template",
+			yaml:        "groups:\n  - {name: x, source: a, targets: [b], commit_message: \"{{end}}\"}\n",
+			errContains: "group \"x\": invalid commit message template",
+		},
+		{
+			name:       "templated group",
+			yaml:       "groups:\n  - {source: a, targets: [\"services/{name}/b\"], template: true, values: {port: \"80\"}}\n",
+			wantGroups: []string{"a"},
+		},
+		{
+			name:        "values without template",
+			yaml:        "groups:\n  - {name: x, source: a, targets: [b], values: {port: \"80\"}}\n",
+			errContains: "values and values_file require template: true",
+		},
+		{
+			name:       "section group",
+			yaml:       "groups:\n  - {source: a, targets: [b], section: shared-lint, insert_missing: true}\n",
+			wantGroups: []string{"a"},
+		},
+		{
+			name:        "insert_missing without section",
+			yaml:        "groups:\n  - {name: x, source: a, targets: [b], insert_missing: true}\n",
+			errContains: "group \"x\": insert_missing requires section",
+		},
+		{
+			name:       "keyed group",
+			yaml:       "groups:\n  - {source: tsconfig.json, targets: [b], keys: [compilerOptions]}\n",
+			wantGroups: []string{"tsconfig.json"},
+		},
+		{
+			name:        "keys without a known format",
+			yaml:        "groups:\n  - {name: x, source: .eslintrc, targets: [b], keys: [rules]}\n",
+			errContains: "group \"x\": cannot tell the format of .eslintrc",
+		},
+		{
+			name:       "merged group",
+			yaml:       "groups:\n  - {source: a, targets: [b], merge: true}\n",
+			wantGroups: []string{"a"},
+		},
+		{
+			name:        "unknown field",
+			yaml:        "groups:\n  - {source: a, targets: [b], tagets: [c]}\n",
+			errContains: "invalid manifest",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manifest, err := parseManifest([]byte(tt.yaml))
+
+			if tt.errContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+					t.Errorf("Expected error containing %q, got %v", tt.errContains, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			var names []string
+			for _, group := range manifest.Groups {
+				names = append(names, group.Name)
+			}
+			if strings.Join(names, ",") != strings.Join(tt.wantGroups, ",") {
+				t.Errorf("Group names = %v, want %v", names, tt.wantGroups)
+			}
+		})
+	}
+}
+
+func TestMatchPathPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"services/*/config.yaml", "services/api/config.yaml", true},
+		{"services/*/config.yaml", "services/api/v2/config.yaml", false},
+		{"services/**/config.yaml", "services/api/v2/config.yaml", true},
+		{"services/**/config.yaml", "services/config.yaml", true},
+		{"**/LICENSE", "a/b/c/LICENSE", true},
+		{"**/LICENSE", "LICENSE.md", false},
+		{"*.yml", "ci.yml", true},
+		{"[invalid", "x", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+"~"+tt.name, func(t *testing.T) {
+			if got := matchPathPattern(tt.pattern, tt.name); got != tt.want {
+				t.Errorf("matchPathPattern(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+// writeManifestTree creates a source and service config files and a manifest describing them
+func writeManifestTree(t *testing.T, manifestYAML string) string {
+	t.Helper()
+
+	tmpDir := t.TempDir()
+	files := []string{
+		"canonical/config.yaml",
+		"services/api/config.yaml",
+		"services/web/config.yaml",
+		"services/legacy/config.yaml",
+	}
+	for _, f := range files {
+		path := filepath.Join(tmpDir, filepath.FromSlash(f))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte("content of "+f), 0o644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, manifestFileName), []byte(manifestYAML), 0o644); err != nil {
+		t.Fatalf("Failed to write manifest: %v", err)
+	}
+	return tmpDir
+}
+
+func TestManifestResolveGroup(t *testing.T) {
+	tmpDir := writeManifestTree(t, `groups:
+  - name: config
+    source: canonical/config.yaml
+    targets: ["services/*/config.yaml", "services/new/config.yaml"]
+    exclude: ["services/legacy/*"]
+`)
+
+	if got := findManifest(tmpDir); got != filepath.Join(tmpDir, manifestFileName) {
+		t.Fatalf("findManifest() = %q", got)
+	}
+	if got := findManifest(t.TempDir()); got != "" {
+		t.Errorf("findManifest() in empty dir = %q, want empty", got)
+	}
+
+	manifest, err := loadManifest(filepath.Join(tmpDir, manifestFileName))
+	if err != nil {
+		t.Fatalf("loadManifest failed: %v", err)
+	}
+
+	files, err := scanFiles(tmpDir, "")
+	if err != nil {
+		t.Fatalf("scanFiles failed: %v", err)
+	}
+
+	source, targets, err := manifest.resolveGroup(manifest.Groups[0], tmpDir, files)
+	if err != nil {
+		t.Fatalf("resolveGroup failed: %v", err)
+	}
+
+	if source != filepath.Join(tmpDir, "canonical", "config.yaml") {
+		t.Errorf("source = %q", source)
+	}
+	want := []string{
+		filepath.Join(tmpDir, "services", "api", "config.yaml"),
+		filepath.Join(tmpDir, "services", "new", "config.yaml"),
+		filepath.Join(tmpDir, "services", "web", "config.yaml"),
+	}
+	if strings.Join(targets, "\n") != strings.Join(want, "\n") {
+		t.Errorf("targets = %v, want %v", targets, want)
+	}
+
+	if _, err := manifest.selectGroups([]string{"nope"}); err == nil {
+		t.Error("Expected error selecting unknown group")
+	}
+}
+
+func TestApplyManifestGroup(t *testing.T) {
+	tmpDir := writeManifestTree(t, `groups:
+  - name: config
+    source: canonical/config.yaml
+    targets: ["services/*/config.yaml"]
+    exclude: ["services/legacy/*"]
+    branch: chore/sync-config
+  - name: api-only
+    source: canonical/config.yaml
+    targets: [services/api/config.yaml]
+`)
+
+	manifest, err := loadManifest(filepath.Join(tmpDir, manifestFileName))
+	if err != nil {
+		t.Fatalf("loadManifest failed: %v", err)
+	}
+	files, err := scanFiles(tmpDir, "")
+	if err != nil {
+		t.Fatalf("scanFiles failed: %v", err)
+	}
+
+	m := InitialModel("", tmpDir)
+	if err := m.setManifest(manifest, ""); err != nil {
+		t.Fatalf("setManifest failed: %v", err)
+	}
+	updated, _ := m.Update(scanCompleteMsg{files: files})
+	m = updated.(model)
+
+	if m.err != nil {
+		t.Fatalf("Unexpected error: %v", m.err)
+	}
+	if m.sourceFile == nil || m.sourceFile.Path != filepath.Join("canonical", "config.yaml") {
+		t.Fatalf("sourceFile = %+v", m.sourceFile)
+	}
+	if len(m.selected) != 2 {
+		t.Errorf("Expected 2 preselected targets, got %d", len(m.selected))
+	}
+
+	m.initGitWorkflow()
+	if got := m.branchNameInput.Value(); got != "chore/sync-config" {
+		t.Errorf("branch name = %q, want manifest branch", got)
+	}
+
+	// Cycle to the next group
+	m.focus = focusList
+	updated, _ = m.updateSelect(keyMsg("g"))
+	m = *updated.(*model)
+	if m.activeGroup == nil || m.activeGroup.Name != "api-only" {
+		t.Errorf("Expected api-only group to be active, got %+v", m.activeGroup)
+	}
+	if len(m.selected) != 1 {
+		t.Errorf("Expected 1 preselected target, got %d", len(m.selected))
+	}
+
+	if err := m.setManifest(manifest, "missing"); err == nil {
+		t.Error("Expected error for unknown group")
+	}
+}
+
+func TestPlanManifestWithRoots(t *testing.T) {
+	parent := t.TempDir()
+	for path, content := range map[string]string{
+		"infra/canonical/ci.yml": "ci",
+		"api/ci.yml":             "old",
+		"web/ci.yml":             "old",
+	} {
+		full := filepath.Join(parent, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+	manifestYAML := "roots: [../api, ../web]\ngroups:\n  - {name: ci, source: canonical/ci.yml, targets: [\"../*/ci.yml\"]}\n"
+	if err := os.WriteFile(filepath.Join(parent, "infra", manifestFileName), []byte(manifestYAML), 0o644); err != nil {
+		t.Fatalf("Failed to write manifest: %v", err)
+	}
+
+	_, plans, err := planManifest(filepath.Join(parent, "infra"), "", nil)
+	if err != nil {
+		t.Fatalf("planManifest failed: %v", err)
+	}
+	if plans[0].Err != nil {
+		t.Fatalf("plan error: %v", plans[0].Err)
+	}
+	want := []string{filepath.Join(parent, "api", "ci.yml"), filepath.Join(parent, "web", "ci.yml")}
+	if strings.Join(plans[0].Targets, "\n") != strings.Join(want, "\n") {
+		t.Errorf("targets = %v, want %v", plans[0].Targets, want)
+	}
+}
@@ -0,0 +1,227 @@
+package filemirror
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Conflict markers around the two sides of a merge conflict, as in git
+const (
+	conflictStart  = "<<<<<<< target"
+	conflictMiddle = "======="
+	conflictEnd    = ">>>>>>> source"
+)
+
+// errNoMergeBase is returned for a merge into a changed target whose last synced version is unknown
+var errNoMergeBase = errors.New("no merge base: the target has no recorded sync to merge local changes against; use --force to overwrite it")
+
+// mergeConflictError reports a three-way merge that left conflicts in a target
+type mergeConflictError struct {
+	Conflicts int
+}
+
+func (e *mergeConflictError) Error() string {
+	if e.Conflicts == 1 {
+		return "1 merge conflict with local changes"
+	}
+	return fmt.Sprintf("%d merge conflicts with local changes", e.Conflicts)
+}
+
+// merge3 merges the changes from base to ours (the target) and from base to theirs (the
+// new source) line by line. Regions changed differently on both sides become conflicts
+// wrapped in conflict markers. Returns the merged text and the number of conflicts.
+func merge3(base, ours, theirs string) (string, int) {
+	baseLines := strings.Split(base, "\n")
+	ourLines := strings.Split(ours, "\n")
+	theirLines := strings.Split(theirs, "\n")
+	toOurs := matchLines(baseLines, ourLines)
+	toTheirs := matchLines(baseLines, theirLines)
+
+	var merged []string
+	conflicts := 0
+	b, o, t := 0, 0, 0
+	for {
+		// Find the next base line kept by both sides
+		next := b
+		for next < len(baseLines) && (toOurs[next] < 0 || toTheirs[next] < 0) {
+			next++
+		}
+		nextOurs, nextTheirs := len(ourLines), len(theirLines)
+		if next < len(baseLines) {
+			nextOurs, nextTheirs = toOurs[next], toTheirs[next]
+		}
+
+		// Resolve the changed region before it
+		baseChunk, ourChunk, theirChunk := baseLines[b:next], ourLines[o:nextOurs], theirLines[t:nextTheirs]
+		switch {
+		case equalLines(ourChunk, baseChunk):
+			merged = append(merged, theirChunk...)
+		case equalLines(theirChunk, baseChunk), equalLines(ourChunk, theirChunk):
+			merged = append(merged, ourChunk...)
+		default:
+			conflicts++
+			merged = append(merged, conflictStart)
+			merged = append(merged, ourChunk...)
+			merged = append(merged, conflictMiddle)
+			merged = append(merged, theirChunk...)
+			merged = append(merged, conflictEnd)
+		}
+
+		if next == len(baseLines) {
+			break
+		}
+		merged = append(merged, baseLines[next])
+		b, o, t = next+1, nextOurs+1, nextTheirs+1
+	}
+	return strings.Join(merged, "\n"), conflicts
+}
+
+// matchLines returns, for every line of base, the index of the same line in other if the
+// minimal diff keeps it, or -1 if other removed it
+func matchLines(base, other []string) []int {
+	matches := make([]int, len(base))
+	for i := range matches {
+		matches[i] = -1
+	}
+	for _, op := range computeDiff(base, other) {
+		if op.Kind == diffEqual {
+			matches[op.OldIndex] = op.NewIndex
+		}
+	}
+	return matches
+}
+
+// equalLines reports whether two line slices are identical
+func equalLines(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// basesDir returns the directory holding the last synced version of every target:
+// index.json maps target paths to content hashes, objects/ holds the contents by hash
+func basesDir() (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "bases"), nil
+}
+
+// loadBaseIndex reads the target path to content hash index of the base store
+func loadBaseIndex(dir string) (map[string]string, error) {
+	index := make(map[string]string)
+	data, err := os.ReadFile(filepath.Join(dir, "index.json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return index, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read sync bases: %w", err)
+	}
+	if err := json.Unmarshal(data, &index); err != nil {
+		return nil, fmt.Errorf("invalid sync bases: %w", err)
+	}
+	return index, nil
+}
+
+// recordBase remembers content as the version of the source last synced into target,
+// the base of the next three-way merge if the lockfile of target's repository has none
+func recordBase(target string, content []byte) error {
+	dir, err := basesDir()
+	if err != nil {
+		return err
+	}
+	hash := hashBytes(content)
+	if err := os.MkdirAll(filepath.Join(dir, "objects"), 0o700); err != nil {
+		return fmt.Errorf("failed to create sync bases: %w", err)
+	}
+	object := filepath.Join(dir, "objects", hash)
+	if _, err := os.Stat(object); errors.Is(err, os.ErrNotExist) {
+		if err := writeFileAtomic(object, content, 0o600); err != nil {
+			return fmt.Errorf("failed to record sync base: %w", err)
+		}
+	}
+
+	index, err := loadBaseIndex(dir)
+	if err != nil {
+		return err
+	}
+	if index[target] == hash {
+		return nil
+	}
+	index[target] = hash
+	data, err := json.MarshalIndent(index, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode sync bases: %w", err)
+	}
+	if err := writeFileAtomic(filepath.Join(dir, "index.json"), data, 0o600); err != nil {
+		return fmt.Errorf("failed to record sync base: %w", err)
+	}
+	return nil
+}
+
+// lockedSource returns the source last synced into target as recorded in the lockfile of
+// target's repository: the source's content at the recorded commit, if it has the recorded hash.
+// The lockfile is shared through git, so this base is the same on every machine.
+func lockedSource(target string) ([]byte, bool, error) {
+	repo, err := detectGitRoot(target)
+	if err != nil {
+		return nil, false, nil // Outside git there is no lockfile
+	}
+	lock, err := readLockFile(filepath.Join(repo, lockFileName))
+	if err != nil {
+		return nil, false, err
+	}
+	relPath, err := filepath.Rel(repo, target)
+	if err != nil {
+		return nil, false, nil
+	}
+	for _, entry := range lock.Files {
+		if entry.Path != filepath.ToSlash(relPath) || entry.SourceCommit == "" {
+			continue
+		}
+		sourceRoot := filepath.Join(repo, filepath.FromSlash(entry.SourceRepo))
+		content, err := exec.Command("git", "-C", sourceRoot, "show", entry.SourceCommit+":"+entry.SourcePath).Output()
+		if err != nil || hashBytes(content) != entry.SourceHash {
+			return nil, false, nil // The source was synced with uncommitted changes, or its history is unavailable
+		}
+		return content, true, nil
+	}
+	return nil, false, nil
+}
+
+// loadBase returns the version of the source last synced into target by a merge sync on
+// this machine, if any
+func loadBase(target string) ([]byte, bool, error) {
+	dir, err := basesDir()
+	if err != nil {
+		return nil, false, err
+	}
+	index, err := loadBaseIndex(dir)
+	if err != nil {
+		return nil, false, err
+	}
+	hash, ok := index[target]
+	if !ok {
+		return nil, false, nil
+	}
+	content, err := os.ReadFile(filepath.Join(dir, "objects", hash))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read sync base: %w", err)
+	}
+	return content, true, nil
+}
@@ -0,0 +1,262 @@
+package filemirror
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMerge3(t *testing.T) {
+	tests := []struct {
+		name      string
+		base      string
+		ours      string
+		theirs    string
+		want      string
+		conflicts int
+	}{
+		{
+			name:   "only the source changed",
+			base:   "a\nb\nc\n",
+			ours:   "a\nb\nc\n",
+			theirs: "a\nB\nc\n",
+			want:   "a\nB\nc\n",
+		},
+		{
+			name:   "only the target changed",
+			base:   "a\nb\nc\n",
+			ours:   "a\nb\nc\nlocal\n",
+			theirs: "a\nb\nc\n",
+			want:   "a\nb\nc\nlocal\n",
+		},
+		{
+			name:   "separate changes on both sides",
+			base:   "a\nb\nc\nd\ne\n",
+			ours:   "a\nlocal\nb\nc\nd\ne\n",
+			theirs: "a\nb\nc\nd\nE\n",
+			want:   "a\nlocal\nb\nc\nd\nE\n",
+		},
+		{
+			name:   "same change on both sides",
+			base:   "a\nb\nc\n",
+			ours:   "a\nB\nc\n",
+			theirs: "a\nB\nc\n",
+			want:   "a\nB\nc\n",
+		},
+		{
+			name:   "line removed by the source",
+			base:   "a\nb\nc\n",
+			ours:   "a\nb\nc\nlocal\n",
+			theirs: "a\nc\n",
+			want:   "a\nc\nlocal\n",
+		},
+		{
+			name:      "conflicting changes",
+			base:      "a\nb\nc\n",
+			ours:      "a\nours\nc\n",
+			theirs:    "a\ntheirs\nc\n",
+			want:      "a\n<<<<<<< target\nours\n=======\ntheirs\n>>>>>>> source\nc\n",
+			conflicts: 1,
+		},
+		{
+			name:      "two conflicts",
+			base:      "a\nb\nc\nd\ne\n",
+			ours:      "a\nB1\nc\nD1\ne\n",
+			theirs:    "a\nB2\nc\nD2\ne\n",
+			want:      "a\n<<<<<<< target\nB1\n=======\nB2\n>>>>>>> source\nc\n<<<<<<< target\nD1\n=======\nD2\n>>>>>>> source\ne\n",
+			conflicts: 2,
+		},
+		{
+			name:   "empty base",
+			base:   "",
+			ours:   "",
+			theirs: "new\n",
+			want:   "new\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, conflicts := merge3(tt.base, tt.ours, tt.theirs)
+			if got != tt.want {
+				t.Errorf("merge3() = %q, want %q", got, tt.want)
+			}
+			if conflicts != tt.conflicts {
+				t.Errorf("merge3() conflicts = %d, want %d", conflicts, tt.conflicts)
+			}
+		})
+	}
+}
+
+func TestRecordBase(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	target := filepath.Join(t.TempDir(), "config.yaml")
+
+	if _, found, err := loadBase(target); err != nil || found {
+		t.Fatalf("loadBase() before recording = found %v, err %v", found, err)
+	}
+	for _, content := range []string{"v1\n", "v2\n"} {
+		if err := recordBase(target, []byte(content)); err != nil {
+			t.Fatalf("recordBase() error = %v", err)
+		}
+		base, found, err := loadBase(target)
+		if err != nil || !found || string(base) != content {
+			t.Errorf("loadBase() = %q, %v, %v, want %q", base, found, err, content)
+		}
+	}
+}
+
+func TestRunSyncMerge(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	tmpDir := t.TempDir()
+	source := filepath.Join(tmpDir, "source.conf")
+	target := filepath.Join(tmpDir, "a", "app.conf")
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		t.Fatalf("Failed to create dir: %v", err)
+	}
+	write := func(path, content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+	args := []string{"-p", tmpDir, "-s", "source.conf", "-t", "a/app.conf", "--merge"}
+	sync := func(args []string, want int) string {
+		t.Helper()
+		var stdout, stderr bytes.Buffer
+		if code := runSync(args, &stdout, &stderr); code != want {
+			t.Fatalf("Exit code = %d, want %d\nstdout: %s\nstderr: %s", code, want, stdout.String(), stderr.String())
+		}
+		return stdout.String()
+	}
+
+	// A sync without --merge records no base, so a changed target cannot be merged yet
+	write(source, "level = info\ncolor = auto\nport = 80\n")
+	write(target, "old\n")
+	sync([]string{"-p", tmpDir, "-s", "source.conf", "-t", "a/app.conf"}, 0)
+	if _, found, _ := loadBase(target); found {
+		t.Error("Expected a sync without --merge not to record a base")
+	}
+	write(target, "old\n")
+	if out := sync(args, 1); !strings.Contains(out, "no merge base") {
+		t.Errorf("Expected the missing base to be reported, got:\n%s", out)
+	}
+	if content, _ := os.ReadFile(target); string(content) != "old\n" {
+		t.Errorf("target without a base = %q, want it untouched", content)
+	}
+
+	// --force overwrites it and records the base
+	sync(append(args, "--force"), 0)
+
+	// Local changes survive the next sync of a changed source
+	write(target, "level = debug\ncolor = auto\nport = 80\n")
+	write(source, "level = info\ncolor = auto\nport = 8080\n")
+	sync(args, 0)
+	if content, _ := os.ReadFile(target); string(content) != "level = debug\ncolor = auto\nport = 8080\n" {
+		t.Errorf("target after merge = %q", content)
+	}
+
+	// Kept local changes are not drift
+	var stdout, stderr bytes.Buffer
+	if code := runCheck([]string{"-p", tmpDir, "-s", "source.conf", "-t", "a/app.conf", "--merge"}, &stdout, &stderr); code != checkExitInSync {
+		t.Errorf("check exit code = %d\n%s", code, stdout.String())
+	}
+
+	// Conflicts are refused, and leave the target untouched
+	write(source, "level = warn\ncolor = auto\nport = 8080\n")
+	if out := sync(args, 1); !strings.Contains(out, "1 merge conflict with local changes") {
+		t.Errorf("Expected the conflict to be reported, got:\n%s", out)
+	}
+	if content, _ := os.ReadFile(target); string(content) != "level = debug\ncolor = auto\nport = 8080\n" {
+		t.Errorf("target after refused merge = %q", content)
+	}
+	if out := sync(append(args, "--transactional"), 1); !strings.Contains(out, "merge conflict") {
+		t.Errorf("Expected the transaction to fail on the conflict, got:\n%s", out)
+	}
+
+	// --force writes the conflict markers
+	sync(append(args, "--force"), 0)
+	want := "<<<<<<< target\nlevel = debug\n=======\nlevel = warn\n>>>>>>> source\ncolor = auto\nport = 8080\n"
+	if content, _ := os.ReadFile(target); string(content) != want {
+		t.Errorf("target after forced merge = %q, want %q", content, want)
+	}
+}
+
+// TestRunSyncMergeSharedBase merges on a machine without recorded bases, using the source
+// commit from the lockfile committed by an earlier sync
+func TestRunSyncMergeSharedBase(t *testing.T) {
+	sourceRepo := createTestGitRepo(t)
+	defer os.RemoveAll(sourceRepo)
+	targetRepo := createTestGitRepo(t)
+	defer os.RemoveAll(targetRepo)
+	commitFiles(t, sourceRepo, map[string]string{"app.conf": "level = info\ncolor = auto\nport = 80\n"})
+	commitFiles(t, targetRepo, map[string]string{"app.conf": "old\n"})
+	source := filepath.Join(sourceRepo, "app.conf")
+	target := filepath.Join(targetRepo, "app.conf")
+
+	sync := func(args ...string) {
+		t.Helper()
+		var stdout, stderr bytes.Buffer
+		if code := runSync(args, &stdout, &stderr); code != 0 {
+			t.Fatalf("Exit code = %d\nstdout: %s\nstderr: %s", code, stdout.String(), stderr.String())
+		}
+	}
+
+	// A teammate's sync is merged, lockfile included
+	sync("-s", source, "-t", target, "--git-only", "--branch", "chore/sync")
+	if output, err := exec.Command("git", "-C", targetRepo, "merge", "-q", "--ff-only", "chore/sync").CombinedOutput(); err != nil {
+		t.Fatalf("git merge failed: %v\n%s", err, output)
+	}
+
+	// Local changes survive a merge sync on a machine that never synced the target
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	if err := os.WriteFile(target, []byte("level = debug\ncolor = auto\nport = 80\n"), 0o644); err != nil {
+		t.Fatalf("Failed to edit target: %v", err)
+	}
+	commitFiles(t, sourceRepo, map[string]string{"app.conf": "level = info\ncolor = auto\nport = 8080\n"})
+	sync("-s", source, "-t", target, "--merge")
+	if content, _ := os.ReadFile(target); string(content) != "level = debug\ncolor = auto\nport = 8080\n" {
+		t.Errorf("target after merge = %q", content)
+	}
+}
+
+func TestMergePreview(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	tmpDir := t.TempDir()
+	target := filepath.Join(tmpDir, "target.conf")
+	if err := os.WriteFile(filepath.Join(tmpDir, "source.conf"), []byte("level = warn\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write source: %v", err)
+	}
+	if err := os.WriteFile(target, []byte("level = debug\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write target: %v", err)
+	}
+	if err := recordBase(target, []byte("level = info\n")); err != nil {
+		t.Fatalf("recordBase() error = %v", err)
+	}
+
+	m := InitialModel("", tmpDir)
+	m.width, m.height = 160, 24
+	m.merge = true
+	m.files = []FileInfo{{Path: "source.conf"}, {Path: "target.conf"}}
+	m.filterFiles()
+	m.sourceFile = &m.filteredFiles[0]
+	m.previewMode = previewDiff
+	m.cursor = 1
+
+	m.refreshPreview()
+	view := m.renderPreview()
+	if !strings.Contains(view, "[merge conflicts: 1]") || !strings.Contains(view, conflictStart) {
+		t.Errorf("Expected the conflict in the preview, got:\n%s", view)
+	}
+
+	// Copying refuses to write the conflict
+	if _, err := copyToTarget(filepath.Join(tmpDir, "source.conf"), []byte("level = warn\n"), target, m.sourceTemplate(), nil); err == nil {
+		t.Error("Expected copying a conflicting merge to fail")
+	}
+	if content, _ := os.ReadFile(target); string(content) != "level = debug\n" {
+		t.Errorf("target = %q, want it untouched", content)
+	}
+}
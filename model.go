@@ -1,12 +1,16 @@
 package filemirror
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
+	"github.com/charmbracelet/bubbles/spinner"
 	"github.com/charmbracelet/bubbles/textarea"
 	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
@@ -21,6 +25,7 @@ type mode int
 const (
 	modeSelect mode = iota
 	modeConfirm
+	modeProgress // copy and git workflow running, see progress.go
 )
 
 type inputFocus int
@@ -40,6 +45,12 @@ const (
 	focusBranchName
 	focusCommitMsg
 	focusPushToggle
+	focusPRToggle
+	focusDirtyTargets
+	focusGitOnlyToggle
+	focusBaseRef
+	focusFetchToggle
+	focusTicket
 )
 
 type previewMode int
@@ -48,13 +59,24 @@ const (
 	previewHidden previewMode = iota
 	previewPlain
 	previewDiff
+	previewSideBySide // falls back to previewDiff below minSideBySideWidth
+	previewModeCount
+)
+
+// minSideBySideWidth is the terminal width needed for the side-by-side preview
+const minSideBySideWidth = 140
+
+// Colors of removed and added lines in diff previews
+var (
+	diffDeleteColor = lipgloss.Color("9")  // Red for deletions
+	diffAddColor    = lipgloss.Color("34") // Darker green for additions (better contrast)
 )
 
 type model struct {
 	files         []FileInfo
 	filteredFiles []FileInfo
 	cursor        int
-	selected      map[int]bool // target files
+	selected      map[string]FileInfo // target files by absolute path
 	sourceFile    *FileInfo
 	searchInput   textinput.Model
 	pathInput     textinput.Model
@@ -64,10 +86,13 @@ type model struct {
 	mode          mode
 	viewport      int // for scrolling
 	focus         inputFocus
-	workDir       string      // current working directory
-	previewScroll int         // scroll position in preview
-	previewMode   previewMode // hidden, plain, or diff mode
-	showHelp      bool        // whether to show help overlay
+	workDir       string        // current working directory
+	previewScroll int           // scroll position in preview
+	previewMode   previewMode   // hidden, plain, diff, or side-by-side mode
+	diffContext   int           // context lines around changes in diff mode
+	intraLine     intraLineMode // word or character highlighting inside changed lines
+	showHelp      bool          // whether to show help overlay
+	preview       previewData   // content of the preview panel, loaded by refreshPreview
 
 	// Git workflow fields (integrated into modeConfirm)
 	gitEnabled      bool
@@ -76,9 +101,49 @@ type model struct {
 	shouldPush      bool
 	confirmFocus    confirmFocus
 	gitRepos        map[string][]string // repo path -> list of changed files
+	baseRef         string              // ref new branches start from (empty: default branch)
+	ticket          string              // ticket ID for the branch and commit templates
+	ticketInput     textinput.Model
+	templateData    gitTemplateData // template variables, collected when entering confirm mode
+	baseRefInput    textinput.Model
+	fetchBase       bool              // fetch origin before creating branches
+	repoBases       map[string]string // repo path -> resolved base ref, or why it cannot be resolved
+	dirty           []dirtyTarget     // selected targets with uncommitted changes (see dirty.go)
+	dirtyCursor     int
+
+	// Background sync started from the confirm screen (see progress.go)
+	run           *syncRun
+	spinner       spinner.Model
+	gitWorkers    int               // repositories committed and pushed at the same time
+	prOpts        PROptions         // pull requests to open after pushing (toggle in the confirm screen)
+	transactional bool              // copy to all targets or none of them
+	gitOnly       bool              // commit the source in the worktrees only, leaving the targets untouched
+	section       string            // managed section mirrored instead of the whole file (--section)
+	insertSection bool              // append the section to targets without its markers
+	keys          []string          // key paths mirrored instead of the whole file (--key)
+	keyFormat     string            // format of the keyed files (--format)
+	merge         bool              // merge with local changes since the last sync (--merge)
+	force         bool              // write merges with conflicts (--force)
+	prURLs        map[string]string // repo path -> pull request opened by the last sync
+
+	// Manifest preselection (see manifest.go)
+	manifest        *Manifest
+	manifestGroup   int          // index of the group to preselect
+	manifestPending bool         // preselect the group once the next scan completes
+	activeGroup     *MirrorGroup // group whose selection is active, for branch/commit defaults
 
 	// Summary to print after exit
 	exitSummary string
+	// Lines removed/added per copied target, for the exit summary
+	copyStats map[string][2]int
+
+	// Scan roots; the path input holds them comma-separated and roots[0] is workDir
+	roots []string
+
+	// Scan scope, editable in the scan settings panel
+	scanOpts     scanOptions
+	scanSettings *scanSettings // open scan settings panel, nil when closed
+	scan         *scanStream   // running scan, nil when idle
 
 	// Debounce fields for automatic scanning
 	lastSearchValue string // last search value we scanned for
@@ -86,9 +151,71 @@ type model struct {
 	debounceTimer   *time.Timer
 }
 
+// scanCompleteMsg carries scan results. Streamed scans send one message per batch of
+// directories, with done set on the last one; other scans send all files at once.
 type scanCompleteMsg struct {
 	files []FileInfo
 	err   error
+	scan  *scanStream // stream the batch belongs to, nil for one-shot scans
+	first bool        // first message of the stream; replaces the previous files
+	done  bool        // last message of the stream
+}
+
+// scanStream delivers the results of a running scan in batches as directories are read
+type scanStream struct {
+	batches   chan []FileInfo
+	stop      chan struct{}
+	stopOnce  sync.Once
+	err       error // set before batches is closed
+	delivered bool  // whether a message was already sent for this stream
+}
+
+// startScanStream starts scanning roots in the background
+func startScanStream(roots []string, pattern string, opts scanOptions) *scanStream {
+	s := &scanStream{
+		batches: make(chan []FileInfo, scanWorkers),
+		stop:    make(chan struct{}),
+	}
+
+	go func() {
+		s.err = walkRoots(roots, pattern, opts, func(batch []FileInfo) {
+			select {
+			case s.batches <- batch:
+			case <-s.stop: // Nobody is waiting for this scan anymore
+			}
+		})
+		close(s.batches)
+	}()
+
+	return s
+}
+
+// cancel discards the remaining results of the scan
+func (s *scanStream) cancel() {
+	s.stopOnce.Do(func() { close(s.stop) })
+}
+
+// next returns a command that waits for the next batch of the scan. Batches that are
+// already queued are merged so the list is not redrawn once per directory.
+func (s *scanStream) next() tea.Cmd {
+	return func() tea.Msg {
+		msg := scanCompleteMsg{scan: s, first: !s.delivered}
+		s.delivered = true
+
+		batch, ok := <-s.batches
+		for ok {
+			msg.files = append(msg.files, batch...)
+			select {
+			case batch, ok = <-s.batches:
+			default:
+				return msg
+			}
+		}
+
+		msg.done = true
+		msg.err = s.err
+		return msg
+	}
 }
 
 type debounceScanMsg struct{}
@@ -122,7 +249,7 @@ func InitialModel(initialQuery, initialPath string) model {
 		files:           []FileInfo{},
 		filteredFiles:   []FileInfo{},
 		cursor:          0,
-		selected:        make(map[int]bool),
+		selected:        make(map[string]FileInfo),
 		searchInput:     searchInput,
 		pathInput:       pathInput,
 		width:           80,
@@ -133,6 +260,10 @@ func InitialModel(initialQuery, initialPath string) model {
 		workDir:         workDir,
 		previewScroll:   0,
 		previewMode:     previewPlain, // Start with plain view (can be changed to previewHidden)
+		diffContext:     defaultDiffContext,
+		scanOpts:        defaultScanOptions(),
+		roots:           []string{workDir},
+		gitWorkers:      defaultGitWorkers,
 		lastSearchValue: initialQuery,
 		lastPathValue:   workDir,
 	}
@@ -143,13 +274,130 @@ func InitialModel(initialQuery, initialPath string) model {
 func (m model) Init() tea.Cmd {
 	return tea.Batch(
 		textinput.Blink,
-		func() tea.Msg {
-			files, err := scanFiles(m.workDir, m.searchInput.Value())
-			return scanCompleteMsg{files: files, err: err}
-		},
+		m.startScan(m.searchInput.Value()),
 	)
 }
 
+// startScan returns a command that scans the roots for pattern, streaming the results.
+// A scan that is still running is cancelled.
+func (m *model) startScan(pattern string) tea.Cmd {
+	if m.scan != nil {
+		m.scan.cancel()
+	}
+	m.scan = startScanStream(m.roots, pattern, m.scanOpts)
+	return m.scan.next()
+}
+
+// changeRoots sets the scan roots from a comma-separated list of directories.
+// The first root becomes the working directory.
+func (m *model) changeRoots(value string) error {
+	paths := splitRoots(value)
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+
+	roots := make([]string, 0, len(paths))
+	seen := make(map[string]bool, len(paths))
+	for _, path := range paths {
+		absPath, err := filepath.Abs(path)
+		if err != nil {
+			return fmt.Errorf("invalid path: %w", err)
+		}
+		if info, err := os.Stat(absPath); err != nil || !info.IsDir() {
+			return fmt.Errorf("path does not exist: %s", absPath)
+		}
+		if !seen[absPath] {
+			seen[absPath] = true
+			roots = append(roots, absPath)
+		}
+	}
+
+	if err := os.Chdir(roots[0]); err != nil {
+		return fmt.Errorf("path does not exist: %s", roots[0])
+	}
+	m.workDir = roots[0]
+	m.roots = roots
+	return nil
+}
+
+// displayPath returns the path shown for a file: relative to its root, prefixed
+// with "root:" when several roots are scanned. Files outside the current roots,
+// like targets selected before the path changed, are shown with their full path.
+func (m model) displayPath(file FileInfo) string {
+	if file.Root != "" && !filepath.IsAbs(file.Path) {
+		label, ok := rootLabels(m.roots)[file.Root]
+		if !ok {
+			return file.fullPath(m.workDir)
+		}
+		if len(m.roots) > 1 {
+			return label + ":" + file.Path
+		}
+	}
+	return file.Path
+}
+
+// isSource reports whether file is the selected source file
+func (m model) isSource(file FileInfo) bool {
+	return m.sourceFile != nil && m.sourceFile.fullPath(m.workDir) == file.fullPath(m.workDir)
+}
+
+// isSelected reports whether file is marked as a target
+func (m model) isSelected(file FileInfo) bool {
+	_, ok := m.selected[file.fullPath(m.workDir)]
+	return ok
+}
+
+// toggleSelected marks file as a target, or unmarks it if it already is one
+func (m *model) toggleSelected(file FileInfo) {
+	path := file.fullPath(m.workDir)
+	if _, ok := m.selected[path]; ok {
+		delete(m.selected, path)
+		return
+	}
+	m.selected[path] = file
+}
+
+// selectedFiles returns the target files sorted by absolute path
+func (m model) selectedFiles() []FileInfo {
+	paths := make([]string, 0, len(m.selected))
+	for path := range m.selected {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	files := make([]FileInfo, 0, len(paths))
+	for _, path := range paths {
+		files = append(files, m.selected[path])
+	}
+	return files
+}
+
+// hiddenSelectedCount returns the number of targets not shown by the current filter
+func (m model) hiddenSelectedCount() int {
+	visible := 0
+	for _, file := range m.filteredFiles {
+		if m.isSelected(file) {
+			visible++
+		}
+	}
+	return len(m.selected) - visible
+}
+
+// refreshSelection updates the source and targets with the scanned file info of the same paths,
+// so sizes and root labels stay current. Files that are no longer scanned stay selected.
+func (m *model) refreshSelection() {
+	for _, file := range m.files {
+		path := file.fullPath(m.workDir)
+		if _, ok := m.selected[path]; ok {
+			m.selected[path] = file
+		}
+		if m.isSource(file) {
+			source := file
+			m.sourceFile = &source
+		}
+	}
+}
+
 // startDebounceTimer starts or restarts the debounce timer
 func (m *model) startDebounceTimer() tea.Cmd {
 	// Stop existing timer if any
@@ -183,29 +431,33 @@ func (m *model) triggerScanIfNeeded() tea.Cmd {
 
 	// Update workDir if path changed
 	if currentPath != m.lastPathValue {
-		absPath, err := filepath.Abs(currentPath)
-		if err != nil {
-			m.err = fmt.Errorf("invalid path: %w", err)
+		if err := m.changeRoots(currentPath); err != nil {
+			m.err = err
 			return nil
 		}
-		if err := os.Chdir(absPath); err != nil {
-			m.err = fmt.Errorf("path does not exist: %s", absPath)
-			return nil
-		}
-		m.workDir = absPath
 	}
 
 	// Update tracking values after successful path change
 	m.lastSearchValue = currentSearch
 	m.lastPathValue = currentPath
 
-	return func() tea.Msg {
-		files, err := scanFiles(m.workDir, currentSearch)
-		return scanCompleteMsg{files: files, err: err}
-	}
+	return m.startScan(currentSearch)
 }
 
+// Update handles msg and then loads the preview of the file under the cursor if it changed
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	next, cmd := m.update(msg)
+	switch next := next.(type) {
+	case model:
+		next.refreshPreview()
+		return next, cmd
+	case *model:
+		next.refreshPreview()
+	}
+	return next, cmd
+}
+
+func (m model) update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
@@ -213,13 +465,20 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, nil
 
 	case scanCompleteMsg:
-		if msg.err != nil {
-			m.err = msg.err
+		if msg.scan == nil {
+			if msg.err != nil {
+				m.err = msg.err
+				return m, nil
+			}
+			m.files = msg.files
+			m.filterFiles()
+			m.refreshSelection()
+			if m.manifestPending {
+				m.applyManifestGroup()
+			}
 			return m, nil
 		}
-		m.files = msg.files
-		m.filterFiles()
-		return m, nil
+		return m.applyScanBatch(msg)
 
 	case debounceScanMsg:
 		// Debounce timer fired - trigger scan if values have changed
@@ -232,26 +491,17 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 
 			// Update workDir if path changed
 			if currentPath != m.lastPathValue {
-				absPath, err := filepath.Abs(currentPath)
-				if err != nil {
-					m.err = fmt.Errorf("invalid path: %w", err)
-					return m, nil
-				}
-				if err := os.Chdir(absPath); err != nil {
-					m.err = fmt.Errorf("path does not exist: %s", absPath)
+				if err := m.changeRoots(currentPath); err != nil {
+					m.err = err
 					return m, nil
 				}
-				m.workDir = absPath
 			}
 
 			// Update tracking values after successful path change
 			m.lastSearchValue = currentSearch
 			m.lastPathValue = currentPath
 
-			return m, func() tea.Msg {
-				files, err := scanFiles(m.workDir, currentSearch)
-				return scanCompleteMsg{files: files, err: err}
-			}
+			return m, m.startScan(currentSearch)
 		}
 		return m, nil
 
@@ -261,13 +511,60 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m.updateSelect(msg)
 		case modeConfirm:
 			return m.updateConfirm(msg)
+		case modeProgress:
+			return m.updateProgress(msg)
+		}
+
+	case syncProgressMsg:
+		return m.applySyncProgress(msg)
+
+	case spinner.TickMsg:
+		if m.run != nil && !m.run.done {
+			var cmd tea.Cmd
+			m.spinner, cmd = m.spinner.Update(msg)
+			return m, cmd
 		}
 	}
 
 	return m, nil
 }
 
+// applyScanBatch adds a batch of streamed scan results to the list and waits for the next one
+func (m model) applyScanBatch(msg scanCompleteMsg) (tea.Model, tea.Cmd) {
+	if msg.scan != m.scan {
+		return m, nil // Results of a scan that was replaced by a newer one
+	}
+
+	if msg.first {
+		m.files = nil
+	}
+	if len(msg.files) > 0 {
+		m.files = mergeFiles(m.files, msg.files)
+	}
+	m.filterFiles()
+
+	if !msg.done {
+		return m, m.scan.next()
+	}
+
+	m.scan = nil
+	if msg.err != nil {
+		m.err = msg.err
+		return m, nil
+	}
+	m.refreshSelection()
+	if m.manifestPending {
+		m.applyManifestGroup()
+	}
+	return m, nil
+}
+
 func (m *model) updateSelect(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	// The scan settings panel takes all keys while it is open
+	if m.scanSettings != nil {
+		return m.updateScanSettings(msg)
+	}
+
 	// Handle input field updates FIRST when focused (before command keys)
 	// This prevents keys like 's', 'k', 'j', etc. from being intercepted
 	if m.focus == focusPath || m.focus == focusSearch {
@@ -277,7 +574,7 @@ func (m *model) updateSelect(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			return m, tea.Quit
 		case "ctrl+p":
 			// Cycle through preview modes (even when in input fields)
-			m.previewMode = (m.previewMode + 1) % 3 // Cycle: hidden -> plain -> diff -> hidden
+			m.previewMode = (m.previewMode + 1) % previewModeCount // Cycle: hidden -> plain -> diff -> side-by-side -> hidden
 			m.previewScroll = 0
 			return m, nil
 		case "pagedown", "ctrl+d", "end":
@@ -333,16 +630,10 @@ func (m *model) updateSelect(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			m.err = nil
 
 			newPath := m.pathInput.Value()
-			absPath, err := filepath.Abs(newPath)
-			if err != nil {
-				m.err = fmt.Errorf("invalid path: %w", err)
-				return m, nil
-			}
-			if err := os.Chdir(absPath); err != nil {
-				m.err = fmt.Errorf("path does not exist: %s", absPath)
+			if err := m.changeRoots(newPath); err != nil {
+				m.err = err
 				return m, nil
 			}
-			m.workDir = absPath
 			m.lastPathValue = newPath
 			m.err = nil
 
@@ -357,32 +648,20 @@ func (m *model) updateSelect(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 				m.searchInput.Blur()
 			}
 
-			return m, func() tea.Msg {
-				files, err := scanFiles(m.workDir, m.searchInput.Value())
-				return scanCompleteMsg{files: files, err: err}
-			}
+			return m, m.startScan(m.searchInput.Value())
 		case "ctrl+r":
 			// Reload files
 			// Clear previous errors first
 			m.err = nil
 
 			newPath := m.pathInput.Value()
-			absPath, err := filepath.Abs(newPath)
-			if err != nil {
-				m.err = fmt.Errorf("invalid path: %w", err)
-				return m, nil
-			}
-			if err := os.Chdir(absPath); err != nil {
-				m.err = fmt.Errorf("path does not exist: %s", absPath)
+			if err := m.changeRoots(newPath); err != nil {
+				m.err = err
 				return m, nil
 			}
-			m.workDir = absPath
 			m.lastPathValue = newPath
 			m.err = nil
-			return m, func() tea.Msg {
-				files, err := scanFiles(m.workDir, m.searchInput.Value())
-				return scanCompleteMsg{files: files, err: err}
-			}
+			return m, m.startScan(m.searchInput.Value())
 		default:
 			// Let the input handle all other keys (including typing)
 			if m.focus == focusSearch {
@@ -464,7 +743,7 @@ func (m *model) updateSelect(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 
 	case "p", "ctrl+p":
 		// Cycle through preview modes (works in any focus mode)
-		m.previewMode = (m.previewMode + 1) % 3 // Cycle: hidden -> plain -> diff -> hidden
+		m.previewMode = (m.previewMode + 1) % previewModeCount // Cycle: hidden -> plain -> diff -> side-by-side -> hidden
 		m.previewScroll = 0
 		return m, nil
 
@@ -487,32 +766,51 @@ func (m *model) updateSelect(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		}
 		return m, nil
 
+	case "+", "=":
+		// Show more context lines around changes in diff mode
+		if m.showsDiff() {
+			m.diffContext++
+			m.previewScroll = 0
+		}
+		return m, nil
+
+	case "-":
+		// Show fewer context lines around changes in diff mode
+		if m.showsDiff() && m.diffContext > 0 {
+			m.diffContext--
+			m.previewScroll = 0
+		}
+		return m, nil
+
+	case "o":
+		// Open the scan settings panel (depth, excludes, hidden directories)
+		if m.focus == focusList {
+			m.openScanSettings()
+		}
+		return m, nil
+
+	case "w":
+		// Cycle intra-line highlighting in diff mode: word -> char -> off
+		if m.showsDiff() {
+			m.intraLine = (m.intraLine + 1) % intraLineModeCount
+		}
+		return m, nil
+
 	case "ctrl+r":
 		// Reload: change to the path and rescan files
 		// Clear previous errors first
 		m.err = nil
 
 		newPath := m.pathInput.Value()
-		absPath, err := filepath.Abs(newPath)
-		if err != nil {
-			m.err = fmt.Errorf("invalid path: %w", err)
+		if err := m.changeRoots(newPath); err != nil {
+			m.err = err
 			return m, nil
 		}
-
-		if err := os.Chdir(absPath); err != nil {
-			m.err = fmt.Errorf("path does not exist: %s", absPath)
-			return m, nil
-		}
-
-		m.workDir = absPath
 		m.lastPathValue = newPath
 		m.err = nil
 
 		// Rescan files in new directory
-		return m, func() tea.Msg {
-			files, err := scanFiles(m.workDir, m.searchInput.Value())
-			return scanCompleteMsg{files: files, err: err}
-		}
+		return m, m.startScan(m.searchInput.Value())
 
 	case "up", "k":
 		if m.focus == focusList {
@@ -535,12 +833,20 @@ func (m *model) updateSelect(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		if m.focus == focusList && m.cursor < len(m.filteredFiles) {
 			file := m.filteredFiles[m.cursor]
 			m.sourceFile = &file
+			m.activeGroup = nil
+		}
+
+	case "g":
+		// Preselect the next manifest group
+		if m.focus == focusList && m.manifest != nil {
+			m.manifestGroup = (m.manifestGroup + 1) % len(m.manifest.Groups)
+			m.applyManifestGroup()
 		}
 
 	case " ": // Space
 		// Toggle target selection (when on file list)
 		if m.focus == focusList && m.cursor < len(m.filteredFiles) {
-			m.selected[m.cursor] = !m.selected[m.cursor]
+			m.toggleSelected(m.filteredFiles[m.cursor])
 		}
 
 	case "enter":
@@ -572,9 +878,8 @@ func (m *model) updateConfirm(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 			return m, nil
 		case "shift+tab":
 			// Move to previous field
-			m.confirmFocus = focusBranchName
+			m.confirmFocus = focusFetchToggle
 			m.commitMsgInput.Blur()
-			m.branchNameInput.Focus()
 			return m, nil
 		default:
 			// Let textarea handle the input
@@ -592,20 +897,15 @@ func (m *model) updateConfirm(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		case "esc":
 			m.mode = modeSelect
 			return m, nil
-		case "tab":
-			m.confirmFocus = focusCommitMsg
+		case "tab", "enter":
+			m.confirmFocus = focusBaseRef
 			m.branchNameInput.Blur()
-			m.commitMsgInput.Focus()
+			m.baseRefInput.Focus()
 			return m, nil
 		case "shift+tab":
-			m.confirmFocus = focusGitEnabled
-			m.branchNameInput.Blur()
-			return m, nil
-		case "enter":
-			// Move to commit message
-			m.confirmFocus = focusCommitMsg
+			m.confirmFocus = focusTicket
 			m.branchNameInput.Blur()
-			m.commitMsgInput.Focus()
+			m.ticketInput.Focus()
 			return m, nil
 		default:
 			m.branchNameInput, cmd = m.branchNameInput.Update(msg)
@@ -613,6 +913,56 @@ func (m *model) updateConfirm(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		}
 	}
 
+	// Handle ticket input when focused
+	if m.confirmFocus == focusTicket {
+		var cmd tea.Cmd
+		switch msg.String() {
+		case "ctrl+c":
+			return m, tea.Quit
+		case "esc":
+			m.mode = modeSelect
+			return m, nil
+		case "tab", "enter":
+			m.confirmFocus = focusBranchName
+			m.ticketInput.Blur()
+			m.branchNameInput.Focus()
+			return m, nil
+		case "shift+tab":
+			m.confirmFocus = focusGitOnlyToggle
+			m.ticketInput.Blur()
+			return m, nil
+		default:
+			m.ticketInput, cmd = m.ticketInput.Update(msg)
+			return m, cmd
+		}
+	}
+
+	// Handle base ref input when focused; the per-repository bases update when leaving it
+	if m.confirmFocus == focusBaseRef {
+		var cmd tea.Cmd
+		switch msg.String() {
+		case "ctrl+c":
+			return m, tea.Quit
+		case "esc":
+			m.mode = modeSelect
+			return m, nil
+		case "tab", "enter":
+			m.confirmFocus = focusFetchToggle
+			m.baseRefInput.Blur()
+			m.resolveRepoBases()
+			return m, nil
+		case "shift+tab":
+			m.confirmFocus = focusBranchName
+			m.baseRefInput.Blur()
+			m.branchNameInput.Focus()
+			m.resolveRepoBases()
+			return m, nil
+		default:
+			m.baseRefInput, cmd = m.baseRefInput.Update(msg)
+			return m, cmd
+		}
+	}
+
 	// Handle other keys
 	switch msg.String() {
 	case "ctrl+c", "q":
@@ -634,23 +984,35 @@ func (m *model) updateConfirm(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		case focusCopyButton:
 			m.confirmFocus = focusCancelButton
 		case focusCancelButton:
+			switch {
+			case m.guardsDirtyTargets():
+				m.confirmFocus = focusDirtyTargets
+			case m.gitEnabled:
+				m.confirmFocus = focusGitEnabled
+			default:
+				// When git disabled, cycle back to copy button
+				m.confirmFocus = focusCopyButton
+			}
+		case focusDirtyTargets:
 			if m.gitEnabled {
 				m.confirmFocus = focusGitEnabled
 			} else {
-				// When git disabled, cycle back to copy button
 				m.confirmFocus = focusCopyButton
 			}
 		case focusGitEnabled:
-			m.confirmFocus = focusBranchName
-			m.branchNameInput.Focus()
-		case focusBranchName:
+			m.confirmFocus = focusGitOnlyToggle
+		case focusGitOnlyToggle:
+			m.confirmFocus = focusTicket
+			m.ticketInput.Focus()
+		case focusFetchToggle:
 			m.confirmFocus = focusCommitMsg
-			m.branchNameInput.Blur()
 			m.commitMsgInput.Focus()
 		case focusCommitMsg:
 			m.confirmFocus = focusPushToggle
 			m.commitMsgInput.Blur()
 		case focusPushToggle:
+			m.confirmFocus = focusPRToggle
+		case focusPRToggle:
 			m.confirmFocus = focusCopyButton
 		}
 		return m, nil
@@ -659,26 +1021,35 @@ func (m *model) updateConfirm(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		// Cycle focus backward
 		switch m.confirmFocus {
 		case focusCopyButton:
-			if m.gitEnabled {
-				m.confirmFocus = focusPushToggle
-			} else {
+			switch {
+			case m.gitEnabled:
+				m.confirmFocus = focusPRToggle
+			case m.guardsDirtyTargets():
+				m.confirmFocus = focusDirtyTargets
+			default:
 				// When git disabled, cycle back to cancel button
 				m.confirmFocus = focusCancelButton
 			}
 		case focusCancelButton:
 			m.confirmFocus = focusCopyButton
-		case focusGitEnabled:
+		case focusDirtyTargets:
 			m.confirmFocus = focusCancelButton
-		case focusBranchName:
+		case focusGitEnabled:
+			if m.guardsDirtyTargets() {
+				m.confirmFocus = focusDirtyTargets
+			} else {
+				m.confirmFocus = focusCancelButton
+			}
+		case focusGitOnlyToggle:
 			m.confirmFocus = focusGitEnabled
-			m.branchNameInput.Blur()
-		case focusCommitMsg:
-			m.confirmFocus = focusBranchName
-			m.commitMsgInput.Blur()
-			m.branchNameInput.Focus()
+		case focusFetchToggle:
+			m.confirmFocus = focusBaseRef
+			m.baseRefInput.Focus()
 		case focusPushToggle:
 			m.confirmFocus = focusCommitMsg
 			m.commitMsgInput.Focus()
+		case focusPRToggle:
+			m.confirmFocus = focusPushToggle
 		}
 		return m, nil
 
@@ -687,58 +1058,78 @@ func (m *model) updateConfirm(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		switch m.confirmFocus {
 		case focusGitEnabled:
 			m.gitEnabled = !m.gitEnabled
+		case focusGitOnlyToggle:
+			m.gitOnly = !m.gitOnly
+		case focusFetchToggle:
+			m.fetchBase = !m.fetchBase
+			m.resolveRepoBases()
 		case focusPushToggle:
 			m.shouldPush = !m.shouldPush
+			// Pull requests need a pushed branch
+			if !m.shouldPush {
+				m.prOpts.Enabled = false
+			}
+		case focusPRToggle:
+			m.prOpts.Enabled = !m.prOpts.Enabled
+			if m.prOpts.Enabled {
+				m.shouldPush = true
+			}
+		case focusDirtyTargets:
+			// Cycle skip -> stash -> abort
+			d := &m.dirty[m.dirtyCursor]
+			d.action = d.action%dirtyAbort + 1
+		}
+		return m, nil
+
+	case "up", "k", "down", "j", "s", "t", "a":
+		if m.confirmFocus != focusDirtyTargets {
+			return m, nil
+		}
+		switch msg.String() {
+		case "up", "k":
+			if m.dirtyCursor > 0 {
+				m.dirtyCursor--
+			}
+		case "down", "j":
+			if m.dirtyCursor < len(m.dirty)-1 {
+				m.dirtyCursor++
+			}
+		case "s":
+			m.dirty[m.dirtyCursor].action = dirtySkip
+		case "t":
+			m.dirty[m.dirtyCursor].action = dirtyStash
+		case "a":
+			m.dirty[m.dirtyCursor].action = dirtyAbort
 		}
 		return m, nil
 
 	case "enter":
 		// Execute on copy button or cancel button
 		if m.confirmFocus == focusCopyButton {
-			// Validate branch name if git is enabled
+			// Validate the rendered branch name and commit message if git is enabled
 			if m.gitEnabled {
-				branchName := m.branchNameInput.Value()
-				if err := validateBranchName(branchName); err != nil {
-					m.err = fmt.Errorf("Invalid branch name: %w", err)
+				if _, _, err := m.renderGitFields(); err != nil {
+					m.err = err
 					return m, nil
 				}
 			}
 
-			// Perform the copy operation
-			err := m.copySourceToTargets()
-			if err != nil {
-				m.err = err
-				return m, nil
-			}
-
-			// Generate summary
-			m.exitSummary = m.generateExitSummary()
-
-			// Perform git workflow if enabled
-			if m.gitEnabled && len(m.gitRepos) > 0 {
-				branchName := m.branchNameInput.Value()
-				commitMsg := m.commitMsgInput.Value()
-
-				successRepos, errors := performGitWorkflow(m.gitRepos, branchName, commitMsg, m.shouldPush)
-
-				// Handle errors
-				if len(errors) > 0 {
-					errMsg := "Git workflow errors:\n"
-					for _, err := range errors {
-						errMsg += fmt.Sprintf("- %v\n", err)
-					}
-					if len(successRepos) > 0 {
-						errMsg += fmt.Sprintf("\nSuccessfully committed to %d repositories", len(successRepos))
-					}
-					m.err = fmt.Errorf("%s", errMsg)
+			if m.gitEnabled && m.gitOnly {
+				// Only the worktrees are written, so every target needs a repository
+				if n := len(m.selected) - countFiles(m.gitRepos); n > 0 {
+					m.err = fmt.Errorf("git only: %d target(s) are not in a git repository", n)
+					return m, nil
+				}
+			} else {
+				// Targets with uncommitted changes need a decision before anything is written
+				if err := m.applyDirtyChoices(); err != nil {
+					m.err = err
 					return m, nil
 				}
-
-				// Add git info to summary
-				m.exitSummary += m.generateGitSummary(successRepos, branchName)
 			}
 
-			return m, tea.Quit
+			// Copy and run the git workflow in the background, showing progress
+			return m, m.startSync()
 		} else if m.confirmFocus == focusCancelButton {
 			// Cancel and go back to selection
 			m.mode = modeSelect
@@ -750,6 +1141,72 @@ func (m *model) updateConfirm(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+// setManifest loads a manifest into the model and schedules preselection of
+// the named group (or the first group) once files have been scanned
+func (m *model) setManifest(manifest *Manifest, groupName string) error {
+	m.manifest = manifest
+	m.manifestGroup = 0
+	if groupName != "" {
+		found := false
+		for i, group := range manifest.Groups {
+			if group.Name == groupName {
+				m.manifestGroup = i
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("manifest has no group named %q", groupName)
+		}
+	}
+	m.manifestPending = true
+	return nil
+}
+
+// applyManifestGroup marks the current manifest group's source and targets in the file list
+func (m *model) applyManifestGroup() {
+	m.manifestPending = false
+	if m.manifest == nil || len(m.manifest.Groups) == 0 {
+		return
+	}
+
+	group := m.manifest.Groups[m.manifestGroup]
+	source, targets, err := m.manifest.resolveGroup(group, m.workDir, m.files)
+	if err != nil {
+		m.err = err
+		return
+	}
+
+	// Map absolute paths to scanned files
+	index := make(map[string]FileInfo, len(m.files))
+	for _, file := range m.files {
+		index[file.fullPath(m.workDir)] = file
+	}
+
+	m.err = nil
+	m.sourceFile = nil
+	m.selected = make(map[string]FileInfo)
+	m.activeGroup = &group
+
+	if file, ok := index[source]; ok {
+		m.sourceFile = &file
+	} else {
+		m.err = fmt.Errorf("manifest group %q: source %s is not in the file list", group.Name, group.Source)
+	}
+
+	missing := 0
+	for _, target := range targets {
+		if file, ok := index[target]; ok {
+			m.selected[target] = file
+		} else {
+			missing++
+		}
+	}
+	if missing > 0 && m.err == nil {
+		m.err = fmt.Errorf("manifest group %q: %d target(s) are not in the file list", group.Name, missing)
+	}
+}
+
 func (m *model) filterFiles() {
 	query := strings.ToLower(m.searchInput.Value())
 	if query == "" {
@@ -760,7 +1217,7 @@ func (m *model) filterFiles() {
 
 	m.filteredFiles = []FileInfo{}
 	for _, file := range m.files {
-		if matchesFilePattern(file.Path, query) {
+		if matchesFilePattern(m.displayPath(file), query) {
 			m.filteredFiles = append(m.filteredFiles, file)
 		}
 	}
@@ -820,6 +1277,8 @@ func (m model) View() string {
 		baseView = m.viewSelect()
 	case modeConfirm:
 		baseView = m.viewConfirm()
+	case modeProgress:
+		baseView = m.viewProgress()
 	default:
 		return ""
 	}
@@ -828,6 +1287,9 @@ func (m model) View() string {
 	if m.showHelp {
 		return m.renderHelpOverlay()
 	}
+	if m.mode == modeSelect && m.scanSettings != nil {
+		return m.renderScanSettings()
+	}
 
 	return baseView
 }
@@ -865,20 +1327,28 @@ func (m model) viewSelect() string {
 		hints = "SEARCH: " + strings.Join(searchHints, " • ")
 	case focusList:
 		fileHints := []string{"↑/↓ or k/j: navigate", "s: set source", "SPACE: toggle target"}
+		if m.manifest != nil {
+			fileHints = append(fileHints, "g: next manifest group")
+		}
+		fileHints = append(fileHints, "o: scan settings")
 		if m.sourceFile != nil && len(m.selected) > 0 {
 			fileHints = append(fileHints, "ENTER: confirm sync")
 		}
 		// Show preview mode hint
 		previewModeStr := map[previewMode]string{
-			previewHidden: "preview plain",
-			previewPlain:  "preview diff",
-			previewDiff:   "hide preview",
+			previewHidden:     "preview plain",
+			previewPlain:      "preview diff",
+			previewDiff:       "preview side-by-side",
+			previewSideBySide: "hide preview",
 		}[m.previewMode]
 		fileHints = append(fileHints, fmt.Sprintf("p/CTRL-P: %s", previewModeStr))
 
 		if m.previewMode != previewHidden {
 			fileHints = append(fileHints, "CTRL-U/D: scroll preview")
 		}
+		if m.showsDiff() {
+			fileHints = append(fileHints, "+/-: context lines", fmt.Sprintf("w: %s highlight", m.intraLine))
+		}
 		fileHints = append(fileHints, "TAB: next", "?: help", "q: quit")
 		hints = "FILE LIST: " + strings.Join(fileHints, " • ")
 	}
@@ -929,7 +1399,11 @@ func (m model) viewSelect() string {
 	// Source file indicator
 	if m.sourceFile != nil {
 		sourceStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("10")).Bold(true)
-		b.WriteString(sourceStyle.Render(fmt.Sprintf("Source: %s", m.sourceFile.Path)) + "\n\n")
+		source := fmt.Sprintf("Source: %s", m.displayPath(*m.sourceFile))
+		if m.activeGroup != nil {
+			source += fmt.Sprintf("  (manifest group %q %d/%d)", m.activeGroup.Name, m.manifestGroup+1, len(m.manifest.Groups))
+		}
+		b.WriteString(sourceStyle.Render(source) + "\n\n")
 	}
 
 	// Determine layout based on preview mode
@@ -979,10 +1453,10 @@ func (m model) viewSelect() string {
 		}
 
 		marker := " "
-		if m.selected[i] {
+		if m.isSelected(file) {
 			marker = "T" // Target
 		}
-		if m.sourceFile != nil && m.sourceFile.Path == file.Path {
+		if m.isSource(file) {
 			marker = "S" // Source
 		}
 
@@ -990,10 +1464,10 @@ func (m model) viewSelect() string {
 		if m.cursor == i {
 			style = style.Background(lipgloss.Color("240")).Bold(true)
 		}
-		if m.selected[i] {
+		if m.isSelected(file) {
 			style = style.Foreground(lipgloss.Color("11"))
 		}
-		if m.sourceFile != nil && m.sourceFile.Path == file.Path {
+		if m.isSource(file) {
 			style = style.Foreground(lipgloss.Color("10"))
 		}
 
@@ -1002,7 +1476,7 @@ func (m model) viewSelect() string {
 			cursor,
 			marker,
 			pathDisplayWidth,
-			truncate(file.Path, pathDisplayWidth),
+			truncate(m.displayPath(file), pathDisplayWidth),
 			formatSize(file.Size),
 			file.Modified.Format("2006-01-02 15:04"),
 		)
@@ -1012,8 +1486,11 @@ func (m model) viewSelect() string {
 
 	// Footer
 	footerStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("240"))
-	fileListContent.WriteString(footerStyle.Render(fmt.Sprintf("\nShowing %d of %d files | Targets: %d",
-		len(m.filteredFiles), len(m.files), len(m.selected))))
+	footer := fmt.Sprintf("\nShowing %d of %d files | Targets: %d", len(m.filteredFiles), len(m.files), len(m.selected))
+	if hidden := m.hiddenSelectedCount(); hidden > 0 {
+		footer += fmt.Sprintf(" (%d hidden by filter)", hidden)
+	}
+	fileListContent.WriteString(footerStyle.Render(footer))
 
 	// Wrap file list in border
 	listBox := lipgloss.NewStyle().
@@ -1036,44 +1513,116 @@ func (m model) viewSelect() string {
 	return b.String()
 }
 
-// renderPreview renders the file preview panel
+// previewData is the content of the preview panel. It is loaded when the previewed file, the
+// source or the sync settings change, so that redrawing never reads files or runs git.
+type previewData struct {
+	key      string              // file, source and settings the data was loaded for
+	content  []byte              // previewed file, only its managed section in section mode
+	source   []byte              // source rendered for the previewed file, in diff modes
+	keyLines []string            // key-level changes shown above the diff of a keyed file
+	conflict *mergeConflictError // conflicts of merging the source into the previewed file
+	errMsg   string              // shown instead of the preview if loading failed
+}
+
+// refreshPreview loads the preview of the file under the cursor unless it is already loaded
+func (m *model) refreshPreview() {
+	if m.previewMode == previewHidden || len(m.filteredFiles) == 0 || m.cursor >= len(m.filteredFiles) {
+		return
+	}
+	filePath := m.filteredFiles[m.cursor].fullPath(m.workDir)
+	key := filePath
+	diff := m.showsDiff() && m.sourceFile != nil
+	tmpl := m.sourceTemplate()
+	if diff {
+		key = fmt.Sprintf("%s\x00%s\x00%+v", filePath, m.sourceFile.fullPath(m.workDir), tmpl)
+	}
+	if m.preview.key == key {
+		return
+	}
+	m.preview = previewData{key: key}
+
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		m.preview.errMsg = fmt.Sprintf("Error reading file: %v", err)
+		return
+	}
+	m.preview.content = content
+	if !diff {
+		return
+	}
+
+	// Diff against the source, rendered for this file if the source is a template
+	sourceFilePath := m.sourceFile.fullPath(m.workDir)
+	sourceContent, err := os.ReadFile(sourceFilePath)
+	if err != nil {
+		m.preview.errMsg = fmt.Sprintf("Error reading source file: %v", err)
+		return
+	}
+	if filePath != sourceFilePath {
+		// Merge conflicts are shown with their markers; writing them needs --force
+		sourceContent, err = tmpl.render(sourceContent, filePath)
+		if err != nil && !errors.As(err, &m.preview.conflict) {
+			m.preview.errMsg = fmt.Sprintf("Error rendering source for this file: %v", err)
+			return
+		}
+		m.preview.keyLines = renderKeyDiff(tmpl, sourceContent, content)
+		// Only the managed section is compared in section mode
+		sourceContent, m.preview.content = tmpl.managedRegion(sourceContent), tmpl.managedRegion(content)
+	}
+	m.preview.source = sourceContent
+}
+
+// renderPreview renders the file preview panel from the data loaded by refreshPreview
 func (m model) renderPreview() string {
 	if len(m.filteredFiles) == 0 || m.cursor >= len(m.filteredFiles) {
 		return m.renderEmptyPreview()
 	}
+	if m.preview.errMsg != "" {
+		return m.renderPreviewError(m.preview.errMsg)
+	}
 
 	currentFile := m.filteredFiles[m.cursor]
-	filePath := filepath.Join(m.workDir, currentFile.Path)
-
-	// Read file content
-	content, err := os.ReadFile(filePath)
-	if err != nil {
-		return m.renderPreviewError(fmt.Sprintf("Error reading file: %v", err))
-	}
+	content := m.preview.content
 
 	// Determine what to show based on preview mode
 	var lines []string
 	var headerTitle string
+	previewWidth := m.width / 2
+	sideBySide := m.sideBySideActive() && m.sourceFile != nil
 
-	if m.previewMode == previewDiff && m.sourceFile != nil {
-		// Show diff against source file
-		sourceFilePath := filepath.Join(m.workDir, m.sourceFile.Path)
-		sourceContent, err := os.ReadFile(sourceFilePath)
-		if err != nil {
-			return m.renderPreviewError(fmt.Sprintf("Error reading source file: %v", err))
-		}
+	if m.showsDiff() && m.sourceFile != nil {
+		tmpl := m.sourceTemplate()
+		sourceContent, keyLines, conflict := m.preview.source, m.preview.keyLines, m.preview.conflict
 
 		// Generate diff
-		lines = m.generateDiff(string(sourceContent), string(content))
-		headerTitle = fmt.Sprintf(" Preview (diff): %s → %s ", m.sourceFile.Path, currentFile.Path)
+		switch {
+		case sideBySide:
+			lines = m.generateSideBySide(string(sourceContent), string(content), previewWidth-3)
+			headerTitle = fmt.Sprintf(" Preview (side-by-side, %d context): %s → %s ", m.diffContext, m.displayPath(*m.sourceFile), m.displayPath(currentFile))
+		case m.previewMode == previewSideBySide:
+			lines = m.generateDiff(string(sourceContent), string(content))
+			headerTitle = fmt.Sprintf(" Preview (diff, too narrow for side-by-side): %s → %s ", m.displayPath(*m.sourceFile), m.displayPath(currentFile))
+		default:
+			lines = m.generateDiff(string(sourceContent), string(content))
+			headerTitle = fmt.Sprintf(" Preview (diff, %d context): %s → %s ", m.diffContext, m.displayPath(*m.sourceFile), m.displayPath(currentFile))
+		}
+		if tmpl != nil && tmpl.section != "" {
+			headerTitle += fmt.Sprintf("[section %s] ", tmpl.section)
+		}
+		if tmpl != nil && len(tmpl.keys) > 0 {
+			headerTitle += fmt.Sprintf("[keys %s] ", strings.Join(tmpl.keys, ", "))
+		}
+		if conflict != nil {
+			headerTitle += fmt.Sprintf("[merge conflicts: %d] ", conflict.Conflicts)
+		}
+		lines = append(keyLines, lines...)
 	} else {
 		// Show plain file content
 		lines = strings.Split(string(content), "\n")
-		headerTitle = fmt.Sprintf(" Preview (plain): %s ", currentFile.Path)
+		headerTitle = fmt.Sprintf(" Preview (plain): %s ", m.displayPath(currentFile))
 	}
 
 	// Calculate preview dimensions
-	previewWidth := m.width / 2
 	// Match file list height to prevent overflow when joined horizontally
 	// File list uses m.height - 16, so preview should use same or less
 	previewHeight := m.height - 16
@@ -1103,22 +1652,42 @@ func (m model) renderPreview() string {
 	start := m.previewScroll
 	end := minInt(start+previewHeight, len(lines))
 
+	// Changed line pairs in unified mode get their differing words highlighted
+	var segments map[int][]lineSegment
+	if m.showsDiff() && m.sourceFile != nil && !sideBySide {
+		segments = pairChangedLines(lines, m.intraLine)
+	}
+
 	for i := start; i < end; i++ {
 		line := lines[i]
+		// Side-by-side lines are already fitted to the columns and styled
+		if sideBySide {
+			b.WriteString(contentStyle.Render(line) + "\n")
+			continue
+		}
+		if segs, ok := segments[i]; ok {
+			color := diffAddColor
+			if line[0] == '-' {
+				color = diffDeleteColor
+			}
+			segs = append([]lineSegment{{Text: line[:1]}}, segs...)
+			b.WriteString(contentStyle.Render(renderSegments(segs, previewWidth-3, color, false)) + "\n")
+			continue
+		}
 		// Truncate long lines
 		if len(line) > previewWidth-3 {
 			line = line[:previewWidth-6] + "..."
 		}
 
 		// Color diff lines if in diff mode
-		if m.previewMode == previewDiff && m.sourceFile != nil {
+		if m.showsDiff() && m.sourceFile != nil {
 			lineStyle := contentStyle
 			if line != "" {
 				switch line[0] {
 				case '+':
-					lineStyle = contentStyle.Foreground(lipgloss.Color("34")) // Darker green for additions (better contrast)
+					lineStyle = contentStyle.Foreground(diffAddColor)
 				case '-':
-					lineStyle = contentStyle.Foreground(lipgloss.Color("9")) // Red for deletions
+					lineStyle = contentStyle.Foreground(diffDeleteColor)
 				case '@':
 					lineStyle = contentStyle.Foreground(lipgloss.Color("12")) // Blue for context markers
 				}
@@ -1153,6 +1722,22 @@ func (m model) renderPreview() string {
 	return b.String()
 }
 
+// renderKeyDiff returns the key-level diff shown above the text diff of a keyed file,
+// followed by a blank line, or nil unless keys are mirrored
+func renderKeyDiff(tmpl *sourceTemplate, rendered, content []byte) []string {
+	changes, err := tmpl.keyDiff(rendered, content)
+	switch {
+	case err != nil:
+		return []string{fmt.Sprintf("@@ keys: %v @@", err), ""}
+	case tmpl == nil || len(tmpl.keys) == 0:
+		return nil
+	case len(changes) == 0:
+		return []string{"@@ keys: no changes @@", ""}
+	}
+	lines := []string{fmt.Sprintf("@@ keys: %d changed @@", len(changes))}
+	return append(append(lines, changes...), "")
+}
+
 func (m model) renderEmptyPreview() string {
 	previewWidth := m.width / 2
 	style := lipgloss.NewStyle().
@@ -1210,7 +1795,7 @@ func (m model) viewConfirm() string {
 	// Source indicator
 	if m.sourceFile != nil {
 		sourceStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("10")).Bold(true)
-		b.WriteString(sourceStyle.Render(fmt.Sprintf("Source: %s", m.sourceFile.Path)) + "\n\n")
+		b.WriteString(sourceStyle.Render(fmt.Sprintf("Source: %s", m.displayPath(*m.sourceFile))) + "\n\n")
 	}
 
 	// Split panel layout
@@ -1223,19 +1808,20 @@ func (m model) viewConfirm() string {
 
 	if m.sourceFile != nil {
 		fileListContent.WriteString("Source:\n")
-		fileListContent.WriteString(fmt.Sprintf("▶ %s\n", m.sourceFile.Path))
+		fileListContent.WriteString(fmt.Sprintf("▶ %s\n", m.displayPath(*m.sourceFile)))
 		fileListContent.WriteString(fmt.Sprintf("  %s\n\n", formatSize(m.sourceFile.Size)))
 	}
 
-	targetCount := 0
 	fileListContent.WriteString(fmt.Sprintf("Targets (%d):\n", len(m.selected)))
-	for idx := range m.selected {
-		if idx < len(m.filteredFiles) {
-			file := m.filteredFiles[idx]
-			fileListContent.WriteString(fmt.Sprintf("→ %s\n", file.Path))
-			fileListContent.WriteString(fmt.Sprintf("  %s\n", formatSize(file.Size)))
-			targetCount++
-		}
+	for _, file := range m.selectedFiles() {
+		fileListContent.WriteString(fmt.Sprintf("→ %s\n", m.displayPath(file)))
+		fileListContent.WriteString(fmt.Sprintf("  %s\n", formatSize(file.Size)))
+	}
+	if m.transactional {
+		fileListContent.WriteString("\nAll-or-nothing: no target is changed\nif any of them fails\n")
+	}
+	if m.guardsDirtyTargets() {
+		fileListContent.WriteString(m.renderDirtyTargets())
 	}
 
 	fileListBox := lipgloss.NewStyle().
@@ -1265,6 +1851,24 @@ func (m model) viewConfirm() string {
 
 	// Only show git fields if git is enabled
 	if m.gitEnabled {
+		// Git-only toggle
+		gitOnlyCheckbox := "[ ]"
+		if m.gitOnly {
+			gitOnlyCheckbox = "[✓]"
+		}
+		gitOnlyStyle := lipgloss.NewStyle()
+		if m.confirmFocus == focusGitOnlyToggle {
+			gitOnlyStyle = gitOnlyStyle.Background(lipgloss.Color("240")).Bold(true)
+		}
+		gitPanelContent.WriteString(gitOnlyStyle.Render(fmt.Sprintf("%s Git only (leave working trees untouched)", gitOnlyCheckbox)) + "\n\n")
+
+		// Ticket, available to the templates as {{.Ticket}}
+		ticketLabelStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("12"))
+		if m.confirmFocus == focusTicket {
+			ticketLabelStyle = ticketLabelStyle.Bold(true)
+		}
+		gitPanelContent.WriteString(ticketLabelStyle.Render("Ticket: ") + m.ticketInput.View() + "\n\n")
+
 		// Branch name
 		branchLabelStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("12"))
 		if m.confirmFocus == focusBranchName {
@@ -1283,6 +1887,35 @@ func (m model) viewConfirm() string {
 			Width(gitPanelWidth - 8)
 		gitPanelContent.WriteString(branchBox.Render(m.branchNameInput.View()) + "\n\n")
 
+		// Base ref
+		baseLabelStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("12"))
+		if m.confirmFocus == focusBaseRef {
+			baseLabelStyle = baseLabelStyle.Bold(true)
+		}
+		gitPanelContent.WriteString(baseLabelStyle.Render("Start From:") + "\n")
+
+		baseBorderColor := lipgloss.Color("240")
+		if m.confirmFocus == focusBaseRef {
+			baseBorderColor = lipgloss.Color("12")
+		}
+		baseBox := lipgloss.NewStyle().
+			BorderStyle(lipgloss.RoundedBorder()).
+			BorderForeground(baseBorderColor).
+			Padding(0, 1).
+			Width(gitPanelWidth - 8)
+		gitPanelContent.WriteString(baseBox.Render(m.baseRefInput.View()) + "\n")
+
+		// Fetch toggle
+		fetchCheckbox := "[ ]"
+		if m.fetchBase {
+			fetchCheckbox = "[✓]"
+		}
+		fetchStyle := lipgloss.NewStyle()
+		if m.confirmFocus == focusFetchToggle {
+			fetchStyle = fetchStyle.Background(lipgloss.Color("240")).Bold(true)
+		}
+		gitPanelContent.WriteString(fetchStyle.Render(fmt.Sprintf("%s Fetch origin first", fetchCheckbox)) + "\n\n")
+
 		// Commit message
 		commitLabelStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("12"))
 		if m.confirmFocus == focusCommitMsg {
@@ -1300,6 +1933,7 @@ func (m model) viewConfirm() string {
 			Padding(0, 1).
 			Width(gitPanelWidth - 8)
 		gitPanelContent.WriteString(commitBox.Render(m.commitMsgInput.View()) + "\n\n")
+		gitPanelContent.WriteString(m.renderGitPreview(gitPanelWidth - 8))
 
 		// Push toggle
 		pushCheckbox := "[ ]"
@@ -1310,13 +1944,33 @@ func (m model) viewConfirm() string {
 		if m.confirmFocus == focusPushToggle {
 			pushStyle = pushStyle.Background(lipgloss.Color("240")).Bold(true)
 		}
-		gitPanelContent.WriteString(pushStyle.Render(fmt.Sprintf("%s Push to origin after commit", pushCheckbox)) + "\n\n")
+		gitPanelContent.WriteString(pushStyle.Render(fmt.Sprintf("%s Push to origin after commit", pushCheckbox)) + "\n")
+
+		// Pull request toggle
+		prCheckbox := "[ ]"
+		if m.prOpts.Enabled {
+			prCheckbox = "[✓]"
+		}
+		prStyle := lipgloss.NewStyle()
+		if m.confirmFocus == focusPRToggle {
+			prStyle = prStyle.Background(lipgloss.Color("240")).Bold(true)
+		}
+		prLabel := "Open pull request after push"
+		if m.prOpts.Draft {
+			prLabel = "Open draft pull request after push"
+		}
+		gitPanelContent.WriteString(prStyle.Render(fmt.Sprintf("%s %s", prCheckbox, prLabel)) + "\n\n")
 
 		// Repository info
 		if len(m.gitRepos) > 0 {
 			gitPanelContent.WriteString(lipgloss.NewStyle().Foreground(lipgloss.Color("240")).Render(fmt.Sprintf("Repository: %d git repos detected", len(m.gitRepos))) + "\n")
-			for repo := range m.gitRepos {
-				gitPanelContent.WriteString(lipgloss.NewStyle().Foreground(lipgloss.Color("10")).Render(fmt.Sprintf("✓ %s", filepath.Base(repo))) + "\n")
+			for _, repo := range sortedRepos(m.gitRepos) {
+				base := m.repoBases[repo]
+				if strings.HasPrefix(base, "✗") {
+					gitPanelContent.WriteString(lipgloss.NewStyle().Foreground(lipgloss.Color("9")).Render(fmt.Sprintf("✗ %s (%s)", filepath.Base(repo), strings.TrimPrefix(base, "✗ "))) + "\n")
+					continue
+				}
+				gitPanelContent.WriteString(lipgloss.NewStyle().Foreground(lipgloss.Color("10")).Render(fmt.Sprintf("✓ %s (from %s)", filepath.Base(repo), base)) + "\n")
 			}
 		} else {
 			gitPanelContent.WriteString(lipgloss.NewStyle().Foreground(lipgloss.Color("9")).Render("✗ No git repositories detected") + "\n")
@@ -1380,21 +2034,32 @@ func (m model) viewConfirm() string {
 	return b.String()
 }
 
-func (m *model) copySourceToTargets() error {
-	if m.sourceFile == nil {
-		return fmt.Errorf("no source file selected")
+// copyToTarget copies the source, rendered for targetPath if tmpl is set, over targetPath and
+// returns the lines removed and added, or nil stats if the target did not exist before.
+// The original target is recorded in j, if set.
+func copyToTarget(sourcePath string, sourceContent []byte, targetPath string, tmpl *sourceTemplate, j *journal) (*[2]int, error) {
+	rendered, err := tmpl.render(sourceContent, targetPath)
+	if err != nil {
+		return nil, err
 	}
 
-	for idx := range m.selected {
-		if idx < len(m.filteredFiles) {
-			target := m.filteredFiles[idx]
-			if err := copyFile(m.sourceFile.Path, target.Path); err != nil {
-				return fmt.Errorf("failed to copy to %s: %w", target.Path, err)
-			}
-		}
+	// Record what the copy changes before overwriting the target
+	stats := changeStats(targetPath, rendered)
+	if err := mirrorTarget(sourcePath, sourceContent, targetPath, tmpl, j); err != nil {
+		return nil, err
 	}
+	return stats, nil
+}
 
-	return nil
+// changeStats returns the lines removed and added by writing content to targetPath,
+// or nil if the target does not exist
+func changeStats(targetPath string, content []byte) *[2]int {
+	targetContent, err := os.ReadFile(targetPath)
+	if err != nil {
+		return nil
+	}
+	removed, added := countChanges(unifiedDiff(string(targetContent), string(content), 0))
+	return &[2]int{removed, added}
 }
 
 // initGitWorkflow initializes git workflow fields when entering confirm mode
@@ -1406,11 +2071,16 @@ func (m *model) initGitWorkflow() {
 	m.branchNameInput.Width = 50
 
 	// Generate default branch name from source filename
-	sourceName := "filesync"
+	sourcePath := ""
 	if m.sourceFile != nil {
-		sourceName = normalizeBranchName(m.sourceFile.Path)
+		sourcePath = m.sourceFile.Path
+	}
+	templates := m.projectTemplates()
+	branchName := defaultBranchName(sourcePath)
+	if templates.Branch != "" {
+		branchName = templates.Branch
 	}
-	m.branchNameInput.SetValue(fmt.Sprintf("chore/filesync-%s", sourceName))
+	m.branchNameInput.SetValue(branchName)
 
 	// Initialize commit message textarea
 	m.commitMsgInput = textarea.New()
@@ -1421,50 +2091,151 @@ func (m *model) initGitWorkflow() {
 
 	// Generate default commit message
 	targetFiles := []string{}
-	for idx := range m.selected {
-		if idx < len(m.filteredFiles) {
-			targetFiles = append(targetFiles, m.filteredFiles[idx].Path)
-		}
+	for _, file := range m.selectedFiles() {
+		targetFiles = append(targetFiles, m.displayPath(file))
 	}
 
-	commitMsg := fmt.Sprintf("chore: Sync %s from source\n\nSynchronized from %s\nTarget files:\n",
-		filepath.Base(m.sourceFile.Path),
-		m.sourceFile.Path)
-	for _, target := range targetFiles {
-		commitMsg += fmt.Sprintf("- %s\n", target)
+	commitMsg := defaultCommitMessage(sourcePath, targetFiles)
+	if templates.CommitMessage != "" {
+		commitMsg = templates.CommitMessage
 	}
 	m.commitMsgInput.SetValue(commitMsg)
 
+	// Initialize ticket input and the variables of the branch and commit templates
+	m.ticketInput = textinput.New()
+	m.ticketInput.Placeholder = "e.g. OPS-42"
+	m.ticketInput.CharLimit = 50
+	m.ticketInput.Width = 20
+	m.ticketInput.SetValue(m.ticket)
+	if m.sourceFile != nil {
+		m.templateData = newGitTemplateData(m.sourceFile.fullPath(m.workDir), m.displayPath(*m.sourceFile), targetFiles, "")
+	}
+
+	// Initialize base ref input
+	m.baseRefInput = textinput.New()
+	m.baseRefInput.Placeholder = "default branch (or HEAD, a branch, tag or commit)"
+	m.baseRefInput.CharLimit = 100
+	m.baseRefInput.Width = 50
+	m.baseRefInput.SetValue(m.baseRef)
+
 	// Detect git repos for target files using extracted function
 	targetPaths := []string{}
-	for idx := range m.selected {
-		if idx < len(m.filteredFiles) {
-			targetPath := filepath.Join(m.workDir, m.filteredFiles[idx].Path)
-			targetPaths = append(targetPaths, targetPath)
-		}
+	for _, file := range m.selectedFiles() {
+		targetPaths = append(targetPaths, file.fullPath(m.workDir))
 	}
 
 	m.gitRepos = groupFilesByRepo(targetPaths)
+	m.dirty = findDirtyTargets(m.gitRepos)
+	m.dirtyCursor = 0
+	m.resolveRepoBases()
 
 	// Enable git by default if we have git repos
 	m.gitEnabled = len(m.gitRepos) > 0
-	m.shouldPush = false             // Safer default
+	m.shouldPush = m.prOpts.Enabled  // Safer default, unless pull requests were requested
 	m.confirmFocus = focusCopyButton // Start on copy button
 }
 
+// sourceTemplate returns the template of the active manifest group or of the --key or
+// --section flags, merging if --merge is set, or nil if the source is mirrored as is
+func (m model) sourceTemplate() *sourceTemplate {
+	if m.manifest != nil && m.activeGroup != nil {
+		if t := newSourceTemplate(m.manifest, *m.activeGroup); t != nil {
+			return t.withMerge(m.merge, m.force)
+		}
+	}
+	var t *sourceTemplate
+	switch {
+	case m.sourceFile == nil:
+		return nil
+	case len(m.keys) > 0:
+		t = newKeysTemplate(m.sourceFile.Path, m.keys, m.keyFormat)
+	case m.section != "":
+		t = newSectionTemplate(m.sourceFile.Path, m.section, m.insertSection)
+	}
+	return t.withMerge(m.merge, m.force)
+}
+
+// projectTemplates returns the branch and commit message templates of the manifest and
+// its active group, if any
+func (m model) projectTemplates() GitTemplates {
+	switch {
+	case m.manifest == nil:
+		return GitTemplates{}
+	case m.activeGroup != nil:
+		return m.manifest.gitTemplates(*m.activeGroup)
+	default:
+		return m.manifest.Git
+	}
+}
+
+// renderGitFields renders the branch name and commit message templates of the confirm screen
+func (m model) renderGitFields() (branch, message string, err error) {
+	data := m.templateData
+	data.Ticket = strings.TrimSpace(m.ticketInput.Value())
+
+	branch, err = renderBranchName(m.branchNameInput.Value(), data)
+	if err != nil {
+		return branch, "", fmt.Errorf("Invalid branch name: %w", err)
+	}
+	message, err = renderGitTemplate("commit message", m.commitMsgInput.Value(), data)
+	return branch, message, err
+}
+
+// renderGitPreview shows the rendered branch name and commit subject while either field
+// is a template, or why it cannot be rendered
+func (m model) renderGitPreview(width int) string {
+	if !strings.Contains(m.branchNameInput.Value(), "{{") && !strings.Contains(m.commitMsgInput.Value(), "{{") {
+		return ""
+	}
+
+	branch, message, err := m.renderGitFields()
+	if err != nil {
+		return lipgloss.NewStyle().Foreground(lipgloss.Color("9")).Width(width).Render(fmt.Sprintf("✗ %v", err)) + "\n\n"
+	}
+	subject, _, _ := strings.Cut(strings.TrimSpace(message), "\n")
+	previewStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("240")).Width(width)
+	return previewStyle.Render(fmt.Sprintf("Preview: %s\n         %s", branch, subject)) + "\n\n"
+}
+
+// resolveRepoBases resolves the base ref typed in the confirm screen for every repository.
+// Repositories where it cannot be found are shown with an error instead.
+func (m *model) resolveRepoBases() {
+	base := strings.TrimSpace(m.baseRefInput.Value())
+	m.repoBases = make(map[string]string, len(m.gitRepos))
+	for repo := range m.gitRepos {
+		ref, err := resolveBaseRef(repo, base, m.fetchBase)
+		switch {
+		case err != nil && m.fetchBase:
+			ref = base + " (after fetch)"
+		case err != nil:
+			ref = "✗ " + err.Error()
+		}
+		m.repoBases[repo] = ref
+	}
+}
+
 // generateExitSummary creates a summary of files copied
 func (m *model) generateExitSummary() string {
 	var summary strings.Builder
 	summary.WriteString("\nFile sync completed successfully!\n\n")
-	summary.WriteString(fmt.Sprintf("Source: %s\n", m.sourceFile.Path))
-	summary.WriteString(fmt.Sprintf("\nCopied to %d target(s):\n", len(m.selected)))
+	summary.WriteString(fmt.Sprintf("Source: %s\n", m.displayPath(*m.sourceFile)))
+	targets := m.syncTargets()
+	if m.run != nil && m.run.gitOnly {
+		summary.WriteString(fmt.Sprintf("\nCommitted to %d target(s), working trees untouched:\n", len(targets)))
+	} else {
+		summary.WriteString(fmt.Sprintf("\nCopied to %d target(s):\n", len(targets)))
+	}
 
-	for idx := range m.selected {
-		if idx < len(m.filteredFiles) {
-			file := m.filteredFiles[idx]
-			summary.WriteString(fmt.Sprintf("  - %s\n", file.Path))
+	for _, file := range targets {
+		if stats, ok := m.copyStats[file.fullPath(m.workDir)]; ok {
+			summary.WriteString(fmt.Sprintf("  - %s (-%d +%d lines)\n", m.displayPath(file), stats[0], stats[1]))
+		} else {
+			summary.WriteString(fmt.Sprintf("  - %s\n", m.displayPath(file)))
 		}
 	}
+	if m.run != nil && m.run.journal != nil && m.run.journal.ID != "" {
+		summary.WriteString(fmt.Sprintf("\nUndo with: fmr undo %s\n", m.run.journal.ID))
+	}
 
 	return summary.String()
 }
@@ -1483,10 +2254,20 @@ func (m *model) generateGitSummary(repos []string, branchName string) string {
 	} else {
 		summary.WriteString("Push: NO (you can push manually later)\n")
 	}
+	if len(m.prURLs) > 0 {
+		summary.WriteString("\nPull requests:\n")
+		for _, repo := range repos {
+			if url, ok := m.prURLs[repo]; ok {
+				summary.WriteString(fmt.Sprintf("  - %s\n", url))
+			}
+		}
+	}
 	summary.WriteString("\nNext steps:\n")
 	summary.WriteString("  - Review commits: git log -1 (in each repository)\n")
 	if !m.shouldPush {
 		summary.WriteString(fmt.Sprintf("  - Push to remote: git push -u origin %s\n", branchName))
+	}
+	if len(m.prURLs) == 0 {
 		summary.WriteString("  - Create pull requests on GitHub/GitLab\n")
 	}
 	return summary.String()
@@ -1526,42 +2307,115 @@ func maxInt(a, b int) int {
 	return b
 }
 
-// generateDiff generates a simple unified diff between two strings
+// generateDiff generates a unified diff between two strings with m.diffContext context lines
 func (m model) generateDiff(source, target string) []string {
-	sourceLines := strings.Split(source, "\n")
-	targetLines := strings.Split(target, "\n")
+	result := []string{fmt.Sprintf("@@ Source: %s → Target @@", m.displayPath(*m.sourceFile))}
+	diff := unifiedDiff(source, target, m.diffContext)
+	if len(diff) == 0 {
+		return append(result, " (no differences)")
+	}
+	return append(result, diff...)
+}
 
-	var result []string
-	result = append(result, fmt.Sprintf("@@ Source: %s → Target @@", m.sourceFile.Path))
+// showsDiff reports whether the preview compares the current file with the source
+func (m model) showsDiff() bool {
+	return m.previewMode == previewDiff || m.previewMode == previewSideBySide
+}
 
-	// Simple line-by-line comparison
-	maxLen := maxInt(len(sourceLines), len(targetLines))
+// sideBySideActive reports whether the side-by-side preview fits the terminal
+func (m model) sideBySideActive() bool {
+	return m.previewMode == previewSideBySide && m.width >= minSideBySideWidth
+}
 
-	for i := 0; i < maxLen; i++ {
-		var sourceLine, targetLine string
+// generateSideBySide renders source and target in two aligned columns fitting width.
+// Changed lines are colored; the lines are ready to display.
+func (m model) generateSideBySide(source, target string, width int) []string {
+	rows := sideBySideDiff(source, target, m.diffContext)
+	if len(rows) == 0 {
+		return []string{" (no differences)"}
+	}
+
+	const gutter = " │ "
+	colWidth := maxInt((width-len([]rune(gutter)))/2, 8)
+	oldStyle := lipgloss.NewStyle().Foreground(diffDeleteColor)
+	newStyle := lipgloss.NewStyle().Foreground(diffAddColor)
+	sepStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("12")) // Blue for skipped lines
 
-		if i < len(sourceLines) {
-			sourceLine = sourceLines[i]
+	column := func(num int, text string) string {
+		if num == 0 {
+			return strings.Repeat(" ", colWidth)
 		}
-		if i < len(targetLines) {
-			targetLine = targetLines[i]
+		return fitColumn(fmt.Sprintf("%4d %s", num, text), colWidth)
+	}
+
+	lines := make([]string, 0, len(rows))
+	for _, row := range rows {
+		if row.Separator {
+			lines = append(lines, sepStyle.Render(fitColumn("  ···", colWidth)+gutter+fitColumn("  ···", colWidth)))
+			continue
 		}
 
-		// If lines are different, show both
-		if sourceLine != targetLine {
-			if i < len(sourceLines) {
-				result = append(result, fmt.Sprintf("-%s", sourceLine))
-			}
-			if i < len(targetLines) {
-				result = append(result, fmt.Sprintf("+%s", targetLine))
+		left, right := column(row.OldNum, row.Old), column(row.NewNum, row.New)
+		if row.Changed {
+			left, right = oldStyle.Render(left), newStyle.Render(right)
+			if row.OldNum > 0 && row.NewNum > 0 {
+				if oldSegs, newSegs := intraLineDiff(row.Old, row.New, m.intraLine); oldSegs != nil {
+					left = renderSegments(numberedSegments(row.OldNum, oldSegs), colWidth, diffDeleteColor, true)
+					right = renderSegments(numberedSegments(row.NewNum, newSegs), colWidth, diffAddColor, true)
+				}
 			}
-		} else {
-			// Lines are the same, show context
-			result = append(result, fmt.Sprintf(" %s", sourceLine))
 		}
+		lines = append(lines, left+gutter+right)
+	}
+	return lines
+}
+
+// numberedSegments prefixes line segments with a side-by-side line number
+func numberedSegments(num int, segs []lineSegment) []lineSegment {
+	return append([]lineSegment{{Text: fmt.Sprintf("%4d ", num)}}, segs...)
+}
+
+// renderSegments colors a changed line and emphasizes its changed segments.
+// The text is truncated to width runes and, if pad is set, padded to exactly width.
+func renderSegments(segs []lineSegment, width int, color lipgloss.Color, pad bool) string {
+	base := lipgloss.NewStyle().Foreground(color)
+	emphasis := base.Bold(true).Reverse(true)
+
+	var b strings.Builder
+	remaining := width
+	for _, seg := range segs {
+		if remaining <= 0 {
+			break
+		}
+		runes := []rune(strings.ReplaceAll(seg.Text, "\t", "    "))
+		truncated := len(runes) > remaining
+		if truncated {
+			runes = append(runes[:maxInt(remaining-1, 0)], '…')
+		}
+		remaining -= len(runes)
+
+		style := base
+		if seg.Changed {
+			style = emphasis
+		}
+		b.WriteString(style.Render(string(runes)))
+		if truncated {
+			break
+		}
+	}
+	if pad && remaining > 0 {
+		b.WriteString(strings.Repeat(" ", remaining))
 	}
+	return b.String()
+}
 
-	return result
+// fitColumn expands tabs, then truncates or pads s to exactly width runes
+func fitColumn(s string, width int) string {
+	runes := []rune(strings.ReplaceAll(s, "\t", "    "))
+	if len(runes) > width {
+		return string(runes[:width-1]) + "…"
+	}
+	return string(runes) + strings.Repeat(" ", width-len(runes))
 }
 
 // renderHelpOverlay renders the help modal overlay
@@ -1574,6 +2428,7 @@ NAVIGATION
 
 PATH INPUT
   Type            Edit working directory path
+                  Separate several roots with commas: ../api, ../web
   CTRL-R          Reload files from current path
 
 SEARCH INPUT
@@ -1585,11 +2440,16 @@ FILE LIST
   k / j           Navigate up/down (vim-style)
   s               Mark current file as SOURCE
   SPACE           Toggle current file as TARGET
+  g               Preselect next manifest group (.fmr.yaml)
+  o               Scan settings: depth, excludes, hidden directories
   ENTER           Proceed to confirmation (requires source + targets)
 
 PREVIEW PANEL
-  p / CTRL-P      Cycle preview modes: hidden → plain → diff → hidden
+  p / CTRL-P      Cycle preview modes: hidden → plain → diff → side-by-side → hidden
+                  Side-by-side needs a terminal at least 140 columns wide
   CTRL-U / CTRL-D Scroll preview up/down
+  + / -           More/fewer context lines around changes (diff mode)
+  w               Cycle highlighting inside changed lines: word → char → off
   Fn+↑ / Fn+↓     Scroll preview up/down (MacBook)
   PgUp / PgDn     Scroll preview up/down (if available)
 
@@ -1600,6 +2460,12 @@ GIT WORKFLOW (Confirmation Screen)
   Type            Edit branch name / commit message when focused
   ENTER           Execute copy & commit (on Copy button)
   ESC             Cancel and return to file list
+  s / t / a       Skip, stash or abort for the focused target with
+                  uncommitted changes (↑/↓ to move, SPACE to cycle)
+
+PROGRESS (while copying and committing)
+  ESC / c         Cancel the steps that have not started yet
+  ENTER / q       Exit once finished (ESC returns to the file list)
 
 GENERAL
   ?               Toggle this help screen
@@ -1611,6 +2477,7 @@ WORKFLOW
   2. Use ↑/↓ or k/j to find your source file
   3. Press 's' to mark it as source
   4. Navigate to target files and press SPACE to select them
+     (targets stay selected while searching; hidden ones are counted below the list)
   5. Press ENTER to review files and configure git workflow
   6. Edit branch name/commit message or disable git with CTRL-G
   7. Press ENTER on "Copy & Commit" button to execute
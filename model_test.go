@@ -1,10 +1,13 @@
 package filemirror
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
 )
 
 func TestFormatSize(t *testing.T) {
@@ -160,9 +163,7 @@ func TestGenerateDiff(t *testing.T) {
 			source: "line1\nline2\nline3",
 			target: "line1\nline2\nline3",
 			expectContains: []string{
-				" line1",
-				" line2",
-				" line3",
+				" (no differences)",
 			},
 		},
 		{
@@ -193,7 +194,7 @@ func TestGenerateDiff(t *testing.T) {
 			expectContains: []string{
 				" line1",
 				"-line2",
-				"+line3",
+				" line3",
 			},
 		},
 		{
@@ -251,7 +252,7 @@ func TestGenerateDiff(t *testing.T) {
 	}
 }
 
-func TestCopySourceToTargets(t *testing.T) {
+func TestSyncCopiesToTargets(t *testing.T) {
 	// Create temporary directory for testing
 	tmpDir, err := os.MkdirTemp("", "fmr-copy-test-*")
 	if err != nil {
@@ -279,7 +280,7 @@ func TestCopySourceToTargets(t *testing.T) {
 	tests := []struct {
 		name        string
 		sourceFile  *FileInfo
-		selected    map[int]bool
+		selected    []FileInfo
 		files       []FileInfo
 		expectError bool
 	}{
@@ -288,7 +289,7 @@ func TestCopySourceToTargets(t *testing.T) {
 			sourceFile: &FileInfo{
 				Path: sourceFile,
 			},
-			selected: map[int]bool{0: true},
+			selected: []FileInfo{{Path: target1}},
 			files: []FileInfo{
 				{Path: target1},
 			},
@@ -299,7 +300,7 @@ func TestCopySourceToTargets(t *testing.T) {
 			sourceFile: &FileInfo{
 				Path: sourceFile,
 			},
-			selected: map[int]bool{0: true, 1: true},
+			selected: []FileInfo{{Path: target1}, {Path: target2}},
 			files: []FileInfo{
 				{Path: target1},
 				{Path: target2},
@@ -311,27 +312,27 @@ func TestCopySourceToTargets(t *testing.T) {
 			sourceFile: &FileInfo{
 				Path: sourceFile,
 			},
-			selected:    map[int]bool{},
+			selected:    nil,
 			files:       []FileInfo{{Path: target1}},
 			expectError: false, // Should succeed with no operation
 		},
 		{
-			name:        "no source file",
-			sourceFile:  nil,
-			selected:    map[int]bool{0: true},
+			name:        "missing source file",
+			sourceFile:  &FileInfo{Path: filepath.Join(tmpDir, "missing.txt")},
+			selected:    []FileInfo{{Path: target1}},
 			files:       []FileInfo{{Path: target1}},
 			expectError: true,
 		},
 		{
-			name: "selected index out of bounds",
+			name: "target hidden by filter",
 			sourceFile: &FileInfo{
 				Path: sourceFile,
 			},
-			selected: map[int]bool{0: true, 5: true}, // 5 is out of bounds
+			selected: []FileInfo{{Path: target1}, {Path: target2}},
 			files: []FileInfo{
 				{Path: target1},
 			},
-			expectError: false, // Should succeed by skipping invalid index
+			expectError: false, // Selection does not depend on the visible list
 		},
 	}
 
@@ -339,10 +340,13 @@ func TestCopySourceToTargets(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			m := InitialModel("", tmpDir)
 			m.sourceFile = tt.sourceFile
-			m.selected = tt.selected
+			for _, file := range tt.selected {
+				m.toggleSelected(file)
+			}
 			m.filteredFiles = tt.files
 
-			err := m.copySourceToTargets()
+			m, _ = driveSync(t, m)
+			err := m.err
 
 			if tt.expectError && err == nil {
 				t.Error("Expected error, got nil")
@@ -352,17 +356,15 @@ func TestCopySourceToTargets(t *testing.T) {
 			}
 
 			// Verify files were copied correctly
-			if !tt.expectError && tt.sourceFile != nil {
-				for idx, selected := range tt.selected {
-					if selected && idx < len(tt.files) {
-						content, err := os.ReadFile(tt.files[idx].Path)
-						if err != nil {
-							t.Errorf("Failed to read target file: %v", err)
-							continue
-						}
-						if string(content) != sourceContent {
-							t.Errorf("Target file content = %q, want %q", string(content), sourceContent)
-						}
+			if !tt.expectError {
+				for _, file := range tt.selected {
+					content, err := os.ReadFile(file.Path)
+					if err != nil {
+						t.Errorf("Failed to read target file: %v", err)
+						continue
+					}
+					if string(content) != sourceContent {
+						t.Errorf("Target file content = %q, want %q", string(content), sourceContent)
 					}
 				}
 			}
@@ -481,7 +483,7 @@ func TestInitGitWorkflow(t *testing.T) {
 		name             string
 		sourceFile       *FileInfo
 		filteredFiles    []FileInfo
-		selected         map[int]bool
+		selected         []int
 		expectGitEnabled bool
 	}{
 		{
@@ -492,9 +494,7 @@ func TestInitGitWorkflow(t *testing.T) {
 			filteredFiles: []FileInfo{
 				{Path: targetFile},
 			},
-			selected: map[int]bool{
-				0: true,
-			},
+			selected:         []int{0},
 			expectGitEnabled: false, // No git repo, so disabled
 		},
 		{
@@ -506,10 +506,7 @@ func TestInitGitWorkflow(t *testing.T) {
 				{Path: "target1.json"},
 				{Path: "target2.json"},
 			},
-			selected: map[int]bool{
-				0: true,
-				1: true,
-			},
+			selected:         []int{0, 1},
 			expectGitEnabled: false,
 		},
 	}
@@ -519,7 +516,9 @@ func TestInitGitWorkflow(t *testing.T) {
 			m := InitialModel("", tmpDir)
 			m.sourceFile = tt.sourceFile
 			m.filteredFiles = tt.filteredFiles
-			m.selected = tt.selected
+			for _, idx := range tt.selected {
+				m.toggleSelected(tt.filteredFiles[idx])
+			}
 
 			m.initGitWorkflow()
 
@@ -671,7 +670,7 @@ func TestGenerateExitSummary(t *testing.T) {
 		name          string
 		sourceFile    *FileInfo
 		filteredFiles []FileInfo
-		selected      map[int]bool
+		selected      []int
 		expectStrings []string
 	}{
 		{
@@ -682,7 +681,7 @@ func TestGenerateExitSummary(t *testing.T) {
 			filteredFiles: []FileInfo{
 				{Path: "target1.txt"},
 			},
-			selected: map[int]bool{0: true},
+			selected: []int{0},
 			expectStrings: []string{
 				"File sync completed successfully",
 				"Source: source.txt",
@@ -700,7 +699,7 @@ func TestGenerateExitSummary(t *testing.T) {
 				{Path: "app2/config.yaml"},
 				{Path: "app3/config.yaml"},
 			},
-			selected: map[int]bool{0: true, 1: true, 2: true},
+			selected: []int{0, 1, 2},
 			expectStrings: []string{
 				"File sync completed successfully",
 				"Source: config.yaml",
@@ -717,7 +716,9 @@ func TestGenerateExitSummary(t *testing.T) {
 			m := InitialModel("", ".")
 			m.sourceFile = tt.sourceFile
 			m.filteredFiles = tt.filteredFiles
-			m.selected = tt.selected
+			for _, idx := range tt.selected {
+				m.toggleSelected(tt.filteredFiles[idx])
+			}
 
 			summary := m.generateExitSummary()
 
@@ -869,7 +870,7 @@ func TestView(t *testing.T) {
 				m.mode = modeConfirm
 				m.sourceFile = &FileInfo{Path: "test.txt"}
 				m.filteredFiles = []FileInfo{{Path: "target.txt"}}
-				m.selected = map[int]bool{0: true}
+				m.toggleSelected(m.filteredFiles[0])
 				m.initGitWorkflow()
 				return m
 			},
@@ -1008,7 +1009,7 @@ func TestRenderPreview(t *testing.T) {
 
 	m := InitialModel("", tmpDir)
 	m.width = 100
-	m.height = 20
+	m.height = 24
 	m.files = []FileInfo{
 		{Path: "source.txt"},
 		{Path: "target.txt"},
@@ -1018,6 +1019,7 @@ func TestRenderPreview(t *testing.T) {
 	// Test plain preview
 	m.previewMode = previewPlain
 	m.cursor = 1 // Select target.txt
+	m.refreshPreview()
 	plainView := m.renderPreview()
 	if !strings.Contains(plainView, "line4") {
 		t.Error("Expected plain preview to contain target file content")
@@ -1026,6 +1028,7 @@ func TestRenderPreview(t *testing.T) {
 	// Test diff preview
 	m.previewMode = previewDiff
 	m.sourceFile = &m.filteredFiles[0] // source.txt
+	m.refreshPreview()
 	diffView := m.renderPreview()
 	if !strings.Contains(diffView, "-line2") {
 		t.Error("Expected diff preview to contain removed line")
@@ -1034,14 +1037,76 @@ func TestRenderPreview(t *testing.T) {
 		t.Error("Expected diff preview to contain added line")
 	}
 
+	// Side-by-side falls back to unified diff on narrow terminals
+	m.previewMode = previewSideBySide
+	m.refreshPreview()
+	narrowView := m.renderPreview()
+	if !strings.Contains(narrowView, "too narrow") || !strings.Contains(narrowView, "+line4") {
+		t.Errorf("Expected unified fallback on narrow terminal, got:\n%s", narrowView)
+	}
+
+	// Side-by-side shows both versions on the same row
+	m.width = 160
+	m.refreshPreview()
+	wideView := m.renderPreview()
+	if !strings.Contains(wideView, "side-by-side") {
+		t.Error("Expected side-by-side header on wide terminal")
+	}
+	foundPair := false
+	for _, line := range strings.Split(wideView, "\n") {
+		if strings.Contains(line, "line2") && strings.Contains(line, "line4") {
+			foundPair = true
+		}
+	}
+	if !foundPair {
+		t.Errorf("Expected line2 and line4 on the same row, got:\n%s", wideView)
+	}
+	m.width = 100
+
 	// Test error handling
 	m.filteredFiles[1].Path = "non-existent-file.txt"
+	m.refreshPreview()
 	errView := m.renderPreview()
 	if !strings.Contains(errView, "Error reading file") {
 		t.Error("Expected error view to contain error message")
 	}
 }
 
+func TestPreviewLoadedOnCursorMove(t *testing.T) {
+	tmpDir := t.TempDir()
+	for name, content := range map[string]string{"a.txt": "first\n", "b.txt": "second\n"} {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	m := InitialModel("", tmpDir)
+	m.width, m.height = 100, 24
+	m.previewMode = previewPlain
+	m.files = []FileInfo{{Path: "a.txt"}, {Path: "b.txt"}}
+	m.filterFiles()
+	updated, _ := m.Update(tea.WindowSizeMsg{Width: 100, Height: 24})
+	m = updated.(model)
+	if !strings.Contains(m.View(), "first") {
+		t.Fatalf("Expected the preview of a.txt, got:\n%s", m.View())
+	}
+
+	// Redrawing shows the loaded preview without reading the file again
+	if err := os.WriteFile(filepath.Join(tmpDir, "a.txt"), []byte("changed\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write a.txt: %v", err)
+	}
+	if view := m.View(); !strings.Contains(view, "first") || strings.Contains(view, "changed") {
+		t.Errorf("Expected the redraw to keep the loaded preview, got:\n%s", view)
+	}
+
+	// Moving the cursor loads the next file
+	updated, _ = m.Update(keyMsg("down"))
+	m = *updated.(*model)
+	if !strings.Contains(m.View(), "second") {
+		t.Errorf("Expected the preview of b.txt, got:\n%s", m.View())
+	}
+}
+
 func TestShouldScanOnBlur(t *testing.T) {
 	tests := []struct {
 		name          string
@@ -1161,3 +1226,332 @@ func TestTriggerScanIfNeeded(t *testing.T) {
 		}
 	})
 }
+
+// keyMsg builds a key press message for a key as reported by tea.KeyMsg.String()
+func keyMsg(key string) tea.KeyMsg {
+	special := map[string]tea.KeyType{
+		"enter":     tea.KeyEnter,
+		"tab":       tea.KeyTab,
+		"shift+tab": tea.KeyShiftTab,
+		"esc":       tea.KeyEsc,
+		" ":         tea.KeySpace,
+		"up":        tea.KeyUp,
+		"down":      tea.KeyDown,
+		"left":      tea.KeyLeft,
+		"right":     tea.KeyRight,
+		"ctrl+c":    tea.KeyCtrlC,
+		"ctrl+r":    tea.KeyCtrlR,
+		"ctrl+p":    tea.KeyCtrlP,
+	}
+	if keyType, ok := special[key]; ok {
+		return tea.KeyMsg{Type: keyType}
+	}
+	return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(key)}
+}
+
+func TestScanSettingsPanel(t *testing.T) {
+	m := InitialModel("", t.TempDir())
+	m.focus = focusList
+
+	updated, _ := m.updateSelect(keyMsg("o"))
+	m = *updated.(*model)
+	if m.scanSettings == nil {
+		t.Fatal("Expected scan settings panel to open")
+	}
+	if got := m.scanSettings.depthInput.Value(); got != "4" {
+		t.Errorf("depth input = %q, want current depth 4", got)
+	}
+	if !strings.Contains(m.View(), "SCAN SETTINGS") {
+		t.Error("Expected view to render the scan settings panel")
+	}
+
+	// Invalid depth keeps the panel open with an error
+	m.scanSettings.depthInput.SetValue("deep")
+	updated, _ = m.updateSelect(keyMsg("enter"))
+	m = *updated.(*model)
+	if m.scanSettings == nil || m.scanSettings.err == nil {
+		t.Fatal("Expected validation error for invalid depth")
+	}
+
+	m.scanSettings.depthInput.SetValue("unlimited")
+	m.scanSettings.excludeInput.SetValue("fixtures, services/legacy")
+	m.scanSettings.setFocus(scanFieldHidden)
+	updated, _ = m.updateSelect(keyMsg(" "))
+	m = *updated.(*model)
+
+	updated, cmd := m.updateSelect(keyMsg("enter"))
+	m = *updated.(*model)
+	if m.scanSettings != nil {
+		t.Error("Expected panel to close after applying")
+	}
+	if cmd == nil {
+		t.Error("Expected a rescan command")
+	}
+	if m.scanOpts.MaxDepth != -1 || !m.scanOpts.IncludeHidden || strings.Join(m.scanOpts.Exclude, ",") != "fixtures,services/legacy" {
+		t.Errorf("scanOpts = %+v", m.scanOpts)
+	}
+
+	// ESC discards changes
+	updated, _ = m.updateSelect(keyMsg("o"))
+	m = *updated.(*model)
+	m.scanSettings.depthInput.SetValue("2")
+	updated, _ = m.updateSelect(keyMsg("esc"))
+	m = *updated.(*model)
+	if m.scanSettings != nil || m.scanOpts.MaxDepth != -1 {
+		t.Errorf("Expected ESC to close the panel without changes, got depth %d", m.scanOpts.MaxDepth)
+	}
+}
+
+func TestMultipleRoots(t *testing.T) {
+	// changeRoots changes the working directory
+	chdirMutex.Lock()
+	defer chdirMutex.Unlock()
+
+	// Try to recover to a valid directory if current one is invalid
+	if _, err := os.Getwd(); err != nil {
+		if homeDir, err := os.UserHomeDir(); err == nil {
+			_ = os.Chdir(homeDir)
+		}
+	}
+	origDir, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("Failed to get current directory: %v", err)
+	}
+	defer os.Chdir(origDir)
+
+	parent := t.TempDir()
+	api := filepath.Join(parent, "api")
+	web := filepath.Join(parent, "web")
+	for dir, content := range map[string]string{api: "new", web: "old"} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(content), 0o644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+
+	m := InitialModel("", api)
+	if err := m.changeRoots(api + ", " + web); err != nil {
+		t.Fatalf("changeRoots failed: %v", err)
+	}
+	if m.workDir != api || len(m.roots) != 2 {
+		t.Fatalf("workDir = %q, roots = %v", m.workDir, m.roots)
+	}
+	if err := m.changeRoots(api + ", " + filepath.Join(parent, "missing")); err == nil {
+		t.Error("Expected error for a missing root")
+	}
+
+	files, err := scanRoots(m.roots, "", m.scanOpts)
+	if err != nil {
+		t.Fatalf("scanRoots failed: %v", err)
+	}
+	updated, _ := m.Update(scanCompleteMsg{files: files})
+	m = updated.(model)
+
+	var apiIdx, webIdx int
+	for i, f := range m.filteredFiles {
+		switch m.displayPath(f) {
+		case "api:config.yaml":
+			apiIdx = i
+		case "web:config.yaml":
+			webIdx = i
+		default:
+			t.Errorf("Unexpected display path %q", m.displayPath(f))
+		}
+	}
+
+	// Files with the same relative path in different roots are distinct
+	m.sourceFile = &m.filteredFiles[apiIdx]
+	if m.isSource(m.filteredFiles[webIdx]) {
+		t.Error("web:config.yaml must not be treated as the source")
+	}
+
+	// Filtering matches the root label
+	m.searchInput.SetValue("web:")
+	m.filterFiles()
+	if len(m.filteredFiles) != 1 {
+		t.Errorf("Expected filter to match one root, got %d files", len(m.filteredFiles))
+	}
+	m.searchInput.SetValue("")
+	m.filterFiles()
+
+	m.toggleSelected(m.filteredFiles[webIdx])
+	if m, _ = driveSync(t, m); m.err != nil {
+		t.Fatalf("sync failed: %v", m.err)
+	}
+	content, _ := os.ReadFile(filepath.Join(web, "config.yaml"))
+	if string(content) != "new" {
+		t.Errorf("web config = %q, want copied content", content)
+	}
+}
+
+func TestStreamedScan(t *testing.T) {
+	root := t.TempDir()
+	for i := 0; i < 20; i++ {
+		dir := filepath.Join(root, fmt.Sprintf("dir%02d", i), "sub")
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+		for _, name := range []string{"a.txt", "b.txt"} {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0o644); err != nil {
+				t.Fatalf("Failed to create file: %v", err)
+			}
+		}
+	}
+
+	want, err := scanRoots([]string{root}, "", defaultScanOptions())
+	if err != nil {
+		t.Fatalf("scanRoots failed: %v", err)
+	}
+
+	m := model{
+		roots:    []string{root},
+		scanOpts: defaultScanOptions(),
+		files:    []FileInfo{{Path: "stale.txt"}},
+		selected: make(map[string]FileInfo),
+	}
+	cmd := m.startScan("")
+
+	var updated tea.Model = m
+	messages := 0
+	for cmd != nil {
+		msg, ok := cmd().(scanCompleteMsg)
+		if !ok {
+			t.Fatalf("Expected scanCompleteMsg, got %T", msg)
+		}
+		if msg.first == (messages > 0) {
+			t.Errorf("Message %d: first = %v", messages, msg.first)
+		}
+		messages++
+		updated, cmd = updated.Update(msg)
+	}
+
+	result := updated.(model)
+	if result.scan != nil {
+		t.Error("Expected scan to be cleared once done")
+	}
+	if len(result.files) != len(want) {
+		t.Fatalf("Expected %d files, got %d", len(want), len(result.files))
+	}
+	for i := range want {
+		if result.files[i].fullPath("") != want[i].fullPath("") {
+			t.Errorf("files[%d] = %q, want %q", i, result.files[i].Path, want[i].Path)
+		}
+	}
+
+	// Results of a replaced scan are ignored
+	old := result.startScan("")
+	result.startScan("a.txt")
+	stale, _ := result.Update(old())
+	if len(stale.(model).files) != len(want) {
+		t.Error("Expected stale scan results to be ignored")
+	}
+	result.scan.cancel()
+}
+
+func TestSelectionByPath(t *testing.T) {
+	root := t.TempDir()
+	files := []FileInfo{
+		{Path: "a/config.yaml", Root: root, Size: 1},
+		{Path: "b/config.yaml", Root: root, Size: 2},
+		{Path: "c/settings.yaml", Root: root, Size: 3},
+	}
+
+	m := model{
+		roots:    []string{root},
+		workDir:  root,
+		files:    files,
+		selected: make(map[string]FileInfo),
+		focus:    focusList,
+		height:   40,
+		width:    120,
+	}
+	m.filterFiles()
+
+	// Mark b/config.yaml as target and a/config.yaml as source
+	m.cursor = 1
+	m.updateSelect(tea.KeyMsg{Type: tea.KeySpace, Runes: []rune{' '}})
+	m.cursor = 0
+	m.updateSelect(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'s'}})
+
+	// A rescan in a different order must not move the marks
+	updated, _ := m.Update(scanCompleteMsg{files: []FileInfo{
+		{Path: "c/settings.yaml", Root: root, Size: 3},
+		{Path: "b/config.yaml", Root: root, Size: 20},
+		{Path: "a/config.yaml", Root: root, Size: 1},
+	}})
+	m = updated.(model)
+	if !m.isSelected(FileInfo{Path: "b/config.yaml", Root: root}) || len(m.selected) != 1 {
+		t.Fatalf("Selection moved after rescan: %v", m.selected)
+	}
+	if got := m.selectedFiles()[0].Size; got != 20 {
+		t.Errorf("Selected file info not refreshed, size = %d", got)
+	}
+	if m.sourceFile == nil || m.sourceFile.Path != "a/config.yaml" {
+		t.Errorf("Source moved after rescan: %+v", m.sourceFile)
+	}
+
+	// Filtering hides the target but keeps it selected
+	m.searchInput.SetValue("settings")
+	m.filterFiles()
+	if got := m.hiddenSelectedCount(); got != 1 {
+		t.Errorf("hiddenSelectedCount() = %d, want 1", got)
+	}
+	if !strings.Contains(m.viewSelect(), "1 hidden by filter") {
+		t.Error("Expected file list footer to mention the hidden target")
+	}
+
+	// Toggling the only visible file does not affect the hidden target
+	m.cursor = 0
+	m.updateSelect(tea.KeyMsg{Type: tea.KeySpace, Runes: []rune{' '}})
+	m.updateSelect(tea.KeyMsg{Type: tea.KeySpace, Runes: []rune{' '}})
+	if len(m.selected) != 1 || !m.isSelected(FileInfo{Path: "b/config.yaml", Root: root}) {
+		t.Errorf("Unexpected selection after toggling: %v", m.selected)
+	}
+
+	// After a path change, targets outside the new roots are shown with their full path
+	m.roots = []string{t.TempDir()}
+	if got := m.displayPath(m.selectedFiles()[0]); got != filepath.Join(root, "b/config.yaml") {
+		t.Errorf("displayPath() = %q, want full path", got)
+	}
+}
+
+func TestConfirmBaseRef(t *testing.T) {
+	repo := createTestGitRepo(t)
+	defer os.RemoveAll(repo)
+
+	m := InitialModel("", repo)
+	m.sourceFile = &FileInfo{Path: filepath.Join(repo, "source.txt")}
+	m.toggleSelected(FileInfo{Path: filepath.Join(repo, "target.txt")})
+	m.mode = modeConfirm
+	m.initGitWorkflow()
+	m.width, m.height = 160, 60
+
+	if !strings.Contains(m.viewConfirm(), "(from main)") {
+		t.Error("Expected the confirm screen to show the default branch as base")
+	}
+
+	// gitEnabled -> git only -> ticket -> branch -> base
+	m.confirmFocus = focusGitEnabled
+	for _, key := range []string{"tab", "tab", "tab", "tab", "n", "o", "p", "e", "tab"} {
+		updated, _ := m.updateConfirm(keyMsg(key))
+		m = *updated.(*model)
+	}
+	if m.confirmFocus != focusFetchToggle {
+		t.Fatalf("focus = %v, want the fetch toggle", m.confirmFocus)
+	}
+	if !strings.Contains(m.viewConfirm(), `base ref "nope" not found`) {
+		t.Error("Expected the confirm screen to flag the missing base ref")
+	}
+	if job := m.newSyncJob(); job.git.Base != "nope" {
+		t.Errorf("job base = %q, want nope", job.git.Base)
+	}
+
+	// With fetching, the ref may still appear
+	updated, _ := m.updateConfirm(keyMsg(" "))
+	m = *updated.(*model)
+	if !m.fetchBase || !strings.Contains(m.viewConfirm(), "nope (after fetch)") {
+		t.Errorf("fetchBase = %v, want the base shown as fetched", m.fetchBase)
+	}
+}
@@ -0,0 +1,541 @@
+package filemirror
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/spinner"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// syncStepStatus is the state of one step of a running sync
+type syncStepStatus int
+
+const (
+	stepPending syncStepStatus = iota
+	stepRunning
+	stepDone
+	stepFailed
+	stepCancelled
+)
+
+// syncStep is one target copy or one repository's git workflow
+type syncStep struct {
+	name      string // target as displayed in the file list, or repository path
+	repo      string // repository path for git steps, "" for copy steps
+	target    string // absolute target path for copy steps
+	status    syncStepStatus
+	stage     string // current stage of a git step, e.g. "pushing"
+	err       error
+	committed bool   // a git step committed, even if pushing failed
+	prURL     string // pull request opened by a git step
+	started   time.Time
+	finished  time.Time
+}
+
+// label describes what the step does
+func (s syncStep) label(push bool) string {
+	switch {
+	case s.repo == "":
+		return "Copy to " + s.name
+	case push:
+		return "Commit & push in " + s.name
+	default:
+		return "Commit in " + s.name
+	}
+}
+
+// syncJob is everything the background sync needs, captured when it starts
+type syncJob struct {
+	source        string
+	targets       []string
+	repos         []string
+	repoFiles     map[string][]string
+	git           gitWorkflowOptions
+	gitOnly       bool              // write the source only into the worktrees, never into targets
+	transactional bool              // copy to all targets or none of them
+	stash         map[string]string // target -> repository, for targets whose local changes are stashed first
+	template      *sourceTemplate   // renders the source per target, for --section, --key and templated, section or keyed manifest groups
+}
+
+// syncRun tracks a copy and git workflow running in the background.
+// Steps are only modified by the model; the worker reports through events.
+type syncRun struct {
+	steps         []syncStep
+	push          bool
+	branch        string // rendered branch name the repositories are committed on
+	events        chan syncProgressMsg
+	ctx           context.Context
+	cancel        context.CancelFunc
+	started       time.Time
+	cancelled     bool
+	quitting      bool // ctrl+c was pressed; the program quits once the worker has cleaned up
+	done          bool
+	transactional bool     // targets are copied all-or-nothing
+	gitOnly       bool     // targets are only written in the worktrees
+	journal       *journal // originals of the overwritten targets; written by the worker until done
+}
+
+// syncProgressMsg reports a step starting, changing stage or finishing.
+// The last message of a run has done set and no step.
+type syncProgressMsg struct {
+	run     *syncRun
+	step    int
+	status  syncStepStatus
+	stage   string
+	err     error
+	stats   *[2]int // lines removed/added by a copy
+	success bool    // a git step committed, even if pushing failed
+	prURL   string  // pull request opened by a git step
+	done    bool
+}
+
+// newSyncJob captures the copy and git settings of the confirm screen
+func (m model) newSyncJob() syncJob {
+	job := syncJob{
+		source:        m.sourceFile.fullPath(m.workDir),
+		transactional: m.transactional,
+		stash:         m.stashTargets(),
+		template:      m.sourceTemplate(),
+		gitOnly:       m.gitOnly && m.gitEnabled,
+	}
+	if !job.gitOnly {
+		for _, file := range m.syncTargets() {
+			job.targets = append(job.targets, file.fullPath(m.workDir))
+		}
+	}
+
+	if repos := m.syncRepos(); m.gitEnabled && len(repos) > 0 {
+		job.repoFiles = repos
+		job.repos = sortedRepos(repos)
+		branch, message, _ := m.renderGitFields() // validated before the sync starts
+		job.git = gitWorkflowOptions{
+			Branch:  branch,
+			Message: message,
+			Push:    m.shouldPush,
+			Workers: m.gitWorkers,
+			PR:      m.prOpts,
+			Base:    strings.TrimSpace(m.baseRefInput.Value()),
+			Fetch:   m.fetchBase,
+		}
+	}
+
+	return job
+}
+
+// startSync switches to the progress view and starts the sync in the background
+func (m *model) startSync() tea.Cmd {
+	job := m.newSyncJob()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	run := &syncRun{
+		events:        make(chan syncProgressMsg, 16),
+		ctx:           ctx,
+		cancel:        cancel,
+		started:       time.Now(),
+		push:          job.git.Push,
+		branch:        job.git.Branch,
+		gitOnly:       job.gitOnly,
+		journal:       newJournal(m.workDir),
+		transactional: job.transactional,
+	}
+	if !job.gitOnly {
+		for _, file := range m.syncTargets() {
+			run.steps = append(run.steps, syncStep{name: m.displayPath(file), target: file.fullPath(m.workDir)})
+		}
+	}
+	for _, repo := range job.repos {
+		run.steps = append(run.steps, syncStep{name: repo, repo: repo})
+	}
+
+	m.run = run
+	m.mode = modeProgress
+	m.err = nil
+	m.copyStats = make(map[string][2]int)
+	m.prURLs = make(map[string]string)
+	m.spinner = spinner.New(spinner.WithSpinner(spinner.Dot))
+
+	go run.execute(job)
+
+	return tea.Batch(m.spinner.Tick, run.next())
+}
+
+// execute copies the source to every target, then runs the git workflow for several
+// repositories at a time. A failed copy stops the run; git failures only affect their repository.
+func (r *syncRun) execute(job syncJob) {
+	defer close(r.events)
+
+	sourceContent, err := os.ReadFile(job.source)
+	if err != nil {
+		if len(job.targets)+len(job.repos) > 0 {
+			r.events <- syncProgressMsg{run: r, step: 0, status: stepFailed, err: fmt.Errorf("failed to read source file: %w", err)}
+		}
+		return
+	}
+
+	// The source's provenance goes into the lockfile committed in every target repository
+	lock, err := newLockSource(job.source)
+	if err != nil {
+		if len(job.targets)+len(job.repos) > 0 {
+			r.events <- syncProgressMsg{run: r, step: 0, status: stepFailed, err: err}
+		}
+		return
+	}
+	job.git.Lock = lock
+
+	switch {
+	case job.gitOnly:
+		// The worktrees get the source content; the targets themselves stay untouched
+		job.git.Content = sourceContent
+		job.git.Template = job.template
+	case !r.stashLocalChanges(job):
+		return
+	case job.transactional:
+		if !r.copyTransaction(job, sourceContent) {
+			return
+		}
+	default:
+		for i, target := range job.targets {
+			if r.ctx.Err() != nil {
+				return
+			}
+			r.events <- syncProgressMsg{run: r, step: i, status: stepRunning}
+
+			stats, err := copyToTarget(job.source, sourceContent, target, job.template, r.journal)
+			if err != nil {
+				r.events <- syncProgressMsg{run: r, step: i, status: stepFailed, err: err}
+				return
+			}
+			r.events <- syncProgressMsg{run: r, step: i, status: stepDone, stats: stats}
+		}
+	}
+
+	forEachConcurrently(len(job.repos), job.git.Workers, func(j int) {
+		if r.ctx.Err() != nil {
+			return
+		}
+		repo := job.repos[j]
+		step := len(job.targets) + j
+		r.events <- syncProgressMsg{run: r, step: step, status: stepRunning}
+
+		res := processRepoWithPR(repo, job.repoFiles[repo], job.git, func(stage string) {
+			r.events <- syncProgressMsg{run: r, step: step, status: stepRunning, stage: stage}
+		})
+		status := stepDone
+		if res.Err != nil {
+			status = stepFailed
+		}
+		r.events <- syncProgressMsg{run: r, step: step, status: status, err: res.Err, success: res.Success, prURL: res.PRURL}
+	})
+}
+
+// stashLocalChanges stashes the uncommitted changes of the targets the user chose to stash,
+// so copying over them loses nothing. Returns false if a stash failed.
+func (r *syncRun) stashLocalChanges(job syncJob) bool {
+	for i, target := range job.targets {
+		repo, ok := job.stash[target]
+		if !ok {
+			continue
+		}
+		if r.ctx.Err() != nil {
+			return false
+		}
+		r.events <- syncProgressMsg{run: r, step: i, status: stepRunning, stage: "stashing local changes"}
+		if err := stashFile(repo, target); err != nil {
+			r.events <- syncProgressMsg{run: r, step: i, status: stepFailed, err: err}
+			return false
+		}
+	}
+	return true
+}
+
+// copyTransaction copies the source to all targets or none of them and reports every
+// target's outcome. Targets left unchanged by a failed transaction are reported as cancelled.
+// Returns false if the transaction did not commit.
+func (r *syncRun) copyTransaction(job syncJob, sourceContent []byte) bool {
+	if r.ctx.Err() != nil {
+		return false
+	}
+
+	stats := make([]*[2]int, len(job.targets))
+	for i, target := range job.targets {
+		r.events <- syncProgressMsg{run: r, step: i, status: stepRunning, stage: "staging"}
+		if rendered, err := job.template.render(sourceContent, target); err == nil {
+			stats[i] = changeStats(target, rendered)
+		}
+	}
+
+	mode := os.FileMode(0o644)
+	if info, err := os.Stat(job.source); err == nil {
+		mode = info.Mode().Perm()
+	}
+	results, txErr := syncTransaction(job.source, sourceContent, mode, job.targets, job.template, r.journal)
+
+	for i, result := range results {
+		msg := syncProgressMsg{run: r, step: i}
+		switch result.Outcome {
+		case txCommitted:
+			msg.status = stepDone
+			msg.stats = stats[i]
+		case txFailed:
+			msg.status = stepFailed
+			msg.err = result.Err
+		case txRollbackFailed:
+			msg.status = stepFailed
+			msg.err = fmt.Errorf("written, rollback failed: %w", result.Err)
+		default:
+			msg.status = stepCancelled
+			msg.err = errors.New(result.Outcome.String())
+		}
+		r.events <- msg
+	}
+	return txErr == nil
+}
+
+// next returns a command that waits for the next progress report of the run
+func (r *syncRun) next() tea.Cmd {
+	return func() tea.Msg {
+		msg, ok := <-r.events
+		if !ok {
+			return syncProgressMsg{run: r, done: true}
+		}
+		return msg
+	}
+}
+
+// applySyncProgress records a progress report and, once the run is over, builds the summary.
+// A run without failures or cancellation quits like the synchronous sync did.
+func (m model) applySyncProgress(msg syncProgressMsg) (tea.Model, tea.Cmd) {
+	run := m.run
+	if run == nil || msg.run != run {
+		return m, nil
+	}
+
+	if !msg.done {
+		step := &run.steps[msg.step]
+		if step.status == stepPending {
+			step.started = time.Now()
+		}
+		step.status = msg.status
+		step.stage = msg.stage
+		step.err = msg.err
+		if msg.status == stepDone || msg.status == stepFailed {
+			step.finished = time.Now()
+			step.stage = ""
+		}
+		if msg.stats != nil {
+			m.copyStats[step.target] = *msg.stats
+		}
+		step.committed = msg.success
+		if msg.prURL != "" {
+			step.prURL = msg.prURL
+			m.prURLs[step.repo] = msg.prURL
+		}
+		return m, run.next()
+	}
+
+	run.done = true
+	run.cancel()
+	m.preview = previewData{} // The previewed file may have been synced
+	if run.quitting {
+		return m, tea.Quit
+	}
+
+	var copyErr error
+	var gitErrors []error
+	var successRepos []string
+	copied, copyFailures := 0, 0
+	for i := range run.steps {
+		step := &run.steps[i]
+		switch {
+		case step.status == stepPending:
+			step.status = stepCancelled
+		case step.repo == "" && step.status == stepDone:
+			copied++
+		case step.repo == "" && step.status == stepFailed:
+			copyFailures++
+			if copyErr == nil {
+				copyErr = fmt.Errorf("failed to copy to %s: %w", step.name, step.err)
+			}
+		case step.status == stepFailed:
+			gitErrors = append(gitErrors, step.err)
+		}
+		if step.committed {
+			successRepos = append(successRepos, step.repo)
+		}
+	}
+
+	if copyErr != nil {
+		switch {
+		case run.transactional && copyFailures == 1:
+			copyErr = fmt.Errorf("%w\nTransaction rolled back: no target was changed", copyErr)
+		case run.journal != nil && run.journal.ID != "":
+			copyErr = fmt.Errorf("%w\nUndo the targets already copied with: fmr undo %s", copyErr, run.journal.ID)
+		}
+		m.err = copyErr
+		return m, nil
+	}
+	if copied == len(m.syncTargets()) || (run.gitOnly && len(gitErrors) == 0 && !run.cancelled) {
+		m.exitSummary = m.generateExitSummary()
+	}
+
+	if len(gitErrors) > 0 {
+		errMsg := "Git workflow errors:\n"
+		for _, err := range gitErrors {
+			errMsg += fmt.Sprintf("- %v\n", err)
+		}
+		if len(successRepos) > 0 {
+			errMsg += fmt.Sprintf("\nSuccessfully committed to %d repositories", len(successRepos))
+		}
+		m.err = fmt.Errorf("%s", errMsg)
+		return m, nil
+	}
+
+	if run.cancelled {
+		m.err = fmt.Errorf("sync cancelled: %d step(s) were not run", countSteps(run.steps, stepCancelled))
+		return m, nil
+	}
+
+	if len(successRepos) > 0 {
+		m.exitSummary += m.generateGitSummary(successRepos, run.branch)
+	}
+	return m, tea.Quit
+}
+
+// countSteps returns the number of steps with the given status
+func countSteps(steps []syncStep, status syncStepStatus) int {
+	count := 0
+	for _, step := range steps {
+		if step.status == status {
+			count++
+		}
+	}
+	return count
+}
+
+// updateProgress handles keys in progress mode. While the sync runs, ESC or c cancels
+// the steps that have not started; afterwards ESC returns to the file list and ENTER or q exits.
+// CTRL+C cancels a running sync and quits once its worker has removed its worktrees.
+func (m *model) updateProgress(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		if m.run != nil && !m.run.done {
+			m.run.cancelled = true
+			m.run.quitting = true
+			m.run.cancel()
+			return m, nil
+		}
+		return m, tea.Quit
+
+	case "esc", "c":
+		if m.run != nil && !m.run.done {
+			m.run.cancelled = true
+			m.run.cancel()
+			return m, nil
+		}
+		if msg.String() == "esc" {
+			m.run = nil
+			m.mode = modeSelect
+			m.err = nil
+		}
+
+	case "enter", "q":
+		if m.run != nil && m.run.done {
+			return m, tea.Quit
+		}
+	}
+
+	return m, nil
+}
+
+// viewProgress renders the steps of the running sync with their status and timing
+func (m model) viewProgress() string {
+	run := m.run
+	var b strings.Builder
+
+	headerStyle := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("12"))
+	hintStyle := lipgloss.NewStyle().Foreground(lipgloss.AdaptiveColor{Light: "#666666", Dark: "#999999"})
+	dimStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("240"))
+	doneStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("10"))
+	failStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("9"))
+	cancelStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("11"))
+
+	elapsed := time.Since(run.started)
+	title := "FileMirror - Syncing"
+	switch {
+	case run.done && run.cancelled:
+		title = "FileMirror - Sync cancelled"
+	case run.quitting:
+		title = "FileMirror - Cancelling sync before quitting"
+	case run.done:
+		title = "FileMirror - Sync finished"
+	}
+	b.WriteString(headerStyle.Render(title) + "  " + dimStyle.Render(formatElapsed(elapsed)) + "\n\n")
+
+	for _, step := range run.steps {
+		var icon, detail string
+		style := lipgloss.NewStyle()
+		switch step.status {
+		case stepPending:
+			icon, style = "·", dimStyle
+			if run.cancelled {
+				detail = "cancelling"
+			}
+		case stepRunning:
+			icon = m.spinner.View()
+			detail = formatElapsed(time.Since(step.started))
+			if step.stage != "" {
+				detail = step.stage + ", " + detail
+			}
+		case stepDone:
+			icon, style = "✓", doneStyle
+			detail = formatElapsed(step.finished.Sub(step.started))
+			if stats, ok := m.copyStats[step.target]; ok && step.repo == "" {
+				detail = fmt.Sprintf("-%d +%d lines, %s", stats[0], stats[1], detail)
+			}
+		case stepFailed:
+			icon, style = "✗", failStyle
+			detail = formatElapsed(step.finished.Sub(step.started))
+		case stepCancelled:
+			icon, style = "–", cancelStyle
+			detail = "not run"
+			if step.err != nil {
+				detail = step.err.Error()
+			}
+		}
+
+		line := fmt.Sprintf("%s %s", icon, style.Render(step.label(run.push)))
+		if detail != "" {
+			line += dimStyle.Render("  (" + detail + ")")
+		}
+		b.WriteString(line + "\n")
+		if step.prURL != "" {
+			b.WriteString(dimStyle.Render("    Pull request: "+step.prURL) + "\n")
+		}
+		if step.status == stepFailed && step.err != nil {
+			b.WriteString(failStyle.Render("    "+strings.ReplaceAll(strings.TrimSpace(step.err.Error()), "\n", "\n    ")) + "\n")
+		}
+	}
+
+	if m.err != nil {
+		b.WriteString("\n" + failStyle.Render("Error: "+m.err.Error()) + "\n")
+	}
+
+	hints := "ESC/c: cancel remaining steps • CTRL-C: quit"
+	if run.done {
+		hints = "ENTER/q: exit • ESC: back to file list"
+	}
+	b.WriteString("\n" + hintStyle.Render(hints))
+
+	return b.String()
+}
+
+// formatElapsed formats a duration with tenth-of-a-second precision
+func formatElapsed(d time.Duration) string {
+	return fmt.Sprintf("%.1fs", d.Seconds())
+}
@@ -0,0 +1,321 @@
+package filemirror
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// driveSync drives a background sync through Update until it reports completion
+func driveSync(t *testing.T, m model) (model, tea.Cmd) {
+	t.Helper()
+
+	m.startSync()
+	if m.mode != modeProgress {
+		t.Fatalf("mode = %v, want modeProgress", m.mode)
+	}
+
+	cmd := m.run.next()
+	for {
+		msg, ok := cmd().(syncProgressMsg)
+		if !ok {
+			t.Fatalf("Expected syncProgressMsg, got %T", msg)
+		}
+		updated, next := m.Update(msg)
+		m = updated.(model)
+		if msg.done {
+			return m, next
+		}
+		cmd = next
+	}
+}
+
+func TestSyncProgress(t *testing.T) {
+	repo := createTestGitRepo(t)
+	defer os.RemoveAll(repo)
+	plain := t.TempDir()
+
+	source := filepath.Join(plain, "source.txt")
+	repoTarget := filepath.Join(repo, "target.txt")
+	plainTarget := filepath.Join(plain, "target.txt")
+	for path, content := range map[string]string{source: "new\n", repoTarget: "old\n", plainTarget: "old\nline\n"} {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+
+	m := InitialModel("", plain)
+	m.sourceFile = &FileInfo{Path: source}
+	m.toggleSelected(FileInfo{Path: repoTarget})
+	m.toggleSelected(FileInfo{Path: plainTarget})
+	m.initGitWorkflow()
+	m.branchNameInput.SetValue("sync-progress")
+
+	m, cmd := driveSync(t, m)
+
+	if m.err != nil {
+		t.Fatalf("Unexpected error: %v", m.err)
+	}
+	if cmd == nil {
+		t.Fatal("Expected a successful sync to quit")
+	}
+	if _, ok := cmd().(tea.QuitMsg); !ok {
+		t.Error("Expected a successful sync to quit")
+	}
+
+	if len(m.run.steps) != 3 {
+		t.Fatalf("Expected 2 copy steps and 1 git step, got %d", len(m.run.steps))
+	}
+	for _, step := range m.run.steps {
+		if step.status != stepDone {
+			t.Errorf("Step %q status = %v, want done", step.label(false), step.status)
+		}
+	}
+	if !m.run.steps[2].committed {
+		t.Error("Expected the git step to be committed")
+	}
+
+	for _, target := range []string{repoTarget, plainTarget} {
+		content, _ := os.ReadFile(target)
+		if string(content) != "new\n" {
+			t.Errorf("%s = %q, want copied content", target, content)
+		}
+	}
+	if stats := m.copyStats[plainTarget]; stats != [2]int{2, 1} {
+		t.Errorf("copyStats = %v, want [2 1]", stats)
+	}
+	if !strings.Contains(m.exitSummary, "Copied to 2 target(s)") || !strings.Contains(m.exitSummary, "Git Workflow Summary") {
+		t.Errorf("Unexpected exit summary:\n%s", m.exitSummary)
+	}
+
+	branchFile, err := exec.Command("git", "-C", repo, "show", "sync-progress:target.txt").Output()
+	if err != nil || string(branchFile) != "new\n" {
+		t.Errorf("Branch content = %q, err = %v", branchFile, err)
+	}
+}
+
+func TestSyncProgressCopyFailure(t *testing.T) {
+	tmpDir := t.TempDir()
+	source := filepath.Join(tmpDir, "source.txt")
+	if err := os.WriteFile(source, []byte("new"), 0o644); err != nil {
+		t.Fatalf("Failed to write source: %v", err)
+	}
+
+	m := InitialModel("", tmpDir)
+	m.sourceFile = &FileInfo{Path: source}
+	m.toggleSelected(FileInfo{Path: filepath.Join(tmpDir, "missing", "a.txt")})
+	m.toggleSelected(FileInfo{Path: filepath.Join(tmpDir, "missing", "b.txt")})
+
+	m, cmd := driveSync(t, m)
+
+	if cmd != nil {
+		t.Error("Expected the progress view to stay open after a failure")
+	}
+	if m.err == nil || !strings.Contains(m.err.Error(), "failed to copy to") {
+		t.Errorf("err = %v, want copy failure", m.err)
+	}
+	if m.run.steps[0].status != stepFailed || m.run.steps[1].status != stepCancelled {
+		t.Errorf("Statuses = %v, %v; want failed, cancelled", m.run.steps[0].status, m.run.steps[1].status)
+	}
+	if !strings.Contains(m.viewProgress(), "ESC: back to file list") {
+		t.Error("Expected finished progress view to offer going back")
+	}
+
+	// ESC returns to the file list
+	updated, _ := m.updateProgress(keyMsg("esc"))
+	if updated.(*model).mode != modeSelect {
+		t.Error("Expected ESC to return to the file list")
+	}
+}
+
+func TestSyncProgressTransactional(t *testing.T) {
+	tmpDir := t.TempDir()
+	source := filepath.Join(tmpDir, "source.txt")
+	target := filepath.Join(tmpDir, "target.txt")
+	for path, content := range map[string]string{source: "new", target: "old"} {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+
+	m := InitialModel("", tmpDir)
+	m.transactional = true
+	m.sourceFile = &FileInfo{Path: source}
+	m.toggleSelected(FileInfo{Path: target})
+	m.toggleSelected(FileInfo{Path: filepath.Join(tmpDir, "missing", "a.txt")})
+
+	m, cmd := driveSync(t, m)
+
+	if cmd != nil {
+		t.Error("Expected the progress view to stay open after a failure")
+	}
+	if m.err == nil || !strings.Contains(m.err.Error(), "no target was changed") {
+		t.Errorf("err = %v, want rolled back transaction", m.err)
+	}
+	// Steps follow the sorted selection: missing/a.txt, then target.txt
+	if m.run.steps[0].status != stepFailed || m.run.steps[1].status != stepCancelled {
+		t.Errorf("Statuses = %v, %v; want failed, cancelled", m.run.steps[0].status, m.run.steps[1].status)
+	}
+	if !strings.Contains(m.viewProgress(), "not written") {
+		t.Error("Expected the untouched target to be shown as not written")
+	}
+	if content, _ := os.ReadFile(target); string(content) != "old" {
+		t.Errorf("target = %q, want it untouched", content)
+	}
+}
+
+func TestSyncProgressGitOnly(t *testing.T) {
+	repo := createTestGitRepo(t)
+	defer os.RemoveAll(repo)
+	commitFiles(t, repo, map[string]string{"target.txt": "old"})
+
+	source := filepath.Join(t.TempDir(), "source.txt")
+	target := filepath.Join(repo, "target.txt")
+	for path, content := range map[string]string{source: "new", target: "local edit"} {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+
+	m := InitialModel("", repo)
+	m.sourceFile = &FileInfo{Path: source}
+	m.toggleSelected(FileInfo{Path: target})
+	m.initGitWorkflow()
+	m.gitOnly = true
+	m.branchNameInput.SetValue("git-only")
+
+	m, cmd := driveSync(t, m)
+
+	if m.err != nil {
+		t.Fatalf("Unexpected error: %v", m.err)
+	}
+	if cmd == nil {
+		t.Fatal("Expected a successful sync to quit")
+	}
+	if len(m.run.steps) != 1 || !m.run.steps[0].committed {
+		t.Fatalf("Expected a single committed git step, got %d step(s)", len(m.run.steps))
+	}
+	if !strings.Contains(m.exitSummary, "working trees untouched") {
+		t.Errorf("Unexpected exit summary:\n%s", m.exitSummary)
+	}
+
+	if content, _ := os.ReadFile(target); string(content) != "local edit" {
+		t.Errorf("target = %q, want the working tree untouched", content)
+	}
+	branchFile, err := exec.Command("git", "-C", repo, "show", "git-only:target.txt").Output()
+	if err != nil || string(branchFile) != "new" {
+		t.Errorf("Branch content = %q, err = %v", branchFile, err)
+	}
+}
+
+func TestApplySyncProgress(t *testing.T) {
+	newRun := func() *syncRun {
+		run := &syncRun{
+			events: make(chan syncProgressMsg),
+			cancel: func() {},
+			steps: []syncStep{
+				{name: "a.txt", target: "/tmp/a.txt"},
+				{name: "/repos/api", repo: "/repos/api"},
+				{name: "/repos/web", repo: "/repos/web"},
+			},
+		}
+		close(run.events)
+		return run
+	}
+
+	t.Run("cancel skips pending steps", func(t *testing.T) {
+		m := InitialModel("", ".")
+		m.run = newRun()
+		m.mode = modeProgress
+		m.copyStats = map[string][2]int{}
+
+		updated, _ := m.Update(syncProgressMsg{run: m.run, step: 0, status: stepDone})
+		m = updated.(model)
+
+		// Cancelling while running keeps the progress view open
+		next, _ := m.updateProgress(keyMsg("c"))
+		m = *next.(*model)
+		if !m.run.cancelled {
+			t.Fatal("Expected c to cancel the run")
+		}
+
+		updated, cmd := m.Update(syncProgressMsg{run: m.run, done: true})
+		m = updated.(model)
+		if cmd != nil {
+			t.Error("Expected a cancelled sync not to quit")
+		}
+		if got := countSteps(m.run.steps, stepCancelled); got != 2 {
+			t.Errorf("Cancelled steps = %d, want 2", got)
+		}
+		if m.err == nil || !strings.Contains(m.err.Error(), "2 step(s) were not run") {
+			t.Errorf("err = %v", m.err)
+		}
+	})
+
+	t.Run("ctrl+c waits for the worker before quitting", func(t *testing.T) {
+		m := InitialModel("", ".")
+		m.run = newRun()
+		m.mode = modeProgress
+		m.copyStats = map[string][2]int{}
+
+		next, cmd := m.updateProgress(keyMsg("ctrl+c"))
+		m = *next.(*model)
+		if cmd != nil {
+			t.Error("Expected ctrl+c not to quit while the worker runs")
+		}
+		if !m.run.cancelled || !m.run.quitting {
+			t.Fatal("Expected ctrl+c to cancel the run")
+		}
+
+		updated, cmd := m.Update(syncProgressMsg{run: m.run, done: true})
+		m = updated.(model)
+		if cmd == nil {
+			t.Fatal("Expected the program to quit once the worker finished")
+		}
+		if _, ok := cmd().(tea.QuitMsg); !ok {
+			t.Error("Expected a quit command")
+		}
+	})
+
+	t.Run("push failure counts as committed", func(t *testing.T) {
+		m := InitialModel("", ".")
+		m.run = newRun()
+		m.mode = modeProgress
+		m.copyStats = map[string][2]int{}
+
+		for _, msg := range []syncProgressMsg{
+			{step: 0, status: stepDone},
+			{step: 1, status: stepRunning, stage: "pushing"},
+			{step: 1, status: stepFailed, success: true, err: errors.New("repo /repos/api (push failed): rejected")},
+			{step: 2, status: stepDone, success: true},
+			{done: true},
+		} {
+			msg.run = m.run
+			updated, _ := m.Update(msg)
+			m = updated.(model)
+		}
+
+		if m.err == nil {
+			t.Fatal("Expected git workflow error")
+		}
+		for _, want := range []string{"push failed", "Successfully committed to 2 repositories"} {
+			if !strings.Contains(m.err.Error(), want) {
+				t.Errorf("err = %q, want it to contain %q", m.err, want)
+			}
+		}
+	})
+
+	t.Run("stale runs are ignored", func(t *testing.T) {
+		m := InitialModel("", ".")
+		m.run = newRun()
+		updated, cmd := m.Update(syncProgressMsg{run: newRun(), done: true})
+		if cmd != nil || updated.(model).run.done {
+			t.Error("Expected messages of another run to be ignored")
+		}
+	})
+}
@@ -0,0 +1,556 @@
+package filemirror
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Pull request backends selectable with --pr-backend
+const (
+	prBackendAuto   = "auto"
+	prBackendGitHub = "github" // GitHub REST API, token from GITHUB_TOKEN or GH_TOKEN
+	prBackendGitLab = "gitlab" // GitLab REST API, token from GITLAB_TOKEN
+	prBackendGH     = "gh"     // GitHub CLI
+	prBackendGLab   = "glab"   // GitLab CLI
+)
+
+// Default pull request templates: the first line of the commit message as title,
+// the rest of it and the synced files as body
+const (
+	defaultPRTitleTemplate = `{{.Subject}}`
+	defaultPRBodyTemplate  = `{{with .Description}}{{.}}
+
+{{end}}Files synced by fmr:
+{{range .Files}}- {{.}}
+{{end}}`
+)
+
+// PROptions configures pull request creation after pushing
+type PROptions struct {
+	Enabled       bool
+	Backend       string // auto, github, gitlab, gh or glab
+	TitleTemplate string // text/template, see prTemplateData
+	BodyTemplate  string
+	Reviewers     []string
+	Labels        []string
+	Draft         bool
+}
+
+// validate checks the backend name and parses the templates, so mistakes surface
+// before anything is copied
+func (o PROptions) validate() error {
+	switch o.Backend {
+	case "", prBackendAuto, prBackendGitHub, prBackendGitLab, prBackendGH, prBackendGLab:
+	default:
+		return fmt.Errorf("unknown pull request backend %q (want auto, github, gitlab, gh or glab)", o.Backend)
+	}
+	for flag, text := range map[string]string{"--pr-title": o.TitleTemplate, "--pr-body": o.BodyTemplate} {
+		if _, err := template.New(flag).Parse(text); err != nil {
+			return fmt.Errorf("invalid %s template: %w", flag, err)
+		}
+	}
+	return nil
+}
+
+// prTemplateData is available to the title and body templates
+type prTemplateData struct {
+	Repo          string   // repository directory name
+	Branch        string   // pushed branch
+	Base          string   // branch the pull request targets
+	CommitMessage string   // full commit message
+	Subject       string   // first line of the commit message
+	Description   string   // commit message without the first line
+	Files         []string // synced files, relative to the repository
+}
+
+// prRequest is a pull request to open for a pushed branch
+type prRequest struct {
+	RepoPath  string
+	Project   string // "owner/repo" or GitLab "group/subgroup/project"
+	Head      string
+	Base      string
+	Title     string
+	Body      string
+	Reviewers []string
+	Labels    []string
+	Draft     bool
+}
+
+// prCreator opens a pull request and returns its URL
+type prCreator interface {
+	CreatePR(req prRequest) (string, error)
+}
+
+// remoteInfo is the host and project path of a git remote URL
+type remoteInfo struct {
+	Host    string
+	Project string
+}
+
+// parseRemoteURL parses https, ssh and scp-like ("git@host:owner/repo.git") remote URLs
+func parseRemoteURL(remote string) (remoteInfo, error) {
+	remote = strings.TrimSpace(remote)
+
+	var host, path string
+	if strings.Contains(remote, "://") {
+		u, err := url.Parse(remote)
+		if err != nil {
+			return remoteInfo{}, fmt.Errorf("invalid remote URL %q: %w", remote, err)
+		}
+		host, path = u.Hostname(), u.Path
+	} else if at := strings.Index(remote, ":"); at > 0 {
+		host, path = remote[:at], remote[at+1:]
+		if i := strings.LastIndex(host, "@"); i >= 0 {
+			host = host[i+1:]
+		}
+	}
+
+	path = strings.TrimSuffix(strings.Trim(path, "/"), ".git")
+	if host == "" || !strings.Contains(path, "/") {
+		return remoteInfo{}, fmt.Errorf("cannot determine project from remote URL %q", remote)
+	}
+	return remoteInfo{Host: host, Project: path}, nil
+}
+
+// originRemote returns the parsed origin remote of a repository
+func originRemote(repoPath string) (remoteInfo, error) {
+	output, err := exec.Command("git", "-C", repoPath, "remote", "get-url", "origin").Output()
+	if err != nil {
+		return remoteInfo{}, fmt.Errorf("failed to read origin remote: %w", err)
+	}
+	return parseRemoteURL(string(output))
+}
+
+// newPRCreator returns the backend for a repository remote. The auto backend uses
+// the REST API when a token is set and falls back to the CLI of the hosting service.
+func newPRCreator(backend string, remote remoteInfo) (prCreator, error) {
+	gitlab := strings.Contains(remote.Host, "gitlab")
+
+	if backend == "" || backend == prBackendAuto {
+		switch {
+		case gitlab && os.Getenv("GITLAB_TOKEN") != "":
+			backend = prBackendGitLab
+		case gitlab:
+			backend = prBackendGLab
+		case githubToken() != "":
+			backend = prBackendGitHub
+		default:
+			backend = prBackendGH
+		}
+	}
+
+	switch backend {
+	case prBackendGitHub:
+		token := githubToken()
+		if token == "" {
+			return nil, errors.New("GITHUB_TOKEN or GH_TOKEN is required for the github backend")
+		}
+		return &githubCreator{baseURL: githubAPIURL(remote.Host), token: token, client: http.DefaultClient}, nil
+	case prBackendGitLab:
+		token := os.Getenv("GITLAB_TOKEN")
+		if token == "" {
+			return nil, errors.New("GITLAB_TOKEN is required for the gitlab backend")
+		}
+		return &gitlabCreator{baseURL: gitlabAPIURL(remote.Host), token: token, client: http.DefaultClient}, nil
+	case prBackendGH, prBackendGLab:
+		return &cliCreator{tool: backend}, nil
+	default:
+		return nil, fmt.Errorf("unknown pull request backend %q (want auto, github, gitlab, gh or glab)", backend)
+	}
+}
+
+// githubToken returns the GitHub token from the environment
+func githubToken() string {
+	if token := os.Getenv("GITHUB_TOKEN"); token != "" {
+		return token
+	}
+	return os.Getenv("GH_TOKEN")
+}
+
+// githubAPIURL returns the REST API root for a GitHub host. GITHUB_API_URL overrides it.
+func githubAPIURL(host string) string {
+	if apiURL := os.Getenv("GITHUB_API_URL"); apiURL != "" {
+		return strings.TrimSuffix(apiURL, "/")
+	}
+	if host == "github.com" {
+		return "https://api.github.com"
+	}
+	return "https://" + host + "/api/v3" // GitHub Enterprise Server
+}
+
+// gitlabAPIURL returns the REST API root for a GitLab host. GITLAB_API_URL overrides it.
+func gitlabAPIURL(host string) string {
+	if apiURL := os.Getenv("GITLAB_API_URL"); apiURL != "" {
+		return strings.TrimSuffix(apiURL, "/")
+	}
+	return "https://" + host + "/api/v4"
+}
+
+// openPullRequest opens a pull request for a pushed branch into base and returns its URL
+func openPullRequest(opts PROptions, repoPath, base, branchName, commitMessage string, files []string) (string, error) {
+	remote, err := originRemote(repoPath)
+	if err != nil {
+		return "", err
+	}
+
+	creator, err := newPRCreator(opts.Backend, remote)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := buildPRRequest(opts, repoPath, remote.Project, base, branchName, commitMessage, files)
+	if err != nil {
+		return "", err
+	}
+
+	return creator.CreatePR(req)
+}
+
+// prBaseBranch returns the branch a pull request for a sync branch started from ref targets:
+// the local or origin branch ref names, or the branch checked out if ref is HEAD.
+// It returns "" for a tag, a commit or a detached HEAD.
+func prBaseBranch(repoPath, ref string) string {
+	if ref == "HEAD" {
+		output, err := exec.Command("git", "-C", repoPath, "symbolic-ref", "--quiet", "--short", "HEAD").Output()
+		if err != nil {
+			return ""
+		}
+		return strings.TrimSpace(string(output))
+	}
+	if refExists(repoPath, "refs/heads/"+ref) {
+		return ref
+	}
+	if branch, ok := strings.CutPrefix(ref, "origin/"); ok && refExists(repoPath, "refs/remotes/"+ref) {
+		return branch
+	}
+	return ""
+}
+
+// buildPRRequest renders the title and body templates for a repository. The pull request
+// targets base, or the default branch if base is empty.
+func buildPRRequest(opts PROptions, repoPath, project, base, branchName, commitMessage string, files []string) (prRequest, error) {
+	if base == "" {
+		var err error
+		if base, err = getDefaultBranch(repoPath); err != nil {
+			return prRequest{}, err
+		}
+	}
+
+	subject, description, _ := strings.Cut(strings.TrimSpace(commitMessage), "\n")
+	data := prTemplateData{
+		Repo:          filepath.Base(repoPath),
+		Branch:        branchName,
+		Base:          base,
+		CommitMessage: commitMessage,
+		Subject:       strings.TrimSpace(subject),
+		Description:   strings.TrimSpace(description),
+	}
+	for _, file := range files {
+		data.Files = append(data.Files, relativeTo(repoPath, file))
+	}
+
+	titleTemplate, bodyTemplate := opts.TitleTemplate, opts.BodyTemplate
+	if titleTemplate == "" {
+		titleTemplate = defaultPRTitleTemplate
+	}
+	if bodyTemplate == "" {
+		bodyTemplate = defaultPRBodyTemplate
+	}
+
+	title, err := renderPRTemplate("title", titleTemplate, data)
+	if err != nil {
+		return prRequest{}, err
+	}
+	body, err := renderPRTemplate("body", bodyTemplate, data)
+	if err != nil {
+		return prRequest{}, err
+	}
+
+	return prRequest{
+		RepoPath:  repoPath,
+		Project:   project,
+		Head:      branchName,
+		Base:      base,
+		Title:     strings.TrimSpace(title),
+		Body:      body,
+		Reviewers: opts.Reviewers,
+		Labels:    opts.Labels,
+		Draft:     opts.Draft,
+	}, nil
+}
+
+// renderPRTemplate executes a pull request title or body template
+func renderPRTemplate(name, text string, data prTemplateData) (string, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid pull request %s template: %w", name, err)
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, data); err != nil {
+		return "", fmt.Errorf("pull request %s template: %w", name, err)
+	}
+	return b.String(), nil
+}
+
+// githubCreator opens pull requests through the GitHub REST API
+type githubCreator struct {
+	baseURL string
+	token   string
+	client  *http.Client
+}
+
+func (g *githubCreator) CreatePR(req prRequest) (string, error) {
+	var pr struct {
+		Number  int    `json:"number"`
+		HTMLURL string `json:"html_url"`
+	}
+	err := g.do(http.MethodPost, "/repos/"+req.Project+"/pulls", map[string]any{
+		"title": req.Title,
+		"body":  req.Body,
+		"head":  req.Head,
+		"base":  req.Base,
+		"draft": req.Draft,
+	}, &pr)
+	if err != nil {
+		return "", fmt.Errorf("failed to create pull request: %w", err)
+	}
+
+	if len(req.Reviewers) > 0 {
+		path := fmt.Sprintf("/repos/%s/pulls/%d/requested_reviewers", req.Project, pr.Number)
+		if err := g.do(http.MethodPost, path, map[string]any{"reviewers": req.Reviewers}, nil); err != nil {
+			return pr.HTMLURL, fmt.Errorf("created %s, but requesting reviewers failed: %w", pr.HTMLURL, err)
+		}
+	}
+	if len(req.Labels) > 0 {
+		// Pull requests share the issue label API
+		path := fmt.Sprintf("/repos/%s/issues/%d/labels", req.Project, pr.Number)
+		if err := g.do(http.MethodPost, path, map[string]any{"labels": req.Labels}, nil); err != nil {
+			return pr.HTMLURL, fmt.Errorf("created %s, but adding labels failed: %w", pr.HTMLURL, err)
+		}
+	}
+
+	return pr.HTMLURL, nil
+}
+
+func (g *githubCreator) do(method, path string, body, result any) error {
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+g.token)
+	header.Set("Accept", "application/vnd.github+json")
+	return doJSON(g.client, method, g.baseURL+path, header, body, result)
+}
+
+// gitlabCreator opens merge requests through the GitLab REST API
+type gitlabCreator struct {
+	baseURL string
+	token   string
+	client  *http.Client
+}
+
+func (g *gitlabCreator) CreatePR(req prRequest) (string, error) {
+	title := req.Title
+	if req.Draft {
+		title = "Draft: " + title
+	}
+	payload := map[string]any{
+		"source_branch": req.Head,
+		"target_branch": req.Base,
+		"title":         title,
+		"description":   req.Body,
+	}
+	if len(req.Labels) > 0 {
+		payload["labels"] = strings.Join(req.Labels, ",")
+	}
+	if len(req.Reviewers) > 0 {
+		// GitLab assigns reviewers by user ID
+		ids := make([]int, 0, len(req.Reviewers))
+		for _, username := range req.Reviewers {
+			id, err := g.userID(username)
+			if err != nil {
+				return "", err
+			}
+			ids = append(ids, id)
+		}
+		payload["reviewer_ids"] = ids
+	}
+
+	var mr struct {
+		WebURL string `json:"web_url"`
+	}
+	path := "/projects/" + url.PathEscape(req.Project) + "/merge_requests"
+	if err := g.do(http.MethodPost, path, payload, &mr); err != nil {
+		return "", fmt.Errorf("failed to create merge request: %w", err)
+	}
+	return mr.WebURL, nil
+}
+
+// userID looks up the ID of a GitLab user by username
+func (g *gitlabCreator) userID(username string) (int, error) {
+	var users []struct {
+		ID int `json:"id"`
+	}
+	if err := g.do(http.MethodGet, "/users?username="+url.QueryEscape(username), nil, &users); err != nil {
+		return 0, fmt.Errorf("failed to look up reviewer %q: %w", username, err)
+	}
+	if len(users) == 0 {
+		return 0, fmt.Errorf("unknown reviewer %q", username)
+	}
+	return users[0].ID, nil
+}
+
+func (g *gitlabCreator) do(method, path string, body, result any) error {
+	header := http.Header{}
+	header.Set("PRIVATE-TOKEN", g.token)
+	return doJSON(g.client, method, g.baseURL+path, header, body, result)
+}
+
+// doJSON sends a JSON request and decodes the JSON response into result (if not nil)
+func doJSON(client *http.Client, method, endpoint string, header http.Header, body, result any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, endpoint, reader)
+	if err != nil {
+		return err
+	}
+	req.Header = header
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	if client.Timeout == 0 {
+		copied := *client
+		copied.Timeout = 30 * time.Second
+		client = &copied
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s %s: %s: %s", method, endpoint, resp.Status, strings.TrimSpace(string(data)))
+	}
+	if result == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, result); err != nil {
+		return fmt.Errorf("invalid response from %s: %w", endpoint, err)
+	}
+	return nil
+}
+
+// cliCreator opens pull requests with the gh or glab command line tool,
+// using the tool's own authentication
+type cliCreator struct {
+	tool string // gh or glab
+}
+
+func (c *cliCreator) CreatePR(req prRequest) (string, error) {
+	var args []string
+	switch c.tool {
+	case prBackendGH:
+		args = []string{"pr", "create", "--title", req.Title, "--body", req.Body, "--base", req.Base, "--head", req.Head}
+		for _, reviewer := range req.Reviewers {
+			args = append(args, "--reviewer", reviewer)
+		}
+		for _, label := range req.Labels {
+			args = append(args, "--label", label)
+		}
+		if req.Draft {
+			args = append(args, "--draft")
+		}
+	case prBackendGLab:
+		args = []string{"mr", "create", "--yes", "--title", req.Title, "--description", req.Body,
+			"--target-branch", req.Base, "--source-branch", req.Head}
+		if len(req.Reviewers) > 0 {
+			args = append(args, "--reviewer", strings.Join(req.Reviewers, ","))
+		}
+		if len(req.Labels) > 0 {
+			args = append(args, "--label", strings.Join(req.Labels, ","))
+		}
+		if req.Draft {
+			args = append(args, "--draft")
+		}
+	default:
+		return "", fmt.Errorf("unknown pull request tool %q", c.tool)
+	}
+
+	cmd := exec.Command(c.tool, args...)
+	cmd.Dir = req.RepoPath
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("%s failed: %w\n%s", c.tool, err, strings.TrimSpace(string(output)))
+	}
+
+	// Both tools print the URL of the new pull request on the last line
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	return strings.TrimSpace(lines[len(lines)-1]), nil
+}
+
+// parsePRFlag parses the pull request flag at args[i] into opts and returns the number
+// of arguments it consumed, or 0 if args[i] is not a pull request flag. Every pull request
+// flag implies --pr.
+func parsePRFlag(args []string, i int, opts *PROptions) (int, error) {
+	value := func(what string) (string, error) {
+		if i+1 >= len(args) {
+			return "", fmt.Errorf("%s requires %s", args[i], what)
+		}
+		return args[i+1], nil
+	}
+
+	var err error
+	var v string
+	switch args[i] {
+	case "--pr":
+		opts.Enabled = true
+		return 1, nil
+	case "--draft":
+		opts.Enabled = true
+		opts.Draft = true
+		return 1, nil
+	case "--pr-backend":
+		v, err = value("a backend name")
+		opts.Backend = v
+	case "--pr-title":
+		v, err = value("a template")
+		opts.TitleTemplate = v
+	case "--pr-body":
+		v, err = value("a template")
+		opts.BodyTemplate = v
+	case "--reviewer":
+		v, err = value("a user name")
+		opts.Reviewers = append(opts.Reviewers, v)
+	case "--label":
+		v, err = value("a label")
+		opts.Labels = append(opts.Labels, v)
+	default:
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	opts.Enabled = true
+	return 2, nil
+}
@@ -0,0 +1,426 @@
+package filemirror
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestParseRemoteURL(t *testing.T) {
+	tests := []struct {
+		remote  string
+		want    remoteInfo
+		wantErr bool
+	}{
+		{remote: "https://github.com/acme/widgets.git", want: remoteInfo{Host: "github.com", Project: "acme/widgets"}},
+		{remote: "git@github.com:acme/widgets.git\n", want: remoteInfo{Host: "github.com", Project: "acme/widgets"}},
+		{remote: "ssh://git@gitlab.example.com:2222/platform/tools/widgets.git", want: remoteInfo{Host: "gitlab.example.com", Project: "platform/tools/widgets"}},
+		{remote: "https://gitlab.com/group/project", want: remoteInfo{Host: "gitlab.com", Project: "group/project"}},
+		{remote: "/srv/git/widgets.git", wantErr: true},
+		{remote: "https://github.com/widgets", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.remote, func(t *testing.T) {
+			got, err := parseRemoteURL(tt.remote)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected error, got %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("parseRemoteURL() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewPRCreator(t *testing.T) {
+	tests := []struct {
+		name    string
+		backend string
+		host    string
+		env     map[string]string
+		want    string // type of the creator
+		tool    string
+		wantErr bool
+	}{
+		{name: "auto github with token", host: "github.com", env: map[string]string{"GITHUB_TOKEN": "t"}, want: "*filemirror.githubCreator"},
+		{name: "auto github without token", host: "github.com", want: "*filemirror.cliCreator", tool: "gh"},
+		{name: "auto gitlab with token", host: "gitlab.com", env: map[string]string{"GITLAB_TOKEN": "t"}, want: "*filemirror.gitlabCreator"},
+		{name: "auto gitlab without token", host: "gitlab.example.com", want: "*filemirror.cliCreator", tool: "glab"},
+		{name: "explicit github needs token", backend: "github", host: "github.com", wantErr: true},
+		{name: "explicit gh", backend: "gh", host: "gitlab.com", want: "*filemirror.cliCreator", tool: "gh"},
+		{name: "unknown backend", backend: "bitbucket", host: "github.com", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, key := range []string{"GITHUB_TOKEN", "GH_TOKEN", "GITLAB_TOKEN"} {
+				t.Setenv(key, tt.env[key])
+			}
+
+			creator, err := newPRCreator(tt.backend, remoteInfo{Host: tt.host, Project: "a/b"})
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("Expected error, got %T", creator)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got := reflect.TypeOf(creator).String(); got != tt.want {
+				t.Errorf("creator = %s, want %s", got, tt.want)
+			}
+			if cli, ok := creator.(*cliCreator); ok && cli.tool != tt.tool {
+				t.Errorf("tool = %q, want %q", cli.tool, tt.tool)
+			}
+		})
+	}
+}
+
+// recordedRequest is a request received by an API stand-in
+type recordedRequest struct {
+	Method string
+	Path   string
+	Query  string
+	Header http.Header
+	Body   map[string]any
+}
+
+// newAPIStandIn starts an HTTP server that records requests and answers them from responses,
+// keyed by "METHOD /path"
+func newAPIStandIn(t *testing.T, responses map[string]string) (*httptest.Server, *[]recordedRequest) {
+	t.Helper()
+
+	var requests []recordedRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := recordedRequest{Method: r.Method, Path: r.URL.EscapedPath(), Query: r.URL.RawQuery, Header: r.Header}
+		if r.Body != nil {
+			_ = json.NewDecoder(r.Body).Decode(&req.Body)
+		}
+		requests = append(requests, req)
+
+		response, ok := responses[r.Method+" "+r.URL.EscapedPath()]
+		if !ok {
+			http.Error(w, `{"message":"not found"}`, http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(response))
+	}))
+	t.Cleanup(server.Close)
+
+	return server, &requests
+}
+
+func TestGitHubCreator(t *testing.T) {
+	server, requests := newAPIStandIn(t, map[string]string{
+		"POST /repos/acme/widgets/pulls":                       `{"number": 7, "html_url": "https://github.com/acme/widgets/pull/7"}`,
+		"POST /repos/acme/widgets/pulls/7/requested_reviewers": `{}`,
+		"POST /repos/acme/widgets/issues/7/labels":             `[]`,
+	})
+
+	creator := &githubCreator{baseURL: server.URL, token: "secret", client: server.Client()}
+	url, err := creator.CreatePR(prRequest{
+		Project:   "acme/widgets",
+		Head:      "chore/sync",
+		Base:      "main",
+		Title:     "Sync config",
+		Body:      "Body",
+		Reviewers: []string{"alice"},
+		Labels:    []string{"chore", "sync"},
+		Draft:     true,
+	})
+	if err != nil {
+		t.Fatalf("CreatePR failed: %v", err)
+	}
+	if url != "https://github.com/acme/widgets/pull/7" {
+		t.Errorf("url = %q", url)
+	}
+
+	if len(*requests) != 3 {
+		t.Fatalf("Expected 3 requests, got %d", len(*requests))
+	}
+	create := (*requests)[0]
+	if create.Header.Get("Authorization") != "Bearer secret" {
+		t.Errorf("Authorization = %q", create.Header.Get("Authorization"))
+	}
+	want := map[string]any{"title": "Sync config", "body": "Body", "head": "chore/sync", "base": "main", "draft": true}
+	if !reflect.DeepEqual(create.Body, want) {
+		t.Errorf("create body = %v, want %v", create.Body, want)
+	}
+	if got := (*requests)[1].Body["reviewers"]; !reflect.DeepEqual(got, []any{"alice"}) {
+		t.Errorf("reviewers = %v", got)
+	}
+	if got := (*requests)[2].Body["labels"]; !reflect.DeepEqual(got, []any{"chore", "sync"}) {
+		t.Errorf("labels = %v", got)
+	}
+
+	// API errors are reported with the response
+	_, err = creator.CreatePR(prRequest{Project: "acme/missing", Head: "x", Base: "main", Title: "t"})
+	if err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("Expected 404 error, got %v", err)
+	}
+}
+
+func TestGitLabCreator(t *testing.T) {
+	server, requests := newAPIStandIn(t, map[string]string{
+		"GET /users": `[{"id": 42}]`,
+		"POST /projects/platform%2Fwidgets/merge_requests": `{"web_url": "https://gitlab.com/platform/widgets/-/merge_requests/3"}`,
+	})
+
+	creator := &gitlabCreator{baseURL: server.URL, token: "secret", client: server.Client()}
+	url, err := creator.CreatePR(prRequest{
+		Project:   "platform/widgets",
+		Head:      "chore/sync",
+		Base:      "main",
+		Title:     "Sync config",
+		Body:      "Body",
+		Reviewers: []string{"bob"},
+		Labels:    []string{"chore", "sync"},
+		Draft:     true,
+	})
+	if err != nil {
+		t.Fatalf("CreatePR failed: %v", err)
+	}
+	if url != "https://gitlab.com/platform/widgets/-/merge_requests/3" {
+		t.Errorf("url = %q", url)
+	}
+
+	if len(*requests) != 2 {
+		t.Fatalf("Expected 2 requests, got %d", len(*requests))
+	}
+	if (*requests)[0].Query != "username=bob" {
+		t.Errorf("user lookup query = %q", (*requests)[0].Query)
+	}
+	create := (*requests)[1]
+	if create.Header.Get("PRIVATE-TOKEN") != "secret" {
+		t.Errorf("PRIVATE-TOKEN = %q", create.Header.Get("PRIVATE-TOKEN"))
+	}
+	want := map[string]any{
+		"source_branch": "chore/sync",
+		"target_branch": "main",
+		"title":         "Draft: Sync config",
+		"description":   "Body",
+		"labels":        "chore,sync",
+		"reviewer_ids":  []any{float64(42)},
+	}
+	if !reflect.DeepEqual(create.Body, want) {
+		t.Errorf("create body = %v, want %v", create.Body, want)
+	}
+}
+
+func TestCLICreator(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Uses a shell script as fake gh")
+	}
+
+	// A fake gh records its arguments and prints a URL like the real one
+	binDir := t.TempDir()
+	argsFile := filepath.Join(binDir, "args")
+	script := "#!/bin/sh\nprintf '%s\\n' \"$@\" > " + argsFile + "\necho 'Creating pull request'\necho https://github.com/acme/widgets/pull/9\n"
+	if err := os.WriteFile(filepath.Join(binDir, "gh"), []byte(script), 0o755); err != nil {
+		t.Fatalf("Failed to write fake gh: %v", err)
+	}
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	creator := &cliCreator{tool: "gh"}
+	url, err := creator.CreatePR(prRequest{
+		RepoPath:  t.TempDir(),
+		Head:      "chore/sync",
+		Base:      "main",
+		Title:     "Sync config",
+		Body:      "Body",
+		Reviewers: []string{"alice", "bob"},
+		Labels:    []string{"chore"},
+		Draft:     true,
+	})
+	if err != nil {
+		t.Fatalf("CreatePR failed: %v", err)
+	}
+	if url != "https://github.com/acme/widgets/pull/9" {
+		t.Errorf("url = %q", url)
+	}
+
+	args, _ := os.ReadFile(argsFile)
+	want := "pr create --title Sync config --body Body --base main --head chore/sync --reviewer alice --reviewer bob --label chore --draft"
+	if got := strings.Join(strings.Split(strings.TrimSpace(string(args)), "\n"), " "); got != want {
+		t.Errorf("gh args = %q, want %q", got, want)
+	}
+}
+
+func TestBuildPRRequest(t *testing.T) {
+	repo := createTestGitRepo(t)
+	defer os.RemoveAll(repo)
+
+	files := []string{filepath.Join(repo, "a", "config.yaml"), filepath.Join(repo, "b", "config.yaml")}
+	commitMessage := "chore: Sync config.yaml\n\nSynchronized from canonical/config.yaml"
+
+	tests := []struct {
+		name      string
+		opts      PROptions
+		base      string
+		wantTitle string
+		wantBody  string
+		wantBase  string
+		wantErr   bool
+	}{
+		{
+			name:      "default templates",
+			wantTitle: "chore: Sync config.yaml",
+			wantBody:  "Synchronized from canonical/config.yaml\n\nFiles synced by fmr:\n- a/config.yaml\n- b/config.yaml\n",
+			wantBase:  "main",
+		},
+		{
+			name:      "chosen base",
+			opts:      PROptions{BodyTemplate: "into {{.Base}}"},
+			base:      "release/1.x",
+			wantTitle: "chore: Sync config.yaml",
+			wantBody:  "into release/1.x",
+			wantBase:  "release/1.x",
+		},
+		{
+			name: "custom templates",
+			opts: PROptions{
+				TitleTemplate: "[{{.Repo}}] {{.Subject}}",
+				BodyTemplate:  "{{.Branch}} into {{.Base}}: {{len .Files}} file(s)",
+			},
+			wantTitle: "[" + filepath.Base(repo) + "] chore: Sync config.yaml",
+			wantBody:  "chore/sync into main: 2 file(s)",
+			wantBase:  "main",
+		},
+		{
+			name:    "unknown field",
+			opts:    PROptions{TitleTemplate: "{{.Nope}}"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := buildPRRequest(tt.opts, repo, "acme/widgets", tt.base, "chore/sync", commitMessage, files)
+			if tt.wantErr {
+				if err == nil {
+					t.Error("Expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if req.Title != tt.wantTitle {
+				t.Errorf("Title = %q, want %q", req.Title, tt.wantTitle)
+			}
+			if req.Body != tt.wantBody {
+				t.Errorf("Body = %q, want %q", req.Body, tt.wantBody)
+			}
+			if req.Base != tt.wantBase || req.Head != "chore/sync" || req.Project != "acme/widgets" {
+				t.Errorf("Unexpected request %+v", req)
+			}
+		})
+	}
+}
+
+func TestPRBaseBranch(t *testing.T) {
+	repo := createTestGitRepo(t)
+	defer os.RemoveAll(repo)
+	for _, args := range [][]string{
+		{"branch", "release"},
+		{"tag", "v1"},
+		{"update-ref", "refs/remotes/origin/stable", "HEAD"},
+	} {
+		if output, err := exec.Command("git", append([]string{"-C", repo}, args...)...).CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, output)
+		}
+	}
+
+	tests := []struct {
+		ref  string
+		want string
+	}{
+		{"main", "main"},
+		{"release", "release"},
+		{"origin/stable", "stable"},
+		{"HEAD", "main"},
+		{"v1", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			if got := prBaseBranch(repo, tt.ref); got != tt.want {
+				t.Errorf("prBaseBranch(%q) = %q, want %q", tt.ref, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestRunGitWorkflowOpensPullRequests pushes to a local remote and opens the pull request
+// against a GitHub API stand-in
+func TestRunGitWorkflowOpensPullRequests(t *testing.T) {
+	repo := createTestGitRepo(t)
+	defer os.RemoveAll(repo)
+
+	// Fetch from the GitHub URL, push to a local bare repository
+	bare := filepath.Join(t.TempDir(), "widgets.git")
+	for _, args := range [][]string{
+		{"init", "-q", "--bare", bare},
+		{"-C", repo, "remote", "add", "origin", "https://github.com/acme/widgets.git"},
+		{"-C", repo, "config", "remote.origin.pushurl", bare},
+	} {
+		if err := exec.Command("git", args...).Run(); err != nil {
+			t.Fatalf("git %v failed: %v", args, err)
+		}
+	}
+
+	file := filepath.Join(repo, "config.yaml")
+	if err := os.WriteFile(file, []byte("synced"), 0o644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	server, requests := newAPIStandIn(t, map[string]string{
+		"POST /repos/acme/widgets/pulls": `{"number": 1, "html_url": "https://github.com/acme/widgets/pull/1"}`,
+	})
+	t.Setenv("GITHUB_TOKEN", "secret")
+	t.Setenv("GITHUB_API_URL", server.URL)
+
+	// The pull request targets the chosen base, not the default branch
+	if err := exec.Command("git", "-C", repo, "branch", "release").Run(); err != nil {
+		t.Fatalf("git branch failed: %v", err)
+	}
+
+	results := runGitWorkflow(map[string][]string{repo: {file}}, gitWorkflowOptions{
+		Branch:  "chore/sync",
+		Message: "chore: Sync",
+		Push:    true,
+		Workers: 2,
+		PR:      PROptions{Enabled: true},
+		Base:    "release",
+	})
+
+	if len(results) != 1 {
+		t.Fatalf("Expected 1 result, got %d", len(results))
+	}
+	res := results[0]
+	if res.Err != nil || !res.Success {
+		t.Fatalf("Unexpected result: %+v", res)
+	}
+	if res.PRURL != "https://github.com/acme/widgets/pull/1" {
+		t.Errorf("PRURL = %q", res.PRURL)
+	}
+	if len(*requests) != 1 || (*requests)[0].Body["head"] != "chore/sync" || (*requests)[0].Body["base"] != "release" || (*requests)[0].Body["title"] != "chore: Sync" {
+		t.Errorf("Unexpected API requests: %+v", *requests)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"os"
 	"path/filepath"
 	"runtime/debug"
+	"strings"
 
 	tea "github.com/charmbracelet/bubbletea"
 )
@@ -47,10 +48,27 @@ func init() {
 
 // Config holds the parsed command-line configuration
 type Config struct {
-	WorkDir      string
-	InitialQuery string
-	ShowHelp     bool
-	ShowVersion  bool
+	WorkDir       string
+	ExtraRoots    []string // directories scanned besides WorkDir, from repeated --path
+	InitialQuery  string
+	ShowHelp      bool
+	ShowVersion   bool
+	ManifestPath  string     // manifest to preselect from (default: .fmr.yaml in WorkDir)
+	Group         string     // manifest group to preselect (default: the first)
+	Scan          ScanConfig // scan scope flags, overriding the manifest's scan section
+	GitWorkers    int        // repositories committed and pushed at the same time (0: default)
+	PR            PROptions  // pull requests to open after pushing, preselected in the confirm screen
+	Transactional bool       // write all targets or none of them
+	GitOnly       bool       // preselect committing on the branch only, leaving working trees untouched
+	BaseRef       string     // ref new branches start from, preselected in the confirm screen
+	Ticket        string     // ticket ID for the branch and commit templates, preselected in the confirm screen
+	FetchBase     bool       // preselect fetching origin before creating branches
+	Section       string     // managed section to mirror instead of the whole file
+	InsertMissing bool       // append the section to targets without its markers
+	Keys          []string   // key paths to mirror instead of the whole file
+	Format        string     // format of the keyed file: json, yaml or toml (default: from the extension)
+	Merge         bool       // merge with local changes since the last sync instead of overwriting
+	Force         bool       // write merges with conflicts, conflict markers included
 }
 
 // parseArgs parses command-line arguments and returns a Config
@@ -69,11 +87,117 @@ func parseArgs(args []string) (Config, error) {
 			return cfg, nil
 		case "-p", "--path":
 			if i+1 < len(args) {
-				cfg.WorkDir = args[i+1]
+				if cfg.WorkDir == "" {
+					cfg.WorkDir = args[i+1]
+				} else {
+					cfg.ExtraRoots = append(cfg.ExtraRoots, args[i+1])
+				}
 				i++ // Skip next arg
 			} else {
 				return cfg, errors.New("--path requires a directory argument")
 			}
+		case "--manifest":
+			if i+1 < len(args) {
+				cfg.ManifestPath = args[i+1]
+				i++
+			} else {
+				return cfg, errors.New("--manifest requires a file argument")
+			}
+		case "-g", "--group":
+			if i+1 < len(args) {
+				cfg.Group = args[i+1]
+				i++
+			} else {
+				return cfg, errors.New("--group requires a group name")
+			}
+		case "--depth":
+			if i+1 >= len(args) {
+				return cfg, errors.New("--depth requires a number of directory levels")
+			}
+			depth, err := parseDepth(args[i+1])
+			if err != nil {
+				return cfg, err
+			}
+			cfg.Scan.Depth = &depth
+			i++
+		case "--exclude":
+			if i+1 < len(args) {
+				cfg.Scan.Exclude = append(cfg.Scan.Exclude, args[i+1])
+				i++
+			} else {
+				return cfg, errors.New("--exclude requires a name or path pattern")
+			}
+		case "--include-hidden":
+			cfg.Scan.IncludeHidden = true
+		case "-j", "--jobs":
+			if i+1 >= len(args) {
+				return cfg, errors.New("--jobs requires a number of repositories")
+			}
+			jobs, err := parseJobs(args[i+1])
+			if err != nil {
+				return cfg, err
+			}
+			cfg.GitWorkers = jobs
+			i++
+		case "--transactional":
+			cfg.Transactional = true
+		case "--git-only":
+			cfg.GitOnly = true
+		case "--base":
+			if i+1 < len(args) {
+				cfg.BaseRef = args[i+1]
+				i++
+			} else {
+				return cfg, errors.New("--base requires a ref")
+			}
+		case "--fetch":
+			cfg.FetchBase = true
+		case "--ticket":
+			if i+1 < len(args) {
+				cfg.Ticket = args[i+1]
+				i++
+			} else {
+				return cfg, errors.New("--ticket requires a ticket ID")
+			}
+		case "--section":
+			if i+1 >= len(args) {
+				return cfg, errors.New("--section requires a section name")
+			}
+			if err := validateSectionName(args[i+1]); err != nil {
+				return cfg, err
+			}
+			cfg.Section = args[i+1]
+			i++
+		case "--insert-missing":
+			cfg.InsertMissing = true
+		case "-k", "--key":
+			if i+1 >= len(args) {
+				return cfg, errors.New("--key requires a key path")
+			}
+			if err := validateKeyPath(args[i+1]); err != nil {
+				return cfg, err
+			}
+			cfg.Keys = append(cfg.Keys, args[i+1])
+			i++
+		case "--format":
+			if i+1 >= len(args) {
+				return cfg, errors.New("--format requires json, yaml or toml")
+			}
+			if _, err := structuredFormatFor("", args[i+1]); err != nil {
+				return cfg, err
+			}
+			cfg.Format = args[i+1]
+			i++
+		case "--merge":
+			cfg.Merge = true
+		case "--force":
+			cfg.Force = true
+		case "--pr", "--draft", "--pr-backend", "--pr-title", "--pr-body", "--reviewer", "--label":
+			n, err := parsePRFlag(args, i, &cfg.PR)
+			if err != nil {
+				return cfg, err
+			}
+			i += n - 1
 		default:
 			// If not a flag, treat as search pattern
 			if cfg.InitialQuery == "" {
@@ -82,6 +206,22 @@ func parseArgs(args []string) (Config, error) {
 		}
 	}
 
+	if err := cfg.PR.validate(); err != nil {
+		return cfg, err
+	}
+	if cfg.InsertMissing && cfg.Section == "" {
+		return cfg, errors.New("--insert-missing requires --section")
+	}
+	if cfg.Format != "" && len(cfg.Keys) == 0 {
+		return cfg, errors.New("--format requires --key")
+	}
+	if len(cfg.Keys) > 0 && cfg.Section != "" {
+		return cfg, errors.New("--key cannot be combined with --section")
+	}
+	if cfg.Force && !cfg.Merge {
+		return cfg, errors.New("--force requires --merge")
+	}
+
 	return cfg, nil
 }
 
@@ -124,6 +264,22 @@ func Run() {
 // RunWithArgs runs the application with provided arguments and writers
 // Returns an exit code (0 for success, non-zero for errors)
 func RunWithArgs(args []string, stdout, stderr io.Writer) int {
+	// Headless subcommands run without the interactive interface
+	if len(args) > 0 {
+		switch args[0] {
+		case "sync":
+			return runSync(args[1:], stdout, stderr)
+		case "check":
+			return runCheck(args[1:], stdout, stderr)
+		case "undo":
+			return runUndo(args[1:], stdout, stderr)
+		case "history":
+			return runHistory(args[1:], stdout, stderr)
+		case "status":
+			return runStatus(args[1:], stdout, stderr)
+		}
+	}
+
 	cfg, err := parseArgs(args)
 	if err != nil {
 		_, _ = fmt.Fprintf(stderr, "Error: %v\n", err) //nolint:errcheck // Error writing to stderr is not actionable
@@ -140,6 +296,13 @@ func RunWithArgs(args []string, stdout, stderr io.Writer) int {
 		return 0
 	}
 
+	// Resolve extra roots before changing into the working directory
+	for i, root := range cfg.ExtraRoots {
+		if absRoot, err := filepath.Abs(root); err == nil {
+			cfg.ExtraRoots[i] = absRoot
+		}
+	}
+
 	// Validate and setup working directory
 	absPath, err := validateAndSetupWorkDir(cfg.WorkDir)
 	if err != nil {
@@ -156,6 +319,58 @@ func RunWithArgs(args []string, stdout, stderr io.Writer) int {
 	// Create the model with initial query and working directory
 	m := InitialModel(cfg.InitialQuery, workDir)
 
+	// Preselect source and targets from a manifest if there is one
+	scanOpts := defaultScanOptions()
+	roots := append([]string{m.workDir}, cfg.ExtraRoots...)
+	manifestPath := cfg.ManifestPath
+	if manifestPath == "" {
+		manifestPath = findManifest(m.workDir)
+	}
+	if manifestPath != "" {
+		manifest, err := loadManifest(manifestPath)
+		if err != nil {
+			_, _ = fmt.Fprintf(stderr, "Error: %v\n", err) //nolint:errcheck // Error writing to stderr is not actionable
+			return 1
+		}
+		if err := m.setManifest(manifest, cfg.Group); err != nil {
+			_, _ = fmt.Fprintf(stderr, "Error: %v\n", err) //nolint:errcheck // Error writing to stderr is not actionable
+			return 1
+		}
+		scanOpts = scanOpts.withConfig(manifest.Scan)
+		roots = append(roots, manifest.scanRoots()[1:]...)
+	} else if cfg.Group != "" {
+		_, _ = fmt.Fprintln(stderr, "Error: --group requires a manifest") //nolint:errcheck // Error writing to stderr is not actionable
+		return 1
+	}
+
+	// Scan flags take precedence over the manifest's scan section
+	m.scanOpts = scanOpts.withConfig(cfg.Scan)
+	if cfg.GitWorkers > 0 {
+		m.gitWorkers = cfg.GitWorkers
+	}
+	m.prOpts = cfg.PR
+	m.transactional = cfg.Transactional
+	m.gitOnly = cfg.GitOnly
+	m.baseRef = cfg.BaseRef
+	m.fetchBase = cfg.FetchBase
+	m.ticket = cfg.Ticket
+	m.section = cfg.Section
+	m.insertSection = cfg.InsertMissing
+	m.keys = cfg.Keys
+	m.keyFormat = cfg.Format
+	m.merge = cfg.Merge
+	m.force = cfg.Force
+
+	// Scan several roots at once if more than one is given
+	if len(roots) > 1 {
+		if err := m.changeRoots(strings.Join(roots, ", ")); err != nil {
+			_, _ = fmt.Fprintf(stderr, "Error: %v\n", err) //nolint:errcheck // Error writing to stderr is not actionable
+			return 1
+		}
+		m.pathInput.SetValue(strings.Join(m.roots, ", "))
+		m.lastPathValue = m.pathInput.Value()
+	}
+
 	// Start the program
 	p := tea.NewProgram(m, tea.WithAltScreen())
 	finalModel, err := p.Run()
@@ -185,15 +400,65 @@ func PrintHelpTo(w io.Writer) {
 
 USAGE:
     fmr [OPTIONS] [PATTERN]
+    fmr sync --source FILE --target FILE|GLOB [OPTIONS]
+    fmr check --source FILE --target FILE|GLOB [OPTIONS]
+    fmr undo [RUN-ID]
+    fmr history
+    fmr status [--path DIR]
 
 DESCRIPTION:
     FileMirror helps you quickly propagate changes from one source file to
     multiple target files. It provides an interactive interface to search,
     select, and synchronize file contents across your project.
 
+COMMANDS:
+    sync               Copy a source file to targets without the interactive
+                       interface (for scripts and CI). See 'fmr sync --help'
+    check              Report targets that drifted from their source, with
+                       exit codes for CI (read-only). See 'fmr check --help'
+    undo               Restore the targets of a previous sync run
+    history            List previous sync runs that can be undone
+    status             Report mirrored files that are behind their source, from
+                       the .fmr.lock files of the repositories. See 'fmr status --help'
+
 OPTIONS:
     -p, --path PATH    Change to directory PATH before searching
                        Supports both absolute and relative paths
+                       Repeat to search several roots at once (shown as root:path)
+    --manifest FILE    Preselect source and targets from a mirror manifest
+                       Default: .fmr.yaml in the working directory, if present
+    -g, --group NAME   Manifest group to preselect (default: the first group)
+    --depth N          Directory levels to scan (default: 4, "unlimited" or -1 for no limit)
+    --exclude PATTERN  Skip files and directories matching PATTERN (repeatable)
+                       A name like "fixtures" matches at any depth, a path like
+                       "services/legacy" matches from the working directory
+    --include-hidden   Also scan hidden directories skipped by default (.cache, .next)
+    -j, --jobs N       Repositories to commit and push at the same time (default: 4)
+    --transactional    Write all targets or none: stage and verify every target
+                       first, roll back written targets if one fails
+    --git-only         Preselect committing on the sync branch only, leaving the
+                       working trees and their current branches untouched
+    --base REF         Preselect the ref new branches start from: HEAD, or a
+                       branch, tag or commit (default: the default branch)
+    --fetch            Preselect fetching origin before creating branches
+    --ticket ID        Ticket ID for branch and commit message templates such as
+                       fix/{{.Ticket}}-{{.SourceName}} (see 'fmr sync --help')
+    --section NAME     Only mirror the region between the "BEGIN fmr:NAME" and
+                       "END fmr:NAME" marker lines; the diff preview shows only it
+    --insert-missing   Append the section to targets without its markers
+    -k, --key PATH     Only mirror the value at a dotted key path of a JSON, YAML or
+                       TOML file, e.g. compilerOptions (repeatable); the diff preview
+                       adds a key-level diff
+    --format NAME      Format of the keyed files: json, yaml or toml
+                       (default: from the source's extension)
+    --merge            Keep local changes to the targets: merge the source's changes
+                       since the last sync into each target; the diff preview shows
+                       the merge and its conflicts, which are not written
+    --force            Write merge conflicts, with conflict markers, and overwrite
+                       changed targets without a known last sync
+    --pr               Preselect opening a pull request per pushed branch
+                       Also: --pr-backend, --pr-title, --pr-body, --reviewer,
+                       --label, --draft (see 'fmr sync --help')
     -h, --help         Show this help message
     -v, --version      Show version information
 
@@ -205,13 +470,17 @@ KEYBOARD SHORTCUTS:
     TAB            Cycle focus forward: Path → Search → File List → Path
     Shift+TAB      Cycle focus backward: Path ← Search ← File List
     CTRL-R         Reload files from current path (when on Path/Search)
-    p / CTRL-P     Cycle preview modes: hidden → plain → diff → hidden
+    p / CTRL-P     Cycle preview modes: hidden → plain → diff → side-by-side → hidden
     PgUp/PgDn      Scroll preview (or CTRL-U/CTRL-D)
+    + / -          More/fewer context lines around changes (diff preview)
+    w              Cycle highlighting inside changed lines: word → char → off
     ?              Show help overlay with all shortcuts
     Type           Edit focused input (Path or Search)
     ↑/↓ or k/j     Navigate through file list (when List is focused)
     s              Mark current file as SOURCE (when List is focused)
     Space          Toggle current file as TARGET (when List is focused)
+    g              Preselect the next manifest group (when a manifest is loaded)
+    o              Edit scan settings: depth, excludes, hidden directories
     Enter          Proceed to confirmation (requires source + targets)
     y              Confirm and execute sync operation
     n / Esc        Cancel operation and return to selection
@@ -231,10 +500,13 @@ FEATURES:
     - Real-time file filtering with glob pattern support (*.go, *.java, etc.)
     - Live file preview panel - see file contents before syncing
     - Diff preview mode - compare target files against source with colored diff
+    - Side-by-side preview mode on terminals at least 140 columns wide
     - Searches up to 4 directory levels deep
     - Excludes common directories (node_modules, .git, vendor, etc.)
+    - Respects .gitignore, .git/info/exclude and .fmrignore files
     - Shows file metadata (size, modified time, git branch)
     - Safe atomic file operations preserving permissions
+    - Mirror manifests (.fmr.yaml) to preselect recurring source/target groups
     - Split-screen layout with scrollable preview
 
 EXAMPLES:
@@ -242,6 +514,8 @@ EXAMPLES:
     fmr "*.go"                    # Start with Go files filter
     fmr --path ~/projects "*.go"  # Start in specific directory
     fmr -p /tmp config.json       # Start in /tmp, filter config.json
+    fmr sync -s a/config.yaml -t 'services/*/config.yaml' --branch chore/sync
+                                  # Sync without the interactive interface
 
     Once running:
     - Path is focused by default - type to edit
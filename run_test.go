@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"sync"
 	"testing"
@@ -267,6 +268,80 @@ func TestParseArgs(t *testing.T) {
 			},
 			wantErr: false,
 		},
+		{
+			name: "manifest and group",
+			args: []string{"--manifest", ".fmr.yaml", "-g", "lint"},
+			wantCfg: Config{
+				ManifestPath: ".fmr.yaml",
+				Group:        "lint",
+			},
+			wantErr: false,
+		},
+		{
+			name:        "manifest flag without value",
+			args:        []string{"--manifest"},
+			wantErr:     true,
+			errContains: "--manifest requires a file argument",
+		},
+		{
+			name: "repeated path adds scan roots",
+			args: []string{"-p", "api", "--path", "../web", "-p", "../infra"},
+			wantCfg: Config{
+				WorkDir:    "api",
+				ExtraRoots: []string{"../web", "../infra"},
+			},
+			wantErr: false,
+		},
+		{
+			name: "scan scope flags",
+			args: []string{"--depth", "8", "--exclude", "fixtures", "--exclude", "services/legacy", "--include-hidden"},
+			wantCfg: Config{
+				Scan: ScanConfig{Depth: depthPtr(8), Exclude: []string{"fixtures", "services/legacy"}, IncludeHidden: true},
+			},
+			wantErr: false,
+		},
+		{
+			name: "unlimited depth",
+			args: []string{"--depth", "unlimited"},
+			wantCfg: Config{
+				Scan: ScanConfig{Depth: depthPtr(-1)},
+			},
+			wantErr: false,
+		},
+		{
+			name:        "invalid depth",
+			args:        []string{"--depth", "deep"},
+			wantErr:     true,
+			errContains: "depth must be a number",
+		},
+		{
+			name: "git workers",
+			args: []string{"-j", "12"},
+			wantCfg: Config{
+				GitWorkers: 12,
+			},
+			wantErr: false,
+		},
+		{
+			name:        "invalid git workers",
+			args:        []string{"--jobs", "0"},
+			wantErr:     true,
+			errContains: "jobs must be a positive number",
+		},
+		{
+			name: "pull requests",
+			args: []string{"--pr", "--pr-backend", "gh", "--label", "sync"},
+			wantCfg: Config{
+				PR: PROptions{Enabled: true, Backend: "gh", Labels: []string{"sync"}},
+			},
+			wantErr: false,
+		},
+		{
+			name:        "pull request flag without value",
+			args:        []string{"--reviewer"},
+			wantErr:     true,
+			errContains: "--reviewer requires",
+		},
 		{
 			name: "multiple non-flag args takes first as query",
 			args: []string{"first", "second"},
@@ -307,6 +382,27 @@ func TestParseArgs(t *testing.T) {
 			if cfg.ShowVersion != tt.wantCfg.ShowVersion {
 				t.Errorf("ShowVersion = %v, want %v", cfg.ShowVersion, tt.wantCfg.ShowVersion)
 			}
+			if cfg.ManifestPath != tt.wantCfg.ManifestPath {
+				t.Errorf("ManifestPath = %q, want %q", cfg.ManifestPath, tt.wantCfg.ManifestPath)
+			}
+			if cfg.Group != tt.wantCfg.Group {
+				t.Errorf("Group = %q, want %q", cfg.Group, tt.wantCfg.Group)
+			}
+			if cfg.GitWorkers != tt.wantCfg.GitWorkers {
+				t.Errorf("GitWorkers = %d, want %d", cfg.GitWorkers, tt.wantCfg.GitWorkers)
+			}
+			if !reflect.DeepEqual(cfg.PR, tt.wantCfg.PR) {
+				t.Errorf("PR = %+v, want %+v", cfg.PR, tt.wantCfg.PR)
+			}
+			if strings.Join(cfg.ExtraRoots, ",") != strings.Join(tt.wantCfg.ExtraRoots, ",") {
+				t.Errorf("ExtraRoots = %v, want %v", cfg.ExtraRoots, tt.wantCfg.ExtraRoots)
+			}
+			gotScan := defaultScanOptions().withConfig(cfg.Scan)
+			wantScan := defaultScanOptions().withConfig(tt.wantCfg.Scan)
+			if gotScan.MaxDepth != wantScan.MaxDepth || gotScan.IncludeHidden != wantScan.IncludeHidden ||
+				strings.Join(gotScan.Exclude, ",") != strings.Join(wantScan.Exclude, ",") {
+				t.Errorf("Scan = %+v, want %+v", gotScan, wantScan)
+			}
 		})
 	}
 }
@@ -559,3 +655,8 @@ func TestRunWithArgs(t *testing.T) {
 		})
 	}
 }
+
+// depthPtr returns a pointer to a scan depth, for ScanConfig literals
+func depthPtr(depth int) *int {
+	return &depth
+}
@@ -2,21 +2,35 @@ package filemirror
 
 import (
 	"fmt"
-	"io/fs"
 	"os"
 	"path/filepath"
+	"runtime"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
 type FileInfo struct {
-	Path     string
+	Path     string // relative to Root
+	Root     string // absolute scan root the file was found under
 	Size     int64
 	Modified time.Time
 	Branch   string
 }
 
+// fullPath returns the absolute path of the file. Files without a Root are resolved from baseDir.
+func (f FileInfo) fullPath(baseDir string) string {
+	if filepath.IsAbs(f.Path) {
+		return f.Path
+	}
+	if f.Root != "" {
+		return filepath.Join(f.Root, f.Path)
+	}
+	return filepath.Join(baseDir, f.Path)
+}
+
 var excludeDirs = map[string]bool{
 	"node_modules": true,
 	".git":         true,
@@ -28,88 +42,297 @@ var excludeDirs = map[string]bool{
 	".cache":       true,
 }
 
+// scanWorkers is the number of directories read concurrently during a scan
+var scanWorkers = runtime.NumCPU() * 2
+
+// defaultMaxDepth is the number of directory levels scanned below the work directory
+const defaultMaxDepth = 4
+
+// scanOptions controls the scope of a scan
+type scanOptions struct {
+	MaxDepth      int      // directory levels below the work directory; negative for unlimited
+	Exclude       []string // directory or file names, or slash-separated path patterns, to skip
+	IncludeHidden bool     // also scan hidden directories excluded by default, like .cache (never .git)
+}
+
+// defaultScanOptions returns the scan scope used when nothing is configured
+func defaultScanOptions() scanOptions {
+	return scanOptions{MaxDepth: defaultMaxDepth}
+}
+
+// withConfig returns opts overridden by the settings in cfg. Excludes are added to the existing ones.
+func (opts scanOptions) withConfig(cfg ScanConfig) scanOptions {
+	if cfg.Depth != nil {
+		opts.MaxDepth = *cfg.Depth
+	}
+	opts.Exclude = append(append([]string(nil), opts.Exclude...), cfg.Exclude...)
+	if cfg.IncludeHidden {
+		opts.IncludeHidden = true
+	}
+	return opts
+}
+
+// skipDir reports whether a directory is excluded by default or by the options
+func (opts scanOptions) skipDir(name, relPath string) bool {
+	if excludeDirs[name] {
+		hidden := strings.HasPrefix(name, ".") && name != ".git"
+		if !opts.IncludeHidden || !hidden {
+			return true
+		}
+	}
+	return opts.excluded(name, relPath)
+}
+
+// excluded reports whether a file or directory matches one of the Exclude entries.
+// Entries without a slash match the name at any depth; others match the path from the work directory.
+func (opts scanOptions) excluded(name, relPath string) bool {
+	for _, pattern := range opts.Exclude {
+		pattern = strings.TrimSuffix(pattern, "/")
+		if strings.Contains(pattern, "/") {
+			if matchPathPattern(strings.TrimPrefix(pattern, "/"), filepath.ToSlash(relPath)) {
+				return true
+			}
+		} else if matched, err := filepath.Match(pattern, name); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+// parseDepth parses a scan depth: a number of directory levels, or "unlimited" (also -1)
+func parseDepth(value string) (int, error) {
+	if value == "unlimited" {
+		return -1, nil
+	}
+	depth, err := strconv.Atoi(value)
+	if err != nil || depth < -1 {
+		return 0, fmt.Errorf("depth must be a number of directory levels or \"unlimited\", got %q", value)
+	}
+	return depth, nil
+}
+
+// scanFiles scans workDir with the default scan options
 func scanFiles(workDir, pattern string) ([]FileInfo, error) {
+	return scanFilesWithOptions(workDir, pattern, defaultScanOptions())
+}
+
+// scanFilesWithOptions lists the files below workDir whose name matches pattern,
+// newest first, within the scope described by opts
+func scanFilesWithOptions(workDir, pattern string, opts scanOptions) ([]FileInfo, error) {
+	return collectScan(func(emit func([]FileInfo)) error {
+		return walkRoot(workDir, pattern, opts, newGitInfoCache(), emit)
+	})
+}
+
+// collectScan runs a scan that reports files in batches and returns all files, newest first
+func collectScan(scan func(emit func([]FileInfo)) error) ([]FileInfo, error) {
+	var mu sync.Mutex
 	var files []FileInfo
-	maxDepth := 4
 
+	err := scan(func(batch []FileInfo) {
+		mu.Lock()
+		files = append(files, batch...)
+		mu.Unlock()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sortFiles(files)
+	return files, nil
+}
+
+// fileBefore reports whether a sorts before b: newer files first, then by path
+func fileBefore(a, b *FileInfo) bool {
+	if !a.Modified.Equal(b.Modified) {
+		return a.Modified.After(b.Modified)
+	}
+	return a.fullPath("") < b.fullPath("")
+}
+
+// sortFiles sorts files by modification time (newest first), then by path
+func sortFiles(files []FileInfo) {
+	sort.Slice(files, func(i, j int) bool { return fileBefore(&files[i], &files[j]) })
+}
+
+// mergeFiles merges batch into the sorted files, keeping the order of sortFiles.
+// The batch is sorted in place.
+func mergeFiles(files, batch []FileInfo) []FileInfo {
+	sortFiles(batch)
+	merged := make([]FileInfo, 0, len(files)+len(batch))
+	i, j := 0, 0
+	for i < len(files) && j < len(batch) {
+		if fileBefore(&batch[j], &files[i]) {
+			merged = append(merged, batch[j])
+			j++
+		} else {
+			merged = append(merged, files[i])
+			i++
+		}
+	}
+	merged = append(merged, files[i:]...)
+	return append(merged, batch[j:]...)
+}
+
+// walkRoot walks the directory tree below workDir with a bounded pool of workers.
+// The matching files of each directory are passed to emit, which may be called concurrently.
+func walkRoot(workDir, pattern string, opts scanOptions, gitCache *gitInfoCache, emit func([]FileInfo)) error {
 	// Use workDir as the base directory
 	if workDir == "" {
 		var err error
 		workDir, err = os.Getwd()
 		if err != nil {
-			return nil, fmt.Errorf("failed to get current directory: %w", err)
+			return fmt.Errorf("failed to get current directory: %w", err)
 		}
 	}
 
 	// Make sure workDir is absolute
 	absWorkDir, err := filepath.Abs(workDir)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get absolute path: %w", err)
+		return fmt.Errorf("failed to get absolute path: %w", err)
 	}
 
-	err = filepath.WalkDir(absWorkDir, func(path string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return nil // Skip files/dirs we can't access
+	// Ignore rules from the enclosing repository apply to the whole scan;
+	// each directory adds the rules of its own ignore files for its subtree
+	parentIgnore := &ignoreMatcher{}
+	parentIgnore.loadParents(absWorkDir)
+
+	var wg sync.WaitGroup
+	workers := make(chan struct{}, scanWorkers)
+
+	var visit func(dir string, level int, ignore *ignoreMatcher)
+	visit = func(dir string, level int, ignore *ignoreMatcher) {
+		defer wg.Done()
+
+		workers <- struct{}{}
+		ignore = ignore.withDir(dir)
+		files, subdirs := scanDir(absWorkDir, dir, pattern, opts, ignore, gitCache)
+		<-workers
+
+		if len(files) > 0 {
+			emit(files)
 		}
 
-		// Calculate relative depth from the work directory
+		// Files in a subdirectory are one level deeper
+		if opts.MaxDepth >= 0 && level+1 > opts.MaxDepth {
+			return
+		}
+		for _, subdir := range subdirs {
+			wg.Add(1)
+			go visit(subdir, level+1, ignore)
+		}
+	}
+
+	wg.Add(1)
+	visit(absWorkDir, 0, parentIgnore)
+	wg.Wait()
+
+	return nil
+}
+
+// scanDir reads one directory and returns its matching files and the subdirectories to scan
+func scanDir(absWorkDir, dir, pattern string, opts scanOptions, ignore *ignoreMatcher, gitCache *gitInfoCache) ([]FileInfo, []string) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, nil // Skip directories we can't read
+	}
+
+	var files []FileInfo
+	var subdirs []string
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
 		relPath, err := filepath.Rel(absWorkDir, path)
 		if err != nil {
-			return nil
-		}
-		depth := strings.Count(relPath, string(os.PathSeparator))
-		if depth > maxDepth {
-			return fs.SkipDir
+			continue
 		}
 
-		// Skip excluded directories
-		if d.IsDir() {
-			if excludeDirs[d.Name()] {
-				return fs.SkipDir
+		// Skip excluded and ignored directories
+		if entry.IsDir() {
+			if !opts.skipDir(entry.Name(), relPath) && !ignore.ignored(path, true) {
+				subdirs = append(subdirs, path)
 			}
-			return nil
+			continue
 		}
 
 		// Skip if not a regular file
-		if !d.Type().IsRegular() {
-			return nil
+		if !entry.Type().IsRegular() {
+			continue
+		}
+
+		// Skip excluded files and files matched by .gitignore, .git/info/exclude or .fmrignore
+		if opts.excluded(entry.Name(), relPath) || ignore.ignored(path, false) {
+			continue
 		}
 
 		// Filter by pattern if provided
-		if pattern != "" && !matchesPattern(d.Name(), pattern) {
-			return nil
+		if pattern != "" && !matchesPattern(entry.Name(), pattern) {
+			continue
 		}
 
 		// Get file info
-		info, err := d.Info()
+		info, err := entry.Info()
 		if err != nil {
-			return nil // Skip files we can't stat
+			continue // Skip files we can't stat
 		}
 
-		// Get git branch for this file
-		branch := getGitBranch(path)
-
 		// Store relative path from work directory
 		files = append(files, FileInfo{
 			Path:     relPath,
+			Root:     absWorkDir,
 			Size:     info.Size(),
 			Modified: info.ModTime(),
-			Branch:   branch,
+			Branch:   gitCache.branch(path),
 		})
+	}
 
-		return nil
+	return files, subdirs
+}
+
+// scanRoots scans several root directories and merges the results, newest first
+func scanRoots(roots []string, pattern string, opts scanOptions) ([]FileInfo, error) {
+	return collectScan(func(emit func([]FileInfo)) error {
+		return walkRoots(roots, pattern, opts, emit)
 	})
+}
 
-	if err != nil {
-		return nil, fmt.Errorf("failed to scan files: %w", err)
+// walkRoots walks every root with a shared git metadata cache, passing batches of files to emit
+func walkRoots(roots []string, pattern string, opts scanOptions, emit func([]FileInfo)) error {
+	gitCache := newGitInfoCache()
+	for _, root := range roots {
+		if err := walkRoot(root, pattern, opts, gitCache, emit); err != nil {
+			return fmt.Errorf("%s: %w", root, err)
+		}
 	}
+	return nil
+}
 
-	// Sort by modification time (newest first)
-	sort.Slice(files, func(i, j int) bool {
-		return files[i].Modified.After(files[j].Modified)
-	})
+// rootLabels returns a short label per root for "root:relative/path" display: the directory
+// name, or the full path if two roots share a name
+func rootLabels(roots []string) map[string]string {
+	count := make(map[string]int, len(roots))
+	for _, root := range roots {
+		count[filepath.Base(root)]++
+	}
 
-	return files, nil
+	labels := make(map[string]string, len(roots))
+	for _, root := range roots {
+		labels[root] = filepath.Base(root)
+		if count[filepath.Base(root)] > 1 {
+			labels[root] = root
+		}
+	}
+	return labels
+}
+
+// splitRoots splits a comma-separated list of directories, dropping empty entries
+func splitRoots(value string) []string {
+	var roots []string
+	for _, root := range strings.Split(value, ",") {
+		if root = strings.TrimSpace(root); root != "" {
+			roots = append(roots, root)
+		}
+	}
+	return roots
 }
 
 func matchesPattern(filename, pattern string) bool {
@@ -4,8 +4,10 @@ import (
 	"os"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestMatchesPattern(t *testing.T) {
@@ -312,6 +314,32 @@ func TestScanFilesSorted(t *testing.T) {
 	}
 }
 
+func TestMergeFiles(t *testing.T) {
+	now := time.Now()
+	file := func(path string, age int) FileInfo {
+		return FileInfo{Path: path, Modified: now.Add(-time.Duration(age) * time.Minute)}
+	}
+
+	var files []FileInfo
+	batches := [][]FileInfo{
+		{file("c.txt", 3), file("a.txt", 1)},
+		{file("e.txt", 5), file("b.txt", 1), file("d.txt", 4)},
+		nil,
+		{file("f.txt", 0)},
+	}
+	for _, batch := range batches {
+		files = mergeFiles(files, batch)
+	}
+
+	var got []string
+	for _, f := range files {
+		got = append(got, f.Path)
+	}
+	if want := "f.txt,a.txt,b.txt,c.txt,d.txt,e.txt"; strings.Join(got, ",") != want {
+		t.Errorf("mergeFiles() order = %s, want %s", strings.Join(got, ","), want)
+	}
+}
+
 func TestScanFilesRelativePaths(t *testing.T) {
 	tmpDir, err := os.MkdirTemp("", "fmr-test-*")
 	if err != nil {
@@ -464,3 +492,130 @@ func TestScanFilesWithUnstatableFile(t *testing.T) {
 	// programming and rarely occurs in practice.
 	t.Skip("Skipping unstat-able file test - requires complex race conditions or special filesystem")
 }
+
+func TestScanFilesWithOptions(t *testing.T) {
+	tmpDir := t.TempDir()
+	for _, f := range []string{
+		"root.txt",
+		"a/b/c/d/e/f/deep.txt",
+		"fixtures/data.txt",
+		"services/legacy/config.yaml",
+		"services/api/config.yaml",
+		"services/api/fixtures/data.txt",
+		".cache/cached.txt",
+		".git/config",
+	} {
+		path := filepath.Join(tmpDir, filepath.FromSlash(f))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte("test"), 0o644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		opts scanOptions
+		want []string
+	}{
+		{
+			name: "defaults",
+			opts: defaultScanOptions(),
+			want: []string{"fixtures/data.txt", "root.txt", "services/api/config.yaml", "services/api/fixtures/data.txt", "services/legacy/config.yaml"},
+		},
+		{
+			name: "top level only",
+			opts: scanOptions{MaxDepth: 0},
+			want: []string{"root.txt"},
+		},
+		{
+			name: "unlimited depth",
+			opts: scanOptions{MaxDepth: -1},
+			want: []string{"a/b/c/d/e/f/deep.txt", "fixtures/data.txt", "root.txt", "services/api/config.yaml", "services/api/fixtures/data.txt", "services/legacy/config.yaml"},
+		},
+		{
+			name: "exclude by name and by path",
+			opts: scanOptions{MaxDepth: 4, Exclude: []string{"fixtures", "services/legacy"}},
+			want: []string{"root.txt", "services/api/config.yaml"},
+		},
+		{
+			name: "include hidden never includes .git",
+			opts: scanOptions{MaxDepth: 1, IncludeHidden: true},
+			want: []string{".cache/cached.txt", "fixtures/data.txt", "root.txt"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files, err := scanFilesWithOptions(tmpDir, "", tt.opts)
+			if err != nil {
+				t.Fatalf("scanFilesWithOptions failed: %v", err)
+			}
+
+			var got []string
+			for _, f := range files {
+				got = append(got, filepath.ToSlash(f.Path))
+			}
+			sort.Strings(got)
+
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("Got files %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScanRoots(t *testing.T) {
+	parent := t.TempDir()
+	api := filepath.Join(parent, "api")
+	web := filepath.Join(parent, "web")
+	for _, dir := range []string{api, web} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "config.yaml"), []byte("x"), 0o644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+
+	files, err := scanRoots([]string{api, web}, "", defaultScanOptions())
+	if err != nil {
+		t.Fatalf("scanRoots failed: %v", err)
+	}
+	if len(files) != 2 {
+		t.Fatalf("Expected 2 files, got %d", len(files))
+	}
+
+	roots := map[string]bool{}
+	for _, f := range files {
+		if f.Path != "config.yaml" {
+			t.Errorf("Path = %q, want relative to its root", f.Path)
+		}
+		roots[f.Root] = true
+		if f.fullPath("/elsewhere") != filepath.Join(f.Root, "config.yaml") {
+			t.Errorf("fullPath() = %q", f.fullPath("/elsewhere"))
+		}
+	}
+	if !roots[api] || !roots[web] {
+		t.Errorf("Expected files tagged with both roots, got %v", roots)
+	}
+}
+
+func TestRootLabels(t *testing.T) {
+	labels := rootLabels([]string{"/src/api", "/src/web", "/old/web"})
+	want := map[string]string{
+		"/src/api": "api",
+		"/src/web": "/src/web",
+		"/old/web": "/old/web",
+	}
+	for root, label := range want {
+		if labels[root] != label {
+			t.Errorf("label of %s = %q, want %q", root, labels[root], label)
+		}
+	}
+
+	if got := splitRoots(" a, ,b "); strings.Join(got, "|") != "a|b" {
+		t.Errorf("splitRoots() = %q", got)
+	}
+}
@@ -0,0 +1,183 @@
+package filemirror
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// scanSettingsField identifies the focused field of the scan settings panel
+type scanSettingsField int
+
+const (
+	scanFieldDepth scanSettingsField = iota
+	scanFieldExclude
+	scanFieldHidden
+	scanFieldCount
+)
+
+// scanSettings is the state of the scan settings panel while it is open
+type scanSettings struct {
+	depthInput   textinput.Model
+	excludeInput textinput.Model
+	hidden       bool
+	focus        scanSettingsField
+	err          error
+}
+
+// openScanSettings opens the scan settings panel prefilled with the current scan scope
+func (m *model) openScanSettings() {
+	depthInput := textinput.New()
+	depthInput.Placeholder = "4, or unlimited"
+	depthInput.CharLimit = 10
+	depthInput.Width = 20
+	depth := strconv.Itoa(m.scanOpts.MaxDepth)
+	if m.scanOpts.MaxDepth < 0 {
+		depth = "unlimited"
+	}
+	depthInput.SetValue(depth)
+	depthInput.Focus()
+
+	excludeInput := textinput.New()
+	excludeInput.Placeholder = "fixtures, services/legacy"
+	excludeInput.CharLimit = 256
+	excludeInput.Width = 50
+	excludeInput.SetValue(strings.Join(m.scanOpts.Exclude, ", "))
+
+	m.scanSettings = &scanSettings{
+		depthInput:   depthInput,
+		excludeInput: excludeInput,
+		hidden:       m.scanOpts.IncludeHidden,
+	}
+}
+
+// setFocus moves the panel focus, wrapping around at both ends
+func (s *scanSettings) setFocus(field scanSettingsField) {
+	s.focus = (field + scanFieldCount) % scanFieldCount
+	s.depthInput.Blur()
+	s.excludeInput.Blur()
+	switch s.focus {
+	case scanFieldDepth:
+		s.depthInput.Focus()
+	case scanFieldExclude:
+		s.excludeInput.Focus()
+	}
+}
+
+// options validates the panel fields and returns the scan scope they describe
+func (s *scanSettings) options() (scanOptions, error) {
+	opts := scanOptions{MaxDepth: defaultMaxDepth, IncludeHidden: s.hidden}
+
+	if value := strings.TrimSpace(s.depthInput.Value()); value != "" {
+		depth, err := parseDepth(value)
+		if err != nil {
+			return opts, err
+		}
+		opts.MaxDepth = depth
+	}
+
+	for _, pattern := range strings.Split(s.excludeInput.Value(), ",") {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			opts.Exclude = append(opts.Exclude, pattern)
+		}
+	}
+
+	return opts, nil
+}
+
+// updateScanSettings handles keys while the scan settings panel is open.
+// ENTER applies the settings and rescans, ESC discards them.
+func (m *model) updateScanSettings(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	s := m.scanSettings
+	var cmd tea.Cmd
+
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+
+	case "esc":
+		m.scanSettings = nil
+		return m, nil
+
+	case "tab", "down":
+		s.setFocus(s.focus + 1)
+		return m, nil
+
+	case "shift+tab", "up":
+		s.setFocus(s.focus - 1)
+		return m, nil
+
+	case " ":
+		if s.focus == scanFieldHidden {
+			s.hidden = !s.hidden
+			return m, nil
+		}
+
+	case "enter":
+		opts, err := s.options()
+		if err != nil {
+			s.err = err
+			return m, nil
+		}
+		m.scanOpts = opts
+		m.scanSettings = nil
+		m.err = nil
+
+		// Rescan files with the new scope
+		return m, m.startScan(m.searchInput.Value())
+	}
+
+	switch s.focus {
+	case scanFieldDepth:
+		s.depthInput, cmd = s.depthInput.Update(msg)
+	case scanFieldExclude:
+		s.excludeInput, cmd = s.excludeInput.Update(msg)
+	}
+	return m, cmd
+}
+
+// renderScanSettings renders the scan settings panel as a modal
+func (m model) renderScanSettings() string {
+	s := m.scanSettings
+	modalWidth := minInt(m.width-4, 70)
+
+	modalStyle := lipgloss.NewStyle().
+		Border(lipgloss.RoundedBorder()).
+		BorderForeground(lipgloss.Color("12")).
+		Padding(1, 2).
+		Width(modalWidth)
+	titleStyle := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("12"))
+	labelStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("240"))
+	focusedStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("12")).Bold(true)
+	hintStyle := lipgloss.NewStyle().Foreground(lipgloss.AdaptiveColor{Light: "#666666", Dark: "#999999"})
+	errorStyle := lipgloss.NewStyle().Foreground(lipgloss.Color("9"))
+
+	label := func(field scanSettingsField, text string) string {
+		if s.focus == field {
+			return focusedStyle.Render("▸ " + text)
+		}
+		return labelStyle.Render("  " + text)
+	}
+
+	hidden := "[ ]"
+	if s.hidden {
+		hidden = "[✓]"
+	}
+
+	var b strings.Builder
+	b.WriteString(titleStyle.Render("SCAN SETTINGS") + "\n\n")
+	b.WriteString(label(scanFieldDepth, "Depth (directory levels):") + "\n")
+	b.WriteString("  " + s.depthInput.View() + "\n\n")
+	b.WriteString(label(scanFieldExclude, "Exclude (comma-separated names or paths):") + "\n")
+	b.WriteString("  " + s.excludeInput.View() + "\n\n")
+	b.WriteString(label(scanFieldHidden, hidden+" Include hidden directories (.cache, .next)") + "\n\n")
+	if s.err != nil {
+		b.WriteString(errorStyle.Render("Error: "+s.err.Error()) + "\n\n")
+	}
+	b.WriteString(hintStyle.Render("TAB/↑/↓: navigate • SPACE: toggle • ENTER: apply & rescan • ESC: cancel"))
+
+	return lipgloss.Place(m.width, m.height, lipgloss.Center, lipgloss.Center, modalStyle.Render(b.String()))
+}
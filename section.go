@@ -0,0 +1,105 @@
+package filemirror
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// sectionNamePattern matches valid managed section names, e.g. "shared-lint"
+var sectionNamePattern = regexp.MustCompile(`^[\w.-]+$`)
+
+// validateSectionName checks a managed section name given in a manifest or a flag
+func validateSectionName(name string) error {
+	if !sectionNamePattern.MatchString(name) {
+		return fmt.Errorf("invalid section name %q: use letters, digits, '.', '_' and '-'", name)
+	}
+	return nil
+}
+
+// findSection returns the byte range of the named managed section in content, from the
+// start of its "BEGIN fmr:NAME" line to the end of its "END fmr:NAME" line. Markers may be
+// wrapped in any comment syntax. found is false if content has no BEGIN marker.
+func findSection(content []byte, name string) (start, end int, found bool, err error) {
+	begin, finish := "BEGIN fmr:"+name, "END fmr:"+name
+	start = -1
+	for offset := 0; offset < len(content); {
+		next := len(content)
+		if i := bytes.IndexByte(content[offset:], '\n'); i >= 0 {
+			next = offset + i + 1
+		}
+		line := string(content[offset:next])
+		switch {
+		case hasSectionMarker(line, begin):
+			if start >= 0 {
+				return 0, 0, false, fmt.Errorf("section %q: BEGIN marker inside the section", name)
+			}
+			start = offset
+		case hasSectionMarker(line, finish):
+			if start < 0 {
+				return 0, 0, false, fmt.Errorf("section %q: END marker without BEGIN marker", name)
+			}
+			return start, next, true, nil
+		}
+		offset = next
+	}
+	if start >= 0 {
+		return 0, 0, false, fmt.Errorf("section %q: BEGIN marker without END marker", name)
+	}
+	return 0, 0, false, nil
+}
+
+// hasSectionMarker reports whether line contains marker not followed by more of a section
+// name, so "BEGIN fmr:lint" does not match "BEGIN fmr:lint-extra"
+func hasSectionMarker(line, marker string) bool {
+	i := strings.Index(line, marker)
+	if i < 0 {
+		return false
+	}
+	rest := line[i+len(marker):]
+	return rest == "" || !sectionNamePattern.MatchString(rest[:1])
+}
+
+// extractSection returns the named section of content including its marker lines, always
+// ending with a newline
+func extractSection(content []byte, name string) ([]byte, error) {
+	start, end, found, err := findSection(content, name)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("section %q not found", name)
+	}
+	block := append([]byte(nil), content[start:end]...)
+	if !bytes.HasSuffix(block, []byte("\n")) {
+		block = append(block, '\n')
+	}
+	return block, nil
+}
+
+// spliceSection replaces the named section of current with block, leaving the rest of the
+// file untouched. If current has no such section, block is appended when insert is set.
+func spliceSection(current, block []byte, name string, insert bool) ([]byte, error) {
+	start, end, found, err := findSection(current, name)
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	switch {
+	case found:
+		b.Write(current[:start])
+		b.Write(block)
+		b.Write(current[end:])
+	case insert:
+		b.Write(current)
+		if len(current) > 0 && !bytes.HasSuffix(current, []byte("\n")) {
+			b.WriteByte('\n')
+		}
+		b.Write(block)
+	default:
+		return nil, fmt.Errorf("section %q not found in target (insert missing sections to add it)", name)
+	}
+	return b.Bytes(), nil
+}
@@ -0,0 +1,169 @@
+package filemirror
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSpliceSection(t *testing.T) {
+	block := []byte("# BEGIN fmr:lint\nlint:\n\tgolangci-lint run\n# END fmr:lint\n")
+
+	tests := []struct {
+		name        string
+		current     string
+		insert      bool
+		want        string
+		errContains string
+	}{
+		{
+			name:    "replaces the section only",
+			current: "build:\n\tgo build\n# BEGIN fmr:lint\nlint:\n\told\n# END fmr:lint\ntest:\n\tgo test\n",
+			want:    "build:\n\tgo build\n" + string(block) + "test:\n\tgo test\n",
+		},
+		{
+			name:    "other comment syntax and a last line without newline",
+			current: "a\n<!-- BEGIN fmr:lint -->\nold\n<!-- END fmr:lint -->",
+			want:    "a\n" + string(block),
+		},
+		{
+			name:    "similar section names are left alone",
+			current: "# BEGIN fmr:lint-extra\nkeep\n# END fmr:lint-extra\n# BEGIN fmr:lint\nold\n# END fmr:lint\n",
+			want:    "# BEGIN fmr:lint-extra\nkeep\n# END fmr:lint-extra\n" + string(block),
+		},
+		{
+			name:    "missing section is appended",
+			current: "build:\n\tgo build",
+			insert:  true,
+			want:    "build:\n\tgo build\n" + string(block),
+		},
+		{
+			name:   "missing file gets the section",
+			insert: true,
+			want:   string(block),
+		},
+		{
+			name:        "missing section without insert",
+			current:     "build:\n",
+			errContains: `section "lint" not found in target`,
+		},
+		{
+			name:        "unterminated section",
+			current:     "# BEGIN fmr:lint\nold\n",
+			insert:      true,
+			errContains: "BEGIN marker without END marker",
+		},
+		{
+			name:        "END before BEGIN",
+			current:     "# END fmr:lint\n# BEGIN fmr:lint\n",
+			errContains: "END marker without BEGIN marker",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := spliceSection([]byte(tt.current), block, "lint", tt.insert)
+			if tt.errContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+					t.Errorf("spliceSection() error = %v, want %q", err, tt.errContains)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("spliceSection() failed: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("spliceSection() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExtractSection(t *testing.T) {
+	got, err := extractSection([]byte("head\n# BEGIN fmr:lint\nx\n# END fmr:lint"), "lint")
+	if err != nil || string(got) != "# BEGIN fmr:lint\nx\n# END fmr:lint\n" {
+		t.Errorf("extractSection() = %q, %v", got, err)
+	}
+	if _, err := extractSection([]byte("head\n"), "lint"); err == nil || !strings.Contains(err.Error(), `section "lint" not found`) {
+		t.Errorf("Expected a missing section error, got %v", err)
+	}
+}
+
+func TestRunSyncSection(t *testing.T) {
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"Makefile":   "all: lint\n# BEGIN fmr:shared-lint\nlint:\n\tgolangci-lint run\n# END fmr:shared-lint\n",
+		"a/Makefile": "build:\n\tgo build\n# BEGIN fmr:shared-lint\nlint:\n\tgo vet\n# END fmr:shared-lint\ntest:\n\tgo test\n",
+		"b/Makefile": "build:\n\tmake -C src\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(tmpDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	args := []string{"-p", tmpDir, "-s", "Makefile", "-t", "*/Makefile", "--section", "shared-lint"}
+	section := "# BEGIN fmr:shared-lint\nlint:\n\tgolangci-lint run\n# END fmr:shared-lint\n"
+
+	// Without --insert-missing, b fails and a is still synced
+	var stdout, stderr bytes.Buffer
+	if code := runSync(args, &stdout, &stderr); code != 1 {
+		t.Errorf("Exit code = %d, want 1\n%s", code, stdout.String())
+	}
+	if !strings.Contains(stdout.String(), `section "shared-lint" not found in target`) {
+		t.Errorf("Expected the missing section error, got:\n%s", stdout.String())
+	}
+	if content, _ := os.ReadFile(filepath.Join(tmpDir, "a", "Makefile")); string(content) != "build:\n\tgo build\n"+section+"test:\n\tgo test\n" {
+		t.Errorf("a/Makefile = %q", content)
+	}
+
+	stdout.Reset()
+	if code := runSync(append(args, "--insert-missing"), &stdout, &stderr); code != 0 {
+		t.Fatalf("Exit code = %d\nstdout: %s\nstderr: %s", code, stdout.String(), stderr.String())
+	}
+	if content, _ := os.ReadFile(filepath.Join(tmpDir, "b", "Makefile")); string(content) != "build:\n\tmake -C src\n"+section {
+		t.Errorf("b/Makefile = %q", content)
+	}
+
+	// The rest of each target differs from the source, yet the sections are in sync
+	stdout.Reset()
+	if code := runCheck([]string{"-p", tmpDir, "-s", "Makefile", "-t", "*/Makefile", "--section", "shared-lint"}, &stdout, &stderr); code != checkExitInSync {
+		t.Errorf("check exit code = %d\n%s", code, stdout.String())
+	}
+}
+
+func TestSectionPreview(t *testing.T) {
+	tmpDir := t.TempDir()
+	source := "all: lint\n# BEGIN fmr:shared-lint\nlint:\n\tgolangci-lint run\n# END fmr:shared-lint\n"
+	target := "build:\n\tgo build\n# BEGIN fmr:shared-lint\nlint:\n\tgo vet\n# END fmr:shared-lint\n"
+	if err := os.WriteFile(filepath.Join(tmpDir, "source.mk"), []byte(source), 0o644); err != nil {
+		t.Fatalf("Failed to write source: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "target.mk"), []byte(target), 0o644); err != nil {
+		t.Fatalf("Failed to write target: %v", err)
+	}
+
+	m := InitialModel("", tmpDir)
+	m.width, m.height = 100, 24
+	m.section = "shared-lint"
+	m.files = []FileInfo{{Path: "source.mk"}, {Path: "target.mk"}}
+	m.filterFiles()
+	m.sourceFile = &m.filteredFiles[0]
+	m.previewMode = previewDiff
+	m.cursor = 1
+
+	m.refreshPreview()
+	view := m.renderPreview()
+	if !strings.Contains(view, "[section shared-lint]") || !strings.Contains(view, "golangci-lint run") {
+		t.Errorf("Expected the section diff, got:\n%s", view)
+	}
+	// Lines outside the section are not part of the diff
+	if strings.Contains(view, "go build") || strings.Contains(view, "all: lint") {
+		t.Errorf("Expected only the section in the diff, got:\n%s", view)
+	}
+}
@@ -0,0 +1,387 @@
+package filemirror
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+	"unicode/utf8"
+
+	"gopkg.in/yaml.v3"
+)
+
+// defaultValuesFile is the per-directory values file of templated groups
+const defaultValuesFile = ".fmr-values.yaml"
+
+// capturePattern matches a path capture such as {name} in a target pattern
+var capturePattern = regexp.MustCompile(`\{(\w+)\}`)
+
+// sourceTemplate renders the source for every target: as a text/template, and as a managed
+// section spliced into the target or as the values of keys merged into the target, if
+// section or keys are set. With merge, the result is merged three-way with the target's
+// local changes since the last sync. A target's template values are,
+// from lowest to highest precedence: the group's values, the path captures of the target
+// pattern it matched, and the values file in the target's directory.
+type sourceTemplate struct {
+	name       string            // source file name, for error messages
+	template   bool              // the source is a text/template
+	values     map[string]string // group values
+	patterns   []string          // absolute, slash-separated target patterns with captures
+	valuesFile string            // values file name looked up in each target's directory
+
+	section       string // managed section mirrored instead of the whole file, see spliceSection
+	insertSection bool   // append the section to targets without its markers
+
+	keys   []string // key paths mirrored instead of the whole file, see mergeKeys
+	format string   // format of the keyed file (default: from the source's extension)
+
+	merge     bool // merge with local changes to the target since the last sync, see merge3
+	force     bool // write merges with conflicts, conflict markers included
+	localBase bool // take merge bases only from this machine's merge syncs, never from git
+}
+
+// newSourceTemplate returns the template of a manifest group, or nil if the group
+// mirrors its source as is
+func newSourceTemplate(mf *Manifest, group MirrorGroup) *sourceTemplate {
+	if !group.Template && group.Section == "" && len(group.Keys) == 0 && !group.Merge {
+		return nil
+	}
+	t := &sourceTemplate{
+		name:          filepath.Base(group.Source),
+		template:      group.Template,
+		values:        group.Values,
+		valuesFile:    group.ValuesFile,
+		section:       group.Section,
+		insertSection: group.InsertMissing,
+		keys:          group.Keys,
+		format:        group.Format,
+		merge:         group.Merge,
+	}
+	if t.valuesFile == "" {
+		t.valuesFile = defaultValuesFile
+	}
+	for _, pattern := range group.Targets {
+		if capturePattern.MatchString(pattern) {
+			t.patterns = append(t.patterns, filepath.ToSlash(mf.absPath(pattern)))
+		}
+	}
+	return t
+}
+
+// newSectionTemplate returns a template that mirrors only the named section of a source
+func newSectionTemplate(source, section string, insert bool) *sourceTemplate {
+	return &sourceTemplate{name: filepath.Base(source), section: section, insertSection: insert}
+}
+
+// newKeysTemplate returns a template that mirrors only the given key paths of a source
+func newKeysTemplate(source string, keys []string, format string) *sourceTemplate {
+	return &sourceTemplate{name: filepath.Base(source), keys: keys, format: format}
+}
+
+// withMerge returns t with three-way merging enabled if merge is set, and with conflicts
+// written if force is set. A nil template becomes one that only merges.
+func (t *sourceTemplate) withMerge(merge, force bool) *sourceTemplate {
+	if t == nil && !merge {
+		return nil
+	}
+	merged := &sourceTemplate{}
+	if t != nil {
+		*merged = *t
+	}
+	merged.merge = merged.merge || merge
+	merged.force = force
+	return merged
+}
+
+// withLocalBase returns a copy of the template whose merges never run git: their base is the
+// one recorded by the last merge sync on this machine, never the lockfile's source commit
+func (t *sourceTemplate) withLocalBase() *sourceTemplate {
+	if t == nil {
+		return nil
+	}
+	local := *t
+	local.localBase = true
+	return &local
+}
+
+// render returns the source content rendered for target. A nil template returns content unchanged.
+// A merge that leaves conflicts returns the content with conflict markers and a
+// *mergeConflictError, unless force is set.
+func (t *sourceTemplate) render(content []byte, target string) ([]byte, error) {
+	return t.renderOver(content, target, target)
+}
+
+// renderOver renders the source for target like render, but splices a managed section into
+// and merges with the file at current, such as the target's copy in a worktree
+func (t *sourceTemplate) renderOver(content []byte, target, current string) ([]byte, error) {
+	rendered, err := t.renderSource(content, target, current)
+	if err != nil {
+		return nil, err
+	}
+	return t.mergeBase(rendered, target, current)
+}
+
+// renderSource renders the source for target like renderOver, without merging
+func (t *sourceTemplate) renderSource(content []byte, target, current string) ([]byte, error) {
+	if t == nil {
+		return content, nil
+	}
+
+	if t.template {
+		values, err := t.targetValues(target)
+		if err != nil {
+			return nil, err
+		}
+		tmpl, err := template.New(t.name).Option("missingkey=error").Parse(string(content))
+		if err != nil {
+			return nil, fmt.Errorf("invalid template: %w", err)
+		}
+		var b bytes.Buffer
+		if err := tmpl.Execute(&b, values); err != nil {
+			return nil, fmt.Errorf("template: %w", err)
+		}
+		content = b.Bytes()
+	}
+	if t.section == "" && len(t.keys) == 0 {
+		return content, nil
+	}
+
+	existing, err := os.ReadFile(current)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read target: %w", err)
+	}
+	if len(t.keys) > 0 {
+		format, err := structuredFormatFor(t.name, t.format)
+		if err != nil {
+			return nil, err
+		}
+		return mergeKeys(format, content, existing, t.keys)
+	}
+
+	block, err := extractSection(content, t.section)
+	if err != nil {
+		return nil, fmt.Errorf("source %s: %w", t.name, err)
+	}
+	return spliceSection(existing, block, t.section, t.insertSection)
+}
+
+// mergeBase merges the changes from the base last synced into target to rendered into
+// the file at current, or returns rendered unchanged unless merge is set and current exists.
+// Without a base, a changed target is only overwritten with force; otherwise rendered is
+// returned with errNoMergeBase.
+func (t *sourceTemplate) mergeBase(rendered []byte, target, current string) ([]byte, error) {
+	if t == nil || !t.merge {
+		return rendered, nil
+	}
+	existing, err := os.ReadFile(current)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return rendered, nil
+	case err != nil:
+		return nil, fmt.Errorf("failed to read target: %w", err)
+	case bytes.Equal(existing, rendered):
+		return rendered, nil
+	}
+
+	base, err := t.syncedBase(target, current)
+	switch {
+	case err != nil:
+		return nil, err
+	case base == nil && t.force:
+		return rendered, nil
+	case base == nil:
+		return rendered, errNoMergeBase
+	}
+
+	merged, conflicts := merge3(string(base), string(existing), string(rendered))
+	if conflicts > 0 && !t.force {
+		return []byte(merged), &mergeConflictError{Conflicts: conflicts}
+	}
+	return []byte(merged), nil
+}
+
+// syncedBase returns the source as last synced into target, rendered for it: preferably the
+// source at the commit recorded in the lockfile of target's repository, else the base recorded
+// by the last merge sync on this machine, the only one looked at with localBase. Returns nil
+// if there is neither.
+func (t *sourceTemplate) syncedBase(target, current string) ([]byte, error) {
+	if !t.localBase {
+		source, found, err := lockedSource(target)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			if base, err := t.renderSource(source, target, current); err == nil {
+				return base, nil
+			}
+		}
+	}
+	base, found, err := loadBase(target)
+	if err != nil || !found {
+		return nil, err
+	}
+	return base, nil
+}
+
+// keyDiff returns the key-level changes rendering makes to current, or nil unless keys are set
+func (t *sourceTemplate) keyDiff(rendered, current []byte) ([]string, error) {
+	if t == nil || len(t.keys) == 0 {
+		return nil, nil
+	}
+	format, err := structuredFormatFor(t.name, t.format)
+	if err != nil {
+		return nil, err
+	}
+	return keyDiff(format, rendered, current, t.keys)
+}
+
+// managedRegion returns the part of content that the template manages: the section, if
+// set and present, or all of content
+func (t *sourceTemplate) managedRegion(content []byte) []byte {
+	if t == nil || t.section == "" {
+		return content
+	}
+	start, end, found, err := findSection(content, t.section)
+	if err != nil || !found {
+		return nil
+	}
+	return content[start:end]
+}
+
+// targetValues collects the values for target
+func (t *sourceTemplate) targetValues(target string) (map[string]string, error) {
+	values := make(map[string]string, len(t.values))
+	for key, value := range t.values {
+		values[key] = value
+	}
+
+	for _, pattern := range t.patterns {
+		if captures := capturePath(pattern, filepath.ToSlash(target)); captures != nil {
+			for key, value := range captures {
+				values[key] = value
+			}
+			break
+		}
+	}
+
+	valuesPath := filepath.Join(filepath.Dir(target), t.valuesFile)
+	data, err := os.ReadFile(valuesPath)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return values, nil
+	case err != nil:
+		return nil, fmt.Errorf("failed to read values file: %w", err)
+	}
+	var fileValues map[string]string
+	if err := yaml.Unmarshal(data, &fileValues); err != nil {
+		return nil, fmt.Errorf("invalid values file %s: %w", valuesPath, err)
+	}
+	for key, value := range fileValues {
+		values[key] = value
+	}
+	return values, nil
+}
+
+// capturePath matches a slash-separated path against a target pattern and returns the
+// values of its captures, or nil if it does not match. A capture matches part of one path
+// segment; "*", "?" and "**" match like in matchPathPattern.
+func capturePath(pattern, name string) map[string]string {
+	var expr strings.Builder
+	expr.WriteString("^")
+	var keys []string
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			expr.WriteString("(?:[^/]+/)*")
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			expr.WriteString(".*")
+			i++
+		case c == '*':
+			expr.WriteString("[^/]*")
+		case c == '?':
+			expr.WriteString("[^/]")
+		case c == '{':
+			loc := capturePattern.FindStringSubmatchIndex(pattern[i:])
+			if loc == nil || loc[0] != 0 {
+				expr.WriteString(regexp.QuoteMeta("{"))
+				continue
+			}
+			keys = append(keys, pattern[i+loc[2]:i+loc[3]])
+			expr.WriteString("([^/]+)")
+			i += loc[1] - 1
+		default:
+			r, size := utf8.DecodeRuneInString(pattern[i:])
+			expr.WriteString(regexp.QuoteMeta(string(r)))
+			i += size - 1
+		}
+	}
+	expr.WriteString("$")
+
+	re, err := regexp.Compile(expr.String())
+	if err != nil {
+		return nil
+	}
+	match := re.FindStringSubmatch(name)
+	if match == nil {
+		return nil
+	}
+	captures := make(map[string]string, len(keys))
+	for i, key := range keys {
+		captures[key] = match[i+1]
+	}
+	return captures
+}
+
+// mirrorTarget writes the source over target, rendered for target if tmpl is set, and
+// records the original target in j, if set, before overwriting it. For merge syncs, the
+// rendered source is recorded as the base of the next merge into target.
+func mirrorTarget(source string, content []byte, target string, tmpl *sourceTemplate, j *journal) error {
+	rendered, err := tmpl.renderSource(content, target, target)
+	if err != nil {
+		return err
+	}
+	merged, err := tmpl.mergeBase(rendered, target, target)
+	if err != nil {
+		return err
+	}
+	if err := j.backup(target, source); err != nil {
+		return err
+	}
+
+	if tmpl == nil {
+		err = copyFile(source, target)
+	} else {
+		mode := os.FileMode(0o644)
+		if info, statErr := os.Stat(source); statErr == nil {
+			mode = info.Mode().Perm()
+		}
+		err = writeFileAtomic(target, merged, tmpl.fileMode(target, mode))
+	}
+	if err != nil {
+		return err
+	}
+	if err := j.written(target, merged); err != nil {
+		return err
+	}
+	if tmpl == nil || !tmpl.merge {
+		return nil
+	}
+	return recordBase(target, rendered)
+}
+
+// fileMode returns the mode to write target with: its current mode if a section or keys are
+// merged into it, as the rest of the file stays the target's own, or mode otherwise
+func (t *sourceTemplate) fileMode(target string, mode os.FileMode) os.FileMode {
+	if t == nil || t.section == "" && len(t.keys) == 0 {
+		return mode
+	}
+	if info, err := os.Stat(target); err == nil {
+		return info.Mode().Perm()
+	}
+	return mode
+}
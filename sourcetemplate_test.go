@@ -0,0 +1,185 @@
+package filemirror
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCapturePath(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    map[string]string
+	}{
+		{"/r/services/{name}/config.yaml", "/r/services/api/config.yaml", map[string]string{"name": "api"}},
+		{"/r/services/{name}/config.yaml", "/r/services/api/v2/config.yaml", nil},
+		{"/r/{team}/svc-{name}.yaml", "/r/core/svc-billing.yaml", map[string]string{"team": "core", "name": "billing"}},
+		{"/r/**/{name}/Dockerfile", "/r/apps/web/Dockerfile", map[string]string{"name": "web"}},
+		{"/r/*/{env}.env", "/r/api/prod.env", map[string]string{"env": "prod"}},
+		{"/r/ünï/{name}.txt", "/r/ünï/a.txt", map[string]string{"name": "a"}},
+		{"/r/{name}/config.yaml", "/r/config.yaml", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+"~"+tt.name, func(t *testing.T) {
+			got := capturePath(tt.pattern, tt.name)
+			if len(got) != len(tt.want) || (got == nil) != (tt.want == nil) {
+				t.Fatalf("capturePath() = %v, want %v", got, tt.want)
+			}
+			for key, value := range tt.want {
+				if got[key] != value {
+					t.Errorf("capture %s = %q, want %q", key, got[key], value)
+				}
+			}
+		})
+	}
+}
+
+func TestSourceTemplateRender(t *testing.T) {
+	dir := t.TempDir()
+	for _, svc := range []string{"api", "web"} {
+		if err := os.MkdirAll(filepath.Join(dir, "services", svc), 0o755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+	}
+	// web overrides the port in its values file
+	if err := os.WriteFile(filepath.Join(dir, "services", "web", defaultValuesFile), []byte("port: \"8081\"\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write values file: %v", err)
+	}
+
+	mf := &Manifest{Dir: dir}
+	group := MirrorGroup{
+		Source:   "canonical/config.yaml",
+		Targets:  []string{"services/{name}/config.yaml"},
+		Template: true,
+		Values:   map[string]string{"port": "8080", "name": "default"},
+	}
+	tmpl := newSourceTemplate(mf, group)
+	source := []byte("service: {{.name}}\nport: {{.port}}\n")
+
+	tests := []struct {
+		name        string
+		target      string
+		source      string
+		want        string
+		errContains string
+	}{
+		{name: "captures override group values", target: "services/api/config.yaml", want: "service: api\nport: 8080\n"},
+		{name: "values file overrides the rest", target: "services/web/config.yaml", want: "service: web\nport: 8081\n"},
+		{name: "target outside the pattern", target: "other/config.yaml", want: "service: default\nport: 8080\n"},
+		{name: "missing value", target: "services/api/config.yaml", source: "{{.host}}", errContains: `map has no entry for key "host"`},
+		{name: "invalid template", target: "services/api/config.yaml", source: "{{.port", errContains: "invalid template"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := source
+			if tt.source != "" {
+				content = []byte(tt.source)
+			}
+			got, err := tmpl.render(content, filepath.Join(dir, filepath.FromSlash(tt.target)))
+			if tt.errContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+					t.Errorf("render() error = %v, want %q", err, tt.errContains)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("render() failed: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("render() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// Plain groups are mirrored as is
+	var plain *sourceTemplate
+	if got, err := plain.render(source, "any"); err != nil || !bytes.Equal(got, source) {
+		t.Errorf("nil render() = %q, %v", got, err)
+	}
+}
+
+func TestRunSyncTemplatedManifest(t *testing.T) {
+	tmpDir := writeManifestTree(t, `groups:
+  - name: config
+    source: canonical/config.yaml
+    targets: ["services/{name}/config.yaml"]
+    exclude: ["services/legacy/*"]
+    template: true
+    values: {port: "8080"}
+`)
+	if err := os.WriteFile(filepath.Join(tmpDir, "canonical", "config.yaml"), []byte("name: {{.name}}\nport: {{.port}}\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write source: %v", err)
+	}
+
+	for _, args := range [][]string{{"-p", tmpDir}, {"-p", tmpDir, "--transactional"}} {
+		var stdout, stderr bytes.Buffer
+		if code := runSync(args, &stdout, &stderr); code != 0 {
+			t.Fatalf("runSync(%v) exit code = %d\nstdout: %s\nstderr: %s", args, code, stdout.String(), stderr.String())
+		}
+		for _, svc := range []string{"api", "web"} {
+			content, _ := os.ReadFile(filepath.Join(tmpDir, "services", svc, "config.yaml"))
+			if want := "name: " + svc + "\nport: 8080\n"; string(content) != want {
+				t.Errorf("%v: services/%s = %q, want %q", args, svc, content, want)
+			}
+		}
+	}
+
+	// The replicas are in sync with the rendered source
+	var stdout, stderr bytes.Buffer
+	if code := runCheck([]string{"-p", tmpDir}, &stdout, &stderr); code != checkExitInSync {
+		t.Errorf("check exit code = %d\n%s", code, stdout.String())
+	}
+
+	// A value missing for a target fails that target
+	if err := os.WriteFile(filepath.Join(tmpDir, "canonical", "config.yaml"), []byte("{{.host}}\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write source: %v", err)
+	}
+	stdout.Reset()
+	if code := runSync([]string{"-p", tmpDir, "--dry-run"}, &stdout, &stderr); code != 1 || !strings.Contains(stdout.String(), `no entry for key "host"`) {
+		t.Errorf("Exit code = %d, want 1 with the missing value\n%s", code, stdout.String())
+	}
+}
+
+func TestTemplatedPreviewAndCopy(t *testing.T) {
+	tmpDir := writeManifestTree(t, `groups:
+  - source: canonical/config.yaml
+    targets: ["services/{name}/config.yaml"]
+    template: true
+`)
+	if err := os.WriteFile(filepath.Join(tmpDir, "canonical", "config.yaml"), []byte("name: {{.name}}\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write source: %v", err)
+	}
+	manifest, err := loadManifest(filepath.Join(tmpDir, manifestFileName))
+	if err != nil {
+		t.Fatalf("loadManifest failed: %v", err)
+	}
+
+	m := InitialModel("", tmpDir)
+	m.width, m.height = 100, 24
+	m.manifest = manifest
+	m.activeGroup = &manifest.Groups[0]
+	m.files = []FileInfo{{Path: "canonical/config.yaml"}, {Path: "services/api/config.yaml"}}
+	m.filterFiles()
+	m.sourceFile = &m.filteredFiles[0]
+	m.previewMode = previewDiff
+	m.cursor = 1
+
+	m.refreshPreview()
+	view := m.renderPreview()
+	if !strings.Contains(view, "+content of services/api/config.yaml") || !strings.Contains(view, "-name: api") {
+		t.Errorf("Expected the diff against the rendered source, got:\n%s", view)
+	}
+
+	m.toggleSelected(m.filteredFiles[1])
+	if m, _ = driveSync(t, m); m.err != nil {
+		t.Fatalf("sync failed: %v", m.err)
+	}
+	if content, _ := os.ReadFile(filepath.Join(tmpDir, "services", "api", "config.yaml")); string(content) != "name: api\n" {
+		t.Errorf("target = %q, want the rendered source", content)
+	}
+}
@@ -0,0 +1,279 @@
+package filemirror
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Exit codes of the status command
+const (
+	statusExitCurrent = 0 // every replica matches the source it was synced from
+	statusExitBehind  = 1 // at least one replica is behind its source or changed locally
+	statusExitError   = 2 // invalid arguments, or a lockfile, source or replica could not be read
+)
+
+// replicaState is the state of a mirrored file compared to its lockfile entry
+type replicaState int
+
+const (
+	replicaCurrent  replicaState = iota
+	replicaBehind                // the source changed since the sync
+	replicaModified              // the replica changed since the sync
+	replicaMissing               // the replica was removed
+	replicaUnknown               // the source or replica could not be read
+)
+
+func (s replicaState) String() string {
+	switch s {
+	case replicaCurrent:
+		return "current"
+	case replicaBehind:
+		return "behind"
+	case replicaModified:
+		return "modified"
+	case replicaMissing:
+		return "missing"
+	default:
+		return "unknown"
+	}
+}
+
+// replicaStatus is the state of one mirrored file recorded in a lockfile
+type replicaStatus struct {
+	Path    string // absolute path of the replica
+	Source  string // absolute path of its source
+	Entry   lockEntry
+	State   replicaState
+	Commits int   // commits to the source since the sync, or -1 if unknown
+	Err     error // set for replicaUnknown
+}
+
+// replicaStatusOf compares the replica recorded in entry of the lockfile in repoRoot with
+// the file and its source as they are now
+func replicaStatusOf(repoRoot string, entry lockEntry) replicaStatus {
+	sourceRoot := filepath.Join(repoRoot, filepath.FromSlash(entry.SourceRepo))
+	st := replicaStatus{
+		Path:    filepath.Join(repoRoot, filepath.FromSlash(entry.Path)),
+		Source:  filepath.Join(sourceRoot, filepath.FromSlash(entry.SourcePath)),
+		Entry:   entry,
+		Commits: -1,
+	}
+
+	source, err := os.ReadFile(st.Source)
+	if err != nil {
+		st.State, st.Err = replicaUnknown, fmt.Errorf("cannot read source: %w", err)
+		return st
+	}
+	replica, err := os.ReadFile(st.Path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		st.State = replicaMissing
+		return st
+	case err != nil:
+		st.State, st.Err = replicaUnknown, err
+		return st
+	}
+
+	switch {
+	case hashBytes(source) != entry.SourceHash:
+		st.State = replicaBehind
+		st.Commits = commitsSince(sourceRoot, entry.SourceCommit, entry.SourcePath)
+	case hashBytes(replica) != entry.Hash:
+		st.State = replicaModified
+	default:
+		st.State = replicaCurrent
+	}
+	return st
+}
+
+// commitsSince counts the commits to path in the repository at root since commit,
+// or returns -1 if they cannot be counted
+func commitsSince(root, commit, path string) int {
+	if commit == "" {
+		return -1
+	}
+	output, err := exec.Command("git", "-C", root, "rev-list", "--count", commit+"..HEAD", "--", path).Output()
+	if err != nil {
+		return -1
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(string(output)))
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+// findLockFiles returns the lockfiles of the git repository containing dir and of the
+// repositories below it, up to the default scan depth, in sorted order
+func findLockFiles(dir string) ([]string, error) {
+	seen := make(map[string]bool)
+	var found []string
+	add := func(path string) {
+		if !seen[path] {
+			seen[path] = true
+			found = append(found, path)
+		}
+	}
+
+	if root, err := detectGitRoot(filepath.Join(dir, lockFileName)); err == nil {
+		if _, err := os.Stat(filepath.Join(root, lockFileName)); err == nil {
+			add(filepath.Join(root, lockFileName))
+		}
+	}
+
+	opts := defaultScanOptions()
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		if d.IsDir() {
+			if rel != "." && (opts.skipDir(d.Name(), rel) || strings.Count(rel, string(filepath.Separator)) >= opts.MaxDepth) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Name() == lockFileName {
+			add(path)
+		}
+		return nil
+	})
+	sort.Strings(found)
+	return found, err
+}
+
+// runStatus runs the status command and returns an exit code
+func runStatus(args []string, stdout, stderr io.Writer) int {
+	dir := "."
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; arg {
+		case "-h", "--help":
+			printStatusHelp(stdout)
+			return statusExitCurrent
+		case "-p", "--path":
+			if i+1 >= len(args) {
+				_, _ = fmt.Fprintln(stderr, "Error: --path requires a directory argument") //nolint:errcheck // Error writing to stderr is not actionable
+				return statusExitError
+			}
+			dir = args[i+1]
+			i++
+		default:
+			_, _ = fmt.Fprintf(stderr, "Error: unknown argument %q\n", arg)   //nolint:errcheck // Error writing to stderr is not actionable
+			_, _ = fmt.Fprintln(stderr, "Run 'fmr status --help' for usage.") //nolint:errcheck // Error writing to stderr is not actionable
+			return statusExitError
+		}
+	}
+
+	workDir, err := filepath.Abs(dir)
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "Error: invalid path %q: %v\n", dir, err) //nolint:errcheck // Error writing to stderr is not actionable
+		return statusExitError
+	}
+	lockFiles, err := findLockFiles(workDir)
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "Error: %v\n", err) //nolint:errcheck // Error writing to stderr is not actionable
+		return statusExitError
+	}
+
+	w := func(format string, a ...any) {
+		_, _ = fmt.Fprintf(stdout, format, a...) //nolint:errcheck // Error writing to stdout is not actionable
+	}
+
+	if len(lockFiles) == 0 {
+		w("No %s found in %s\n", lockFileName, workDir)
+		return statusExitCurrent
+	}
+
+	counts := make(map[replicaState]int)
+	for i, path := range lockFiles {
+		if i > 0 {
+			w("\n")
+		}
+		w("== %s ==\n", relativeTo(workDir, path))
+		lock, err := readLockFile(path)
+		if err != nil {
+			w("✗ %v\n", err)
+			counts[replicaUnknown]++
+			continue
+		}
+
+		for _, entry := range lock.Files {
+			st := replicaStatusOf(filepath.Dir(path), entry)
+			counts[st.State]++
+
+			line := fmt.Sprintf("%-8s  %s ← %s", st.State, relativeTo(workDir, st.Path), relativeTo(workDir, st.Source))
+			if commit := st.Entry.SourceCommit; commit != "" {
+				line += "@" + commit[:minInt(len(commit), 7)]
+			}
+			switch {
+			case st.Err != nil:
+				line += fmt.Sprintf(": %v", st.Err)
+			case st.State == replicaBehind && st.Commits > 0:
+				line += fmt.Sprintf(" (%d commit(s) since)", st.Commits)
+			}
+			w("%s\n", line)
+		}
+	}
+
+	w("\n%d current, %d behind, %d modified, %d missing, %d unknown\n",
+		counts[replicaCurrent], counts[replicaBehind], counts[replicaModified], counts[replicaMissing], counts[replicaUnknown])
+
+	switch {
+	case counts[replicaUnknown] > 0:
+		return statusExitError
+	case counts[replicaBehind]+counts[replicaModified]+counts[replicaMissing] > 0:
+		return statusExitBehind
+	}
+	return statusExitCurrent
+}
+
+// printStatusHelp displays the help message for the status command
+func printStatusHelp(w io.Writer) {
+	help := `fmr status - Report mirrored files that are behind their source
+
+USAGE:
+    fmr status [OPTIONS]
+
+DESCRIPTION:
+    The git workflow records in a .fmr.lock file in the root of each target
+    repository where the mirrored files came from: the source repository and
+    path, the source commit and the hashes of the source and the written file.
+    The lockfile is committed along with the files on the sync branch and is
+    never written into the working tree directly.
+
+    Only syncs with the git workflow (--git, --branch, --git-only, ...) record
+    provenance. A file last written by a sync without git is reported as
+    modified if it has a lockfile entry from an earlier git sync, and not at
+    all otherwise.
+
+    Status reads the lockfiles of the repository containing PATH and of the
+    repositories below it, and reports each mirrored file as:
+        current    the source and the file are unchanged since the sync
+        behind     the source changed since the sync
+        modified   the file was changed after the sync
+        missing    the file was removed
+        unknown    the source or the file could not be read
+
+OPTIONS:
+    -p, --path PATH   Directory to look for lockfiles in (default: current directory)
+    -h, --help        Show this help message
+
+EXIT STATUS:
+    0  Every mirrored file is current
+    1  At least one file is behind, modified or missing
+    2  Invalid arguments, or a lockfile, source or file could not be read
+
+EXAMPLES:
+    fmr status
+    fmr status -p ~/src
+`
+	_, _ = fmt.Fprint(w, help) //nolint:errcheck // Error writing to writer is not actionable
+}
@@ -0,0 +1,80 @@
+package filemirror
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunStatus(t *testing.T) {
+	root := t.TempDir()
+	sourceRepo := filepath.Join(root, "canonical")
+	if err := os.Rename(createTestGitRepo(t), sourceRepo); err != nil {
+		t.Fatalf("Failed to move repo: %v", err)
+	}
+	targetRepo := filepath.Join(root, "service")
+	if err := os.Rename(createTestGitRepo(t), targetRepo); err != nil {
+		t.Fatalf("Failed to move repo: %v", err)
+	}
+	commitFiles(t, sourceRepo, map[string]string{"Makefile": "lint:\n", "LICENSE": "MIT\n", ".editorconfig": "root = true\n"})
+
+	// The lockfile is committed on the sync branch and reaches the checkout when it is merged
+	var stdout, stderr bytes.Buffer
+	for i, name := range []string{"Makefile", "LICENSE", ".editorconfig"} {
+		branch := fmt.Sprintf("chore/sync-%d", i)
+		args := []string{"-s", filepath.Join(sourceRepo, name), "-t", filepath.Join(targetRepo, name), "--git-only", "--branch", branch}
+		if code := runSync(args, &stdout, &stderr); code != 0 {
+			t.Fatalf("sync exit code = %d\nstdout: %s\nstderr: %s", code, stdout.String(), stderr.String())
+		}
+		if output, err := exec.Command("git", "-C", targetRepo, "merge", "-q", "--ff-only", branch).CombinedOutput(); err != nil {
+			t.Fatalf("git merge failed: %v\n%s", err, output)
+		}
+	}
+
+	status := func(want int) string {
+		t.Helper()
+		var stdout, stderr bytes.Buffer
+		if code := runStatus([]string{"-p", root}, &stdout, &stderr); code != want {
+			t.Fatalf("status exit code = %d, want %d\nstdout: %s\nstderr: %s", code, want, stdout.String(), stderr.String())
+		}
+		return stdout.String()
+	}
+	if out := status(statusExitCurrent); !strings.Contains(out, "3 current, 0 behind") {
+		t.Errorf("Expected every file to be current, got:\n%s", out)
+	}
+
+	commitFiles(t, sourceRepo, map[string]string{"Makefile": "lint:\n\tgolangci-lint run\n"})
+	if err := os.WriteFile(filepath.Join(targetRepo, "LICENSE"), []byte("Apache-2.0\n"), 0o644); err != nil {
+		t.Fatalf("Failed to edit replica: %v", err)
+	}
+	if err := os.Remove(filepath.Join(targetRepo, ".editorconfig")); err != nil {
+		t.Fatalf("Failed to remove replica: %v", err)
+	}
+
+	out := status(statusExitBehind)
+	for _, want := range []string{
+		"behind    service/Makefile ← canonical/Makefile@",
+		"(1 commit(s) since)",
+		"modified  service/LICENSE",
+		"missing   service/.editorconfig",
+		"0 current, 1 behind, 1 modified, 1 missing, 0 unknown",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %q in:\n%s", want, out)
+		}
+	}
+}
+
+func TestRunStatusWithoutLockFiles(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := RunWithArgs([]string{"status", "-p", t.TempDir()}, &stdout, &stderr); code != statusExitCurrent {
+		t.Errorf("Exit code = %d, want %d", code, statusExitCurrent)
+	}
+	if !strings.Contains(stdout.String(), "No .fmr.lock found") {
+		t.Errorf("Expected no lockfiles, got %q", stdout.String())
+	}
+}
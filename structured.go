@@ -0,0 +1,261 @@
+package filemirror
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// structuredFormat merges key paths of one file format as text, so that everything outside
+// the merged keys keeps its formatting, comments and order
+type structuredFormat interface {
+	// merge replaces the value at path in target with its value in source, inserting it
+	// and any missing parents if target lacks it
+	merge(source, target []byte, path []string) ([]byte, error)
+	// leaves returns the scalar and array values of content by dotted key path
+	leaves(content []byte) (map[string]string, error)
+}
+
+// structuredFormats are the formats supported by structured sync, by name
+var structuredFormats = map[string]structuredFormat{
+	"json": jsonFormat{},
+	"yaml": yamlFormat{},
+	"toml": tomlFormat{},
+}
+
+// structuredFormatFor returns the named format, or the format of path's extension if name is empty
+func structuredFormatFor(path, name string) (structuredFormat, error) {
+	if name == "" {
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".json":
+			name = "json"
+		case ".yaml", ".yml":
+			name = "yaml"
+		case ".toml":
+			name = "toml"
+		default:
+			return nil, fmt.Errorf("cannot tell the format of %s from its extension: set the format to json, yaml or toml", filepath.Base(path))
+		}
+	}
+	format, ok := structuredFormats[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown format %q: use json, yaml or toml", name)
+	}
+	return format, nil
+}
+
+// validateKeyPath checks a dotted key path such as "compilerOptions.paths"
+func validateKeyPath(key string) error {
+	for _, part := range strings.Split(key, ".") {
+		if strings.TrimSpace(part) == "" {
+			return fmt.Errorf("invalid key path %q", key)
+		}
+	}
+	return nil
+}
+
+// validateKeys checks the key paths and format of a structured sync of source
+func validateKeys(source string, keys []string, format, section string) error {
+	if len(keys) == 0 {
+		if format != "" {
+			return errors.New("format requires keys")
+		}
+		return nil
+	}
+	if section != "" {
+		return errors.New("keys cannot be combined with section")
+	}
+	for _, key := range keys {
+		if err := validateKeyPath(key); err != nil {
+			return err
+		}
+	}
+	_, err := structuredFormatFor(source, format)
+	return err
+}
+
+// validateKeyFlags checks --key and --format, given with --source and --section
+func validateKeyFlags(source string, keys []string, format, section string) error {
+	switch {
+	case len(keys) == 0 && format != "":
+		return errors.New("--format requires --key")
+	case len(keys) > 0 && source == "":
+		return errors.New("--key requires --source (manifest groups set keys instead)")
+	case len(keys) > 0 && section != "":
+		return errors.New("--key cannot be combined with --section")
+	}
+	return validateKeys(source, keys, format, section)
+}
+
+// mergeKeys overwrites the given key paths of target with their values in source
+func mergeKeys(format structuredFormat, source, target []byte, keys []string) ([]byte, error) {
+	for _, key := range keys {
+		merged, err := format.merge(source, target, strings.Split(key, "."))
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", key, err)
+		}
+		target = merged
+	}
+	return target, nil
+}
+
+// keyDiff returns the key-level changes from current to merged within the given key paths,
+// one line each: "~ path: old → new", "+ path: new" or "- path: old"
+func keyDiff(format structuredFormat, merged, current []byte, keys []string) ([]string, error) {
+	newLeaves, err := format.leaves(merged)
+	if err != nil {
+		return nil, err
+	}
+	oldLeaves, err := format.leaves(current)
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make(map[string]bool)
+	for path := range newLeaves {
+		paths[path] = true
+	}
+	for path := range oldLeaves {
+		paths[path] = true
+	}
+	sorted := make([]string, 0, len(paths))
+	for path := range paths {
+		if underKeys(path, keys) {
+			sorted = append(sorted, path)
+		}
+	}
+	sort.Strings(sorted)
+
+	var lines []string
+	for _, path := range sorted {
+		newValue, inNew := newLeaves[path]
+		oldValue, inOld := oldLeaves[path]
+		switch {
+		case !inOld:
+			lines = append(lines, fmt.Sprintf("+ %s: %s", path, newValue))
+		case !inNew:
+			lines = append(lines, fmt.Sprintf("- %s: %s", path, oldValue))
+		case newValue != oldValue:
+			lines = append(lines, fmt.Sprintf("~ %s: %s → %s", path, oldValue, newValue))
+		}
+	}
+	return lines, nil
+}
+
+// underKeys reports whether a leaf path is one of keys or inside one of them
+func underKeys(path string, keys []string) bool {
+	for _, key := range keys {
+		if path == key || strings.HasPrefix(path, key+".") || strings.HasPrefix(path, key+"[") {
+			return true
+		}
+	}
+	return false
+}
+
+// flattenLeaves adds the leaves of a decoded JSON or YAML value to out, by dotted path
+func flattenLeaves(prefix string, value any, out map[string]string) {
+	join := func(key string) string {
+		if prefix == "" {
+			return key
+		}
+		return prefix + "." + key
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		if len(v) == 0 && prefix != "" {
+			out[prefix] = "{}"
+		}
+		for key, child := range v {
+			flattenLeaves(join(key), child, out)
+		}
+	case map[any]any:
+		if len(v) == 0 && prefix != "" {
+			out[prefix] = "{}"
+		}
+		for key, child := range v {
+			flattenLeaves(join(fmt.Sprint(key)), child, out)
+		}
+	default:
+		if prefix == "" {
+			return
+		}
+		data, err := json.Marshal(v)
+		if err != nil {
+			out[prefix] = fmt.Sprint(v)
+			return
+		}
+		out[prefix] = string(data)
+	}
+}
+
+// reindentLines moves lines from indentation from to indentation to, turning every further
+// level of fromStep into toStep. Blank lines and lines not starting with from are kept.
+func reindentLines(lines []string, from, to, fromStep, toStep string) []string {
+	out := make([]string, len(lines))
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "" || !strings.HasPrefix(line, from) {
+			out[i] = line
+			continue
+		}
+		rest := line[len(from):]
+		levels := 0
+		for fromStep != "" && strings.HasPrefix(rest, fromStep) {
+			rest = rest[len(fromStep):]
+			levels++
+		}
+		out[i] = to + strings.Repeat(toStep, levels) + rest
+	}
+	return out
+}
+
+// indentStep returns the indentation of the first indented line of content, the step
+// its nesting levels are indented by, or two spaces if no line is indented
+func indentStep(content []byte) string {
+	for _, line := range splitLines(content) {
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed != line && strings.TrimSpace(trimmed) != "" && !strings.HasPrefix(trimmed, "#") {
+			return line[:len(line)-len(trimmed)]
+		}
+	}
+	return "  "
+}
+
+// leadingSpaces counts the spaces a line starts with
+func leadingSpaces(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " "))
+}
+
+// splitLines splits content into lines that keep their line endings
+func splitLines(content []byte) []string {
+	lines := strings.SplitAfter(string(content), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// spliceLines replaces lines[start:end] with block, making sure the line before block ends
+// with a newline
+func spliceLines(lines []string, start, end int, block []string) []byte {
+	var b strings.Builder
+	for _, line := range lines[:start] {
+		b.WriteString(line)
+	}
+	if start > 0 && !strings.HasSuffix(lines[start-1], "\n") {
+		b.WriteString("\n")
+	}
+	for _, line := range block {
+		b.WriteString(line)
+	}
+	if end < len(lines) && len(block) > 0 && !strings.HasSuffix(block[len(block)-1], "\n") {
+		b.WriteString("\n")
+	}
+	for _, line := range lines[end:] {
+		b.WriteString(line)
+	}
+	return []byte(b.String())
+}
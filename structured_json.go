@@ -0,0 +1,267 @@
+package filemirror
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// jsonFormat merges JSON objects by splicing the text of the selected values
+type jsonFormat struct{}
+
+// jsonMember is one member of a JSON object, as offsets into the document
+type jsonMember struct {
+	key        string
+	start      int // offset of the key's opening quote
+	valueStart int
+	valueEnd   int
+}
+
+// jsonObject is a JSON object in a document: the offsets of its braces and its members
+type jsonObject struct {
+	open, close int
+	members     []jsonMember
+}
+
+// member returns the member named key
+func (o jsonObject) member(key string) (jsonMember, bool) {
+	for _, m := range o.members {
+		if m.key == key {
+			return m, true
+		}
+	}
+	return jsonMember{}, false
+}
+
+// merge implements structuredFormat
+func (jsonFormat) merge(source, target []byte, path []string) ([]byte, error) {
+	if len(bytes.TrimSpace(target)) == 0 {
+		target = []byte("{}\n")
+	}
+	if !json.Valid(source) {
+		return nil, errors.New("source is not valid JSON")
+	}
+	if !json.Valid(target) {
+		return nil, errors.New("target is not valid JSON")
+	}
+
+	// Find the members along path in the source
+	srcObj, err := parseJSONObject(source, skipJSONSpace(source, 0))
+	if err != nil {
+		return nil, fmt.Errorf("source: %w", err)
+	}
+	srcMembers := make([]jsonMember, len(path))
+	for i, part := range path {
+		m, ok := srcObj.member(part)
+		if !ok {
+			return nil, fmt.Errorf("%s not found in source", strings.Join(path[:i+1], "."))
+		}
+		srcMembers[i] = m
+		if i < len(path)-1 {
+			if srcObj, err = parseJSONObject(source, m.valueStart); err != nil {
+				return nil, fmt.Errorf("source %s: %w", strings.Join(path[:i+1], "."), err)
+			}
+		}
+	}
+
+	obj, err := parseJSONObject(target, skipJSONSpace(target, 0))
+	if err != nil {
+		return nil, fmt.Errorf("target: %w", err)
+	}
+	var merged []byte
+	for i, part := range path {
+		m, ok := obj.member(part)
+		if !ok {
+			text, indent := nestedJSONMember(source, srcMembers[i:])
+			merged = insertJSONMember(source, target, text, indent, obj)
+			break
+		}
+		if i == len(path)-1 {
+			src := srcMembers[i]
+			value := reindentJSON(source, target, source[src.valueStart:src.valueEnd], lineIndent(source, src.start), lineIndent(target, m.start))
+			merged = append(append(append([]byte(nil), target[:m.valueStart]...), value...), target[m.valueEnd:]...)
+			break
+		}
+		if obj, err = parseJSONObject(target, m.valueStart); err != nil {
+			return nil, fmt.Errorf("target %s: %w", strings.Join(path[:i+1], "."), err)
+		}
+	}
+
+	if !json.Valid(merged) {
+		return nil, errors.New("merged JSON is invalid")
+	}
+	return merged, nil
+}
+
+// nestedJSONMember returns the text of the first of the source members along a key path,
+// holding only the last member, and the indentation of its line in the source. Members
+// the selected key is nested in are written as objects with that one member.
+func nestedJSONMember(source []byte, members []jsonMember) ([]byte, string) {
+	first, leaf := members[0], members[len(members)-1]
+	base := lineIndent(source, first.start)
+	if len(members) == 1 {
+		return source[first.start:first.valueEnd], base
+	}
+
+	step := indentStep(source)
+	depth := len(members) - 1
+	var b bytes.Buffer
+	for d, m := range members[:depth] {
+		if d > 0 {
+			b.WriteString(base + strings.Repeat(step, d))
+		}
+		b.Write(source[m.start:skipJSONValue(source, m.start)])
+		b.WriteString(": {\n")
+	}
+	b.WriteString(base + strings.Repeat(step, depth))
+	b.Write(reindentJSON(source, source, source[leaf.start:leaf.valueEnd], lineIndent(source, leaf.start), base+strings.Repeat(step, depth)))
+	for d := depth - 1; d >= 0; d-- {
+		b.WriteString("\n" + base + strings.Repeat(step, d) + "}")
+	}
+	return b.Bytes(), base
+}
+
+// insertJSONMember adds the text of a source member, whose line is indented by srcIndent in
+// the source, to the target object obj, indented like its siblings
+func insertJSONMember(source, target, text []byte, srcIndent string, obj jsonObject) []byte {
+	var insert []byte
+	at, end := obj.close, obj.close
+	switch {
+	case len(obj.members) > 0 && lineStart(target, obj.members[0].start) == lineStart(target, obj.open):
+		// Single-line object: stay on one line
+		var compact bytes.Buffer
+		if err := json.Compact(&compact, append(append([]byte("{"), text...), '}')); err == nil {
+			text = bytes.TrimSuffix(bytes.TrimPrefix(compact.Bytes(), []byte("{")), []byte("}"))
+		}
+		last := obj.members[len(obj.members)-1]
+		at, end = last.valueEnd, last.valueEnd
+		insert = append([]byte(", "), text...)
+	case len(obj.members) > 0:
+		indent := lineIndent(target, obj.members[0].start)
+		last := obj.members[len(obj.members)-1]
+		at, end = last.valueEnd, last.valueEnd
+		insert = append([]byte(",\n"+indent), reindentJSON(source, target, text, srcIndent, indent)...)
+	default:
+		// Empty object: indent one level deeper than the object's line
+		objIndent := lineIndent(target, obj.open)
+		indent := objIndent + indentStep(target)
+		at = obj.open + 1
+		insert = append([]byte("\n"+indent), reindentJSON(source, target, text, srcIndent, indent)...)
+		insert = append(insert, "\n"+objIndent...)
+	}
+
+	merged := append([]byte(nil), target[:at]...)
+	merged = append(merged, insert...)
+	return append(merged, target[end:]...)
+}
+
+// reindentJSON moves the continuation lines of a multi-line source value from one
+// indentation to another, in the indentation step of the target
+func reindentJSON(source, target, value []byte, from, to string) []byte {
+	lines := strings.Split(string(value), "\n")
+	copy(lines[1:], reindentLines(lines[1:], from, to, indentStep(source), indentStep(target)))
+	return []byte(strings.Join(lines, "\n"))
+}
+
+// lineStart returns the offset of the start of the line containing pos
+func lineStart(data []byte, pos int) int {
+	return bytes.LastIndexByte(data[:pos], '\n') + 1
+}
+
+// lineIndent returns the leading whitespace of the line containing pos
+func lineIndent(data []byte, pos int) string {
+	start := lineStart(data, pos)
+	end := start
+	for end < pos && (data[end] == ' ' || data[end] == '\t') {
+		end++
+	}
+	return string(data[start:end])
+}
+
+// parseJSONObject parses the members of the object starting at pos in a valid document
+func parseJSONObject(data []byte, pos int) (jsonObject, error) {
+	if pos >= len(data) || data[pos] != '{' {
+		return jsonObject{}, errors.New("not an object")
+	}
+	obj := jsonObject{open: pos}
+	pos = skipJSONSpace(data, pos+1)
+	for pos < len(data) && data[pos] != '}' {
+		keyEnd := skipJSONValue(data, pos)
+		var key string
+		if err := json.Unmarshal(data[pos:keyEnd], &key); err != nil {
+			return obj, err
+		}
+		colon := skipJSONSpace(data, keyEnd)
+		valueStart := skipJSONSpace(data, colon+1)
+		valueEnd := skipJSONValue(data, valueStart)
+		obj.members = append(obj.members, jsonMember{key: key, start: pos, valueStart: valueStart, valueEnd: valueEnd})
+
+		pos = skipJSONSpace(data, valueEnd)
+		if pos < len(data) && data[pos] == ',' {
+			pos = skipJSONSpace(data, pos+1)
+		}
+	}
+	obj.close = pos
+	return obj, nil
+}
+
+// skipJSONSpace returns the offset of the first non-whitespace byte at or after pos
+func skipJSONSpace(data []byte, pos int) int {
+	for pos < len(data) && strings.IndexByte(" \t\r\n", data[pos]) >= 0 {
+		pos++
+	}
+	return pos
+}
+
+// skipJSONValue returns the offset just past the value starting at pos in a valid document
+func skipJSONValue(data []byte, pos int) int {
+	depth := 0
+	inString := false
+	for i := pos; i < len(data); i++ {
+		c := data[i]
+		switch {
+		case inString:
+			if c == '\\' {
+				i++
+			} else if c == '"' {
+				inString = false
+				if depth == 0 {
+					return i + 1
+				}
+			}
+		case c == '"':
+			inString = true
+		case c == '{' || c == '[':
+			depth++
+		case c == '}' || c == ']':
+			if depth == 0 {
+				return i
+			}
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		case depth == 0 && strings.IndexByte(", \t\r\n", c) >= 0:
+			return i
+		}
+	}
+	return len(data)
+}
+
+// leaves implements structuredFormat
+func (jsonFormat) leaves(content []byte) (map[string]string, error) {
+	out := make(map[string]string)
+	if len(bytes.TrimSpace(content)) == 0 {
+		return out, nil
+	}
+	var value any
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	flattenLeaves("", value, out)
+	return out, nil
+}
@@ -0,0 +1,347 @@
+package filemirror
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestMergeKeys(t *testing.T) {
+	tests := []struct {
+		name        string
+		format      string
+		source      string
+		target      string
+		keys        []string
+		want        string
+		errContains string
+	}{
+		{
+			name:   "json replaces a subtree",
+			format: "json",
+			source: "{\n  \"compilerOptions\": {\n    \"strict\": true,\n    \"target\": \"es2022\"\n  },\n  \"include\": [\"src\"]\n}\n",
+			target: "{\n    \"extends\": \"../base.json\",\n    \"compilerOptions\": {\n        \"strict\": false\n    },\n    \"include\": [\"lib\"]\n}\n",
+			keys:   []string{"compilerOptions"},
+			want:   "{\n    \"extends\": \"../base.json\",\n    \"compilerOptions\": {\n        \"strict\": true,\n        \"target\": \"es2022\"\n    },\n    \"include\": [\"lib\"]\n}\n",
+		},
+		{
+			name:   "json nested key and insertion",
+			format: "json",
+			source: "{\"a\": {\"b\": 1, \"c\": {\"d\": [1, 2]}}}",
+			target: "{\n  \"a\": {\n    \"b\": 0\n  },\n  \"z\": null\n}\n",
+			keys:   []string{"a.b", "a.c"},
+			want:   "{\n  \"a\": {\n    \"b\": 1,\n    \"c\": {\"d\": [1, 2]}\n  },\n  \"z\": null\n}\n",
+		},
+		{
+			name:   "json into an empty target",
+			format: "json",
+			source: "{\n  \"a\": 1,\n  \"b\": 2\n}\n",
+			target: "",
+			keys:   []string{"b"},
+			want:   "{\n  \"b\": 2\n}\n",
+		},
+		{
+			name:   "json single-line object",
+			format: "json",
+			source: "{\n  \"a\": {\n    \"x\": 1\n  }\n}\n",
+			target: "{\"b\": true}\n",
+			keys:   []string{"a"},
+			want:   "{\"b\": true, \"a\":{\"x\":1}}\n",
+		},
+		{
+			name:   "json parent missing in target",
+			format: "json",
+			source: "{\n  \"compilerOptions\": {\n    \"strict\": true,\n    \"paths\": {\n      \"@/*\": [\"src/*\"]\n    }\n  }\n}\n",
+			target: "{\n    \"name\": \"x\"\n}\n",
+			keys:   []string{"compilerOptions.paths"},
+			want:   "{\n    \"name\": \"x\",\n    \"compilerOptions\": {\n        \"paths\": {\n            \"@/*\": [\"src/*\"]\n        }\n    }\n}\n",
+		},
+		{
+			name:        "json key missing in source",
+			format:      "json",
+			source:      "{}",
+			target:      "{}",
+			keys:        []string{"a.b"},
+			errContains: "a not found in source",
+		},
+		{
+			name:        "json target is not an object",
+			format:      "json",
+			source:      "{\"a\": {\"b\": 1}}",
+			target:      "{\"a\": 5}",
+			keys:        []string{"a.b"},
+			errContains: "target a: not an object",
+		},
+		{
+			name:   "yaml keeps comments and other keys",
+			format: "yaml",
+			source: "run:\n  timeout: 5m\nlinters:\n  enable:\n    - errcheck\n    - govet\n    - staticcheck\n",
+			target: "# repo settings\nrun:\n  timeout: 10m # slow CI\nlinters:\n  enable:\n    - errcheck\n\n# local exclusions\nissues:\n  exclude: [x]\n",
+			keys:   []string{"linters.enable"},
+			want:   "# repo settings\nrun:\n  timeout: 10m # slow CI\nlinters:\n  enable:\n    - errcheck\n    - govet\n    - staticcheck\n\n# local exclusions\nissues:\n  exclude: [x]\n",
+		},
+		{
+			name:   "yaml reindents and inserts missing parents",
+			format: "yaml",
+			source: "a:\n    b:\n        c: 1\n        d: [1, 2]\n",
+			target: "x: 1\na:\n  e: 2\n",
+			keys:   []string{"a.b"},
+			want:   "x: 1\na:\n  e: 2\n  b:\n    c: 1\n    d: [1, 2]\n",
+		},
+		{
+			name:   "yaml parent missing in target",
+			format: "yaml",
+			source: "a:\n  b:\n    keep: 1\n    other: 2\n  c: 3\n",
+			target: "x: 1\n",
+			keys:   []string{"a.b.keep"},
+			want:   "x: 1\na:\n  b:\n    keep: 1\n",
+		},
+		{
+			name:   "yaml sequence indented like its key",
+			format: "yaml",
+			source: "list:\n- a\n- b\nother: 1\n",
+			target: "list:\n- c\nkeep: true\n",
+			keys:   []string{"list"},
+			want:   "list:\n- a\n- b\nkeep: true\n",
+		},
+		{
+			name:   "yaml under an empty key",
+			format: "yaml",
+			source: "a:\n  b: 1\n",
+			target: "a:\nz: 2\n",
+			keys:   []string{"a.b"},
+			want:   "a:\n  b: 1\nz: 2\n",
+		},
+		{
+			name:   "yaml into an empty target",
+			format: "yaml",
+			source: "a:\n  b: 1\n",
+			target: "",
+			keys:   []string{"a"},
+			want:   "a:\n  b: 1\n",
+		},
+		{
+			name:        "yaml flow mapping",
+			format:      "yaml",
+			source:      "a: {b: 1}\n",
+			target:      "a: {b: 2}\n",
+			keys:        []string{"a.b"},
+			errContains: "flow-style mappings are not supported",
+		},
+		{
+			name:   "toml replaces a table and its subtables",
+			format: "toml",
+			source: "[tool.ruff]\nline-length = 100\n\n[tool.ruff.lint]\nselect = [\n  \"E\",\n  \"F\",\n]\n",
+			target: "[project]\nname = \"api\"\n\n[tool.ruff]\nline-length = 88 # old\n\n[tool.mypy]\nstrict = true\n\n[tool.ruff.lint]\nselect = [\"E\"]\n",
+			keys:   []string{"tool.ruff"},
+			want:   "[project]\nname = \"api\"\n\n[tool.ruff]\nline-length = 100\n\n[tool.ruff.lint]\nselect = [\n  \"E\",\n  \"F\",\n]\n\n[tool.mypy]\nstrict = true\n\n",
+		},
+		{
+			name:   "toml key in a table",
+			format: "toml",
+			source: "[tool.black]\nline-length = 100\ntarget-version = [\"py312\"]\n",
+			target: "[tool.black]\nline-length = 88\nskip-string-normalization = true\n\n[other]\nx = 1\n",
+			keys:   []string{"tool.black.line-length", "tool.black.target-version"},
+			want:   "[tool.black]\nline-length = 100\nskip-string-normalization = true\ntarget-version = [\"py312\"]\n\n[other]\nx = 1\n",
+		},
+		{
+			name:   "toml table missing in target",
+			format: "toml",
+			source: "title = \"x\"\n\n[owner]\nname = \"a\"\n",
+			target: "version = 2\n",
+			keys:   []string{"owner.name", "title"},
+			want:   "version = 2\ntitle = \"x\"\n\n[owner]\nname = \"a\"\n",
+		},
+		{
+			name:   "toml parent missing in target",
+			format: "toml",
+			source: "[tool.black]\nline-length = 100\ntarget-version = [\"py312\"]\n",
+			target: "[project]\nname = \"api\"\n",
+			keys:   []string{"tool.black.line-length"},
+			want:   "[project]\nname = \"api\"\n\n[tool.black]\nline-length = 100\n",
+		},
+		{
+			name:        "toml key missing in source",
+			format:      "toml",
+			source:      "a = 1\n",
+			target:      "a = 2\n",
+			keys:        []string{"b"},
+			errContains: "b not found in source",
+		},
+		{
+			name:   "toml dotted key",
+			format: "toml",
+			source: "t.b = 1\n",
+			target: "t.b = 2\nt.c = 3\n",
+			keys:   []string{"t.b"},
+			want:   "t.b = 1\nt.c = 3\n",
+		},
+		{
+			name:        "toml dotted key into a table",
+			format:      "toml",
+			source:      "t.b = 1\n",
+			target:      "[t]\nb = 2\n",
+			keys:        []string{"t.b"},
+			errContains: "merged TOML is invalid: line 3: t is defined twice",
+		},
+		{
+			name:        "toml table into an inline table",
+			format:      "toml",
+			source:      "[t]\nb = 1\n",
+			target:      "t = { b = 2 }\n",
+			keys:        []string{"t"},
+			errContains: "merged TOML is invalid",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := mergeKeys(structuredFormats[tt.format], []byte(tt.source), []byte(tt.target), tt.keys)
+			if tt.errContains != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+					t.Errorf("mergeKeys() error = %v, want %q", err, tt.errContains)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("mergeKeys() failed: %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("mergeKeys() =\n%s\nwant:\n%s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyDiff(t *testing.T) {
+	tests := []struct {
+		format  string
+		merged  string
+		current string
+		want    []string
+	}{
+		{
+			format:  "json",
+			merged:  `{"c": {"strict": true, "lib": ["dom"], "new": 1}, "other": 1}`,
+			current: `{"c": {"strict": false, "lib": ["dom"], "old": "x"}, "other": 2}`,
+			want:    []string{"+ c.new: 1", `- c.old: "x"`, "~ c.strict: false → true"},
+		},
+		{
+			format:  "yaml",
+			merged:  "c:\n  enable: [a, b]\n  settings: {}\n",
+			current: "c:\n  enable: [a]\n",
+			want:    []string{`~ c.enable: ["a"] → ["a","b"]`, "+ c.settings: {}"},
+		},
+		{
+			format:  "toml",
+			merged:  "[c]\nx = 1 # note\ny = [\n  1,\n  2,\n]\n\n[[c.servers]]\nname = \"a\"\n",
+			current: "[c]\nx = 1\ny = [1]\n",
+			want:    []string{`+ c.servers[0].name: "a"`, "~ c.y: [1] → [ 1, 2, ]"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			got, err := keyDiff(structuredFormats[tt.format], []byte(tt.merged), []byte(tt.current), []string{"c"})
+			if err != nil {
+				t.Fatalf("keyDiff() failed: %v", err)
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("keyDiff() =\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+			}
+		})
+	}
+}
+
+func TestValidateKeyFlags(t *testing.T) {
+	tests := []struct {
+		name        string
+		source      string
+		keys        []string
+		format      string
+		section     string
+		errContains string
+	}{
+		{name: "format from extension", source: "tsconfig.json", keys: []string{"compilerOptions"}},
+		{name: "explicit format", source: ".eslintrc", keys: []string{"rules"}, format: "json"},
+		{name: "unknown extension", source: ".eslintrc", keys: []string{"rules"}, errContains: "cannot tell the format of .eslintrc"},
+		{name: "unknown format", source: "a.json", keys: []string{"a"}, format: "ini", errContains: `unknown format "ini"`},
+		{name: "format without keys", source: "a.json", format: "json", errContains: "--format requires --key"},
+		{name: "keys with section", source: "a.json", keys: []string{"a"}, section: "x", errContains: "cannot be combined with --section"},
+		{name: "empty key part", source: "a.json", keys: []string{"a..b"}, errContains: "invalid key path"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateKeyFlags(tt.source, tt.keys, tt.format, tt.section)
+			if tt.errContains == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+				t.Errorf("validateKeyFlags() error = %v, want %q", err, tt.errContains)
+			}
+		})
+	}
+}
+
+func TestRunSyncKeys(t *testing.T) {
+	tmpDir := t.TempDir()
+	source := "{\n  \"compilerOptions\": {\n    \"strict\": true\n  },\n  \"include\": [\"src\"]\n}\n"
+	target := "{\n  \"extends\": \"./base.json\",\n  \"compilerOptions\": {\n    \"strict\": false\n  }\n}\n"
+	for name, content := range map[string]string{"tsconfig.json": source, "web/tsconfig.json": target} {
+		path := filepath.Join(tmpDir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	args := []string{"-p", tmpDir, "-s", "tsconfig.json", "-t", "web/tsconfig.json", "--key", "compilerOptions"}
+	if code := runSync(args, &stdout, &stderr); code != 0 {
+		t.Fatalf("Exit code = %d\nstdout: %s\nstderr: %s", code, stdout.String(), stderr.String())
+	}
+	want := "{\n  \"extends\": \"./base.json\",\n  \"compilerOptions\": {\n    \"strict\": true\n  }\n}\n"
+	if content, _ := os.ReadFile(filepath.Join(tmpDir, "web", "tsconfig.json")); string(content) != want {
+		t.Errorf("web/tsconfig.json = %q, want %q", content, want)
+	}
+
+	stdout.Reset()
+	if code := runCheck([]string{"-p", tmpDir, "-s", "tsconfig.json", "-t", "web/tsconfig.json", "-k", "compilerOptions"}, &stdout, &stderr); code != checkExitInSync {
+		t.Errorf("check exit code = %d\n%s", code, stdout.String())
+	}
+}
+
+func TestKeyDiffPreview(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(tmpDir, "a.yaml"), []byte("lint:\n  enable: [errcheck, govet]\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write source: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "b.yaml"), []byte("name: b\nlint:\n  enable: [errcheck]\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write target: %v", err)
+	}
+
+	m := InitialModel("", tmpDir)
+	m.width, m.height = 160, 30
+	m.keys = []string{"lint"}
+	m.files = []FileInfo{{Path: "a.yaml"}, {Path: "b.yaml"}}
+	m.filterFiles()
+	m.sourceFile = &m.filteredFiles[0]
+	m.previewMode = previewDiff
+	m.cursor = 1
+
+	m.refreshPreview()
+	view := m.renderPreview()
+	for _, want := range []string{"[keys lint]", "@@ keys: 1 changed @@", `~ lint.enable: ["errcheck"] → ["errcheck","govet"]`} {
+		if !strings.Contains(view, want) {
+			t.Errorf("Expected %q in the preview, got:\n%s", want, view)
+		}
+	}
+}
@@ -0,0 +1,502 @@
+package filemirror
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// tomlFormat merges TOML tables and keys by splicing their lines. It reads the line
+// structure of TOML (headers, keys and multi-line values) rather than decoding values.
+type tomlFormat struct{}
+
+// tomlEntry is a table header or a key/value pair of a TOML document, on lines [start, end)
+type tomlEntry struct {
+	header  bool
+	array   bool     // header of an array of tables, or a key inside one
+	table   []string // the header's table, or the table a key belongs to
+	key     []string // dotted key within table, for key/value pairs
+	value   string   // raw value text, for key/value pairs
+	start   int
+	end     int
+	section string // table path for leaves, with array indexes, e.g. "servers[1]"
+}
+
+// path returns the full key path of the entry
+func (e tomlEntry) path() []string {
+	return append(append([]string(nil), e.table...), e.key...)
+}
+
+// parseTOML splits TOML lines into headers and key/value pairs
+func parseTOML(lines []string) ([]tomlEntry, error) {
+	var entries []tomlEntry
+	var table []string
+	array := false
+	section := ""
+	arrayCounts := make(map[string]int)
+
+	for i := 0; i < len(lines); i++ {
+		trimmed := strings.TrimSpace(lines[i])
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(trimmed, "[") {
+			array = strings.HasPrefix(trimmed, "[[")
+			open, closing := 1, "]"
+			if array {
+				open, closing = 2, "]]"
+			}
+			end := strings.Index(trimmed, closing)
+			if end < open {
+				return nil, fmt.Errorf("line %d: invalid table header", i+1)
+			}
+			name, err := parseTOMLKey(trimmed[open:end])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", i+1, err)
+			}
+			table = name
+			section = strings.Join(name, ".")
+			if array {
+				count := arrayCounts[section]
+				arrayCounts[section]++
+				section = fmt.Sprintf("%s[%d]", section, count)
+			}
+			entries = append(entries, tomlEntry{header: true, array: array, table: name, start: i, end: i + 1, section: section})
+			continue
+		}
+
+		eq := indexOutsideQuotes(trimmed, '=')
+		if eq < 0 {
+			return nil, fmt.Errorf("line %d: expected key = value", i+1)
+		}
+		key, err := parseTOMLKey(trimmed[:eq])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		value := strings.TrimSpace(trimmed[eq+1:])
+		end := i + 1
+		for _, complete := scanTOMLValue(value); !complete && end < len(lines); _, complete = scanTOMLValue(value) {
+			value += "\n" + strings.TrimRight(lines[end], "\r\n")
+			end++
+		}
+		entries = append(entries, tomlEntry{array: array, table: table, key: key, value: value, start: i, end: end, section: section})
+		i = end - 1
+	}
+	return entries, nil
+}
+
+// parseTOMLKey parses a dotted key of bare and quoted parts
+func parseTOMLKey(s string) ([]string, error) {
+	var parts []string
+	rest := strings.TrimSpace(s)
+	for {
+		var part string
+		switch {
+		case strings.HasPrefix(rest, `"`):
+			end := 1
+			for end < len(rest) && rest[end] != '"' {
+				if rest[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(rest) {
+				return nil, fmt.Errorf("invalid key %q", s)
+			}
+			unquoted, err := strconv.Unquote(rest[:end+1])
+			if err != nil {
+				unquoted = rest[1:end]
+			}
+			part, rest = unquoted, rest[end+1:]
+		case strings.HasPrefix(rest, "'"):
+			end := strings.IndexByte(rest[1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid key %q", s)
+			}
+			part, rest = rest[1:end+1], rest[end+2:]
+		default:
+			end := strings.IndexFunc(rest, func(r rune) bool {
+				return !(r == '_' || r == '-' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z')
+			})
+			if end < 0 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("invalid key %q", s)
+			}
+			part, rest = rest[:end], rest[end:]
+		}
+		parts = append(parts, part)
+
+		rest = strings.TrimLeft(rest, " \t")
+		if rest == "" {
+			return parts, nil
+		}
+		if rest[0] != '.' {
+			return nil, fmt.Errorf("invalid key %q", s)
+		}
+		rest = strings.TrimLeft(rest[1:], " \t")
+	}
+}
+
+// indexOutsideQuotes returns the index of the first c in s outside quoted strings, or -1
+func indexOutsideQuotes(s string, c byte) int {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		switch {
+		case quote != 0:
+			if s[i] == '\\' && quote == '"' {
+				i++
+			} else if s[i] == quote {
+				quote = 0
+			}
+		case s[i] == '"' || s[i] == '\'':
+			quote = s[i]
+		case s[i] == c:
+			return i
+		}
+	}
+	return -1
+}
+
+// scanTOMLValue returns a raw value without comments and with whitespace outside strings
+// collapsed, and whether the value is complete rather than continued on the next line
+func scanTOMLValue(v string) (string, bool) {
+	var b strings.Builder
+	depth := 0
+	space := false
+	for i := 0; i < len(v); i++ {
+		c := v[i]
+		if c == '#' {
+			for i < len(v) && v[i] != '\n' {
+				i++
+			}
+			continue
+		}
+		if strings.IndexByte(" \t\r\n", c) >= 0 {
+			space = b.Len() > 0
+			continue
+		}
+		if space {
+			b.WriteByte(' ')
+			space = false
+		}
+
+		switch {
+		case strings.HasPrefix(v[i:], `"""`) || strings.HasPrefix(v[i:], `'''`):
+			end := strings.Index(v[i+3:], v[i:i+3])
+			if end < 0 {
+				return b.String(), false
+			}
+			b.WriteString(v[i : i+3+end+3])
+			i += 3 + end + 2
+		case c == '"' || c == '\'':
+			end := i + 1
+			for end < len(v) && v[end] != c {
+				if v[end] == '\\' && c == '"' {
+					end++
+				}
+				end++
+			}
+			end = minInt(end, len(v)-1)
+			b.WriteString(v[i : end+1])
+			i = end
+		default:
+			if c == '[' || c == '{' {
+				depth++
+			} else if c == ']' || c == '}' {
+				depth--
+			}
+			b.WriteByte(c)
+		}
+	}
+	return b.String(), depth <= 0
+}
+
+// tomlBlockEnd returns the end of the block started by the header at index h: the line
+// after its last key/value pair, or after the header if it has none
+func tomlBlockEnd(entries []tomlEntry, h int) int {
+	end := entries[h].end
+	for _, e := range entries[h+1:] {
+		if e.header {
+			break
+		}
+		end = e.end
+	}
+	return end
+}
+
+// tomlNextHeader returns the first line of the header after index h, or total if there is none
+func tomlNextHeader(entries []tomlEntry, h, total int) int {
+	for _, e := range entries[h+1:] {
+		if e.header {
+			return e.start
+		}
+	}
+	return total
+}
+
+// hasKeyPrefix reports whether path starts with prefix
+func hasKeyPrefix(path, prefix []string) bool {
+	if len(path) < len(prefix) {
+		return false
+	}
+	for i := range prefix {
+		if path[i] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// withNewline returns lines with the last one ending in a newline
+func withNewline(lines []string) []string {
+	if len(lines) == 0 || strings.HasSuffix(lines[len(lines)-1], "\n") {
+		return lines
+	}
+	out := append([]string(nil), lines...)
+	out[len(out)-1] += "\n"
+	return out
+}
+
+// tomlEdit replaces lines [start, end) of a document
+type tomlEdit struct {
+	start, end int
+	lines      []string
+}
+
+// applyTOMLEdits applies non-overlapping edits, given in document order, to lines
+func applyTOMLEdits(lines []string, edits []tomlEdit) []byte {
+	for i := len(edits) - 1; i >= 0; i-- {
+		lines = splitLines(spliceLines(lines, edits[i].start, edits[i].end, edits[i].lines))
+	}
+	return []byte(strings.Join(lines, ""))
+}
+
+// merge implements structuredFormat
+func (tomlFormat) merge(source, target []byte, path []string) ([]byte, error) {
+	srcLines := splitLines(source)
+	src, err := parseTOML(srcLines)
+	if err != nil {
+		return nil, fmt.Errorf("source: %w", err)
+	}
+	lines := splitLines(target)
+	tgt, err := parseTOML(lines)
+	if err != nil {
+		return nil, fmt.Errorf("target: %w", err)
+	}
+
+	var merged []byte
+	for _, e := range src {
+		if e.header && hasKeyPrefix(e.table, path) {
+			merged = mergeTOMLTable(srcLines, src, lines, tgt, path)
+			break
+		}
+	}
+	if merged == nil {
+		if merged, err = mergeTOMLKey(srcLines, src, lines, tgt, path); err != nil {
+			return nil, err
+		}
+	}
+	// Splicing lines cannot tell a dotted key or an inline table from a table header,
+	// so the same table may now be defined twice
+	if err := validateTOML(merged); err != nil {
+		return nil, fmt.Errorf("merged TOML is invalid: %w", err)
+	}
+	return merged, nil
+}
+
+// Kinds of the names defined in a TOML document, for validateTOML
+const (
+	tomlImplicit = iota + 1 // table created as the parent of a header
+	tomlDotted              // table created by a dotted key
+	tomlTable               // table defined by a header
+	tomlArray               // array of tables
+	tomlValue               // key with a value, including inline tables
+)
+
+// validateTOML checks that content defines every key and table only once, and does not
+// extend inline tables or tables of dotted keys with headers
+func validateTOML(content []byte) error {
+	entries, err := parseTOML(splitLines(content))
+	if err != nil {
+		return err
+	}
+	kinds := make(map[string]int)
+	for _, e := range entries {
+		name := strings.Join(e.table, ".")
+		if e.header {
+			for i := 1; i < len(e.table); i++ {
+				parent := strings.Join(e.table[:i], ".")
+				switch kinds[parent] {
+				case tomlValue:
+					return fmt.Errorf("line %d: %s is not a table", e.start+1, parent)
+				case 0:
+					kinds[parent] = tomlImplicit
+				}
+			}
+			kind := kinds[name]
+			switch {
+			case e.array && (kind == 0 || kind == tomlArray):
+				kinds[name] = tomlArray
+				continue
+			case !e.array && (kind == 0 || kind == tomlImplicit):
+				kinds[name] = tomlTable
+				continue
+			}
+			return fmt.Errorf("line %d: %s is defined twice", e.start+1, name)
+		}
+
+		prefix := e.section
+		for i := range e.key {
+			key := strings.Join(e.key[:i+1], ".")
+			if prefix != "" {
+				key = prefix + "." + key
+			}
+			kind := kinds[key]
+			if i == len(e.key)-1 {
+				if kind != 0 {
+					return fmt.Errorf("line %d: %s is defined twice", e.start+1, key)
+				}
+				kinds[key] = tomlValue
+				break
+			}
+			switch kind {
+			case 0:
+				kinds[key] = tomlDotted
+			case tomlValue, tomlTable, tomlArray:
+				return fmt.Errorf("line %d: %s is defined twice", e.start+1, key)
+			}
+		}
+	}
+	return nil
+}
+
+// mergeTOMLTable replaces the table at path and its subtables with the source's
+func mergeTOMLTable(srcLines []string, src []tomlEntry, lines []string, tgt []tomlEntry, path []string) []byte {
+	var block []string
+	for h, e := range src {
+		if !e.header || !hasKeyPrefix(e.table, path) {
+			continue
+		}
+		if len(block) > 0 {
+			block = append(block, "\n")
+		}
+		block = append(block, withNewline(srcLines[e.start:tomlBlockEnd(src, h)])...)
+	}
+
+	var edits []tomlEdit
+	for h, e := range tgt {
+		if !e.header || !hasKeyPrefix(e.table, path) {
+			continue
+		}
+		if len(edits) == 0 {
+			edits = append(edits, tomlEdit{start: e.start, end: tomlBlockEnd(tgt, h), lines: block})
+		} else {
+			edits = append(edits, tomlEdit{start: e.start, end: tomlNextHeader(tgt, h, len(lines))})
+		}
+	}
+	if len(edits) == 0 {
+		if len(lines) > 0 {
+			block = append([]string{"\n"}, block...)
+		}
+		edits = append(edits, tomlEdit{start: len(lines), end: len(lines), lines: block})
+	}
+	return applyTOMLEdits(lines, edits)
+}
+
+// mergeTOMLKey replaces the key/value pairs at path with the source's
+func mergeTOMLKey(srcLines []string, src []tomlEntry, lines []string, tgt []tomlEntry, path []string) ([]byte, error) {
+	var block []string
+	var table []string
+	found := false
+	for _, e := range src {
+		if e.header || !hasKeyPrefix(e.path(), path) {
+			continue
+		}
+		if e.array {
+			return nil, errors.New("keys in arrays of tables are not supported")
+		}
+		if found && strings.Join(e.table, ".") != strings.Join(table, ".") {
+			return nil, errors.New("key is split across tables in source")
+		}
+		table, found = e.table, true
+		block = append(block, withNewline(srcLines[e.start:e.end])...)
+	}
+	if !found {
+		return nil, fmt.Errorf("%s not found in source", strings.Join(path, "."))
+	}
+
+	var edits []tomlEdit
+	for _, e := range tgt {
+		if e.header || e.array || strings.Join(e.table, ".") != strings.Join(table, ".") || !hasKeyPrefix(e.path(), path) {
+			continue
+		}
+		if len(edits) == 0 {
+			edits = append(edits, tomlEdit{start: e.start, end: e.end, lines: block})
+		} else {
+			edits = append(edits, tomlEdit{start: e.start, end: e.end})
+		}
+	}
+	if len(edits) > 0 {
+		return applyTOMLEdits(lines, edits), nil
+	}
+
+	// Insert at the end of the key's table, adding the table if the target lacks it
+	at := -1
+	if len(table) == 0 {
+		at = 0
+		for _, e := range tgt {
+			if e.header {
+				break
+			}
+			at = e.end
+		}
+		if at == 0 && len(lines) > 0 {
+			block = append(block, "\n")
+		}
+	} else {
+		for h, e := range tgt {
+			if e.header && !e.array && strings.Join(e.table, ".") == strings.Join(table, ".") {
+				at = tomlBlockEnd(tgt, h)
+				break
+			}
+		}
+	}
+	if at < 0 {
+		header := "[" + strings.Join(table, ".") + "]\n"
+		for _, e := range src {
+			if e.header && strings.Join(e.table, ".") == strings.Join(table, ".") {
+				header = withNewline(srcLines[e.start:e.end])[0]
+			}
+		}
+		block = append([]string{header}, block...)
+		if len(lines) > 0 {
+			block = append([]string{"\n"}, block...)
+		}
+		at = len(lines)
+	}
+	return applyTOMLEdits(lines, []tomlEdit{{start: at, end: at, lines: block}}), nil
+}
+
+// leaves implements structuredFormat
+func (tomlFormat) leaves(content []byte) (map[string]string, error) {
+	entries, err := parseTOML(splitLines(content))
+	if err != nil {
+		return nil, fmt.Errorf("invalid TOML: %w", err)
+	}
+	out := make(map[string]string)
+	for _, e := range entries {
+		if e.header {
+			continue
+		}
+		path := strings.Join(e.key, ".")
+		if e.section != "" {
+			path = e.section + "." + path
+		}
+		out[path], _ = scanTOMLValue(e.value)
+	}
+	return out, nil
+}
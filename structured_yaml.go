@@ -0,0 +1,191 @@
+package filemirror
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// yamlFormat merges block-style YAML mappings by splicing the lines of the selected keys
+type yamlFormat struct{}
+
+// yamlRoot parses content and returns its top-level mapping, or nil for an empty document
+func yamlRoot(content []byte) (*yaml.Node, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return nil, fmt.Errorf("invalid YAML: %w", err)
+	}
+	if doc.Kind == 0 || len(doc.Content) == 0 {
+		return nil, nil
+	}
+	root := doc.Content[0]
+	if root.Kind == yaml.ScalarNode && root.Tag == "!!null" {
+		return nil, nil
+	}
+	if root.Kind != yaml.MappingNode {
+		return nil, errors.New("document is not a mapping")
+	}
+	return root, nil
+}
+
+// yamlLookup returns the key and value nodes of key in a block mapping
+func yamlLookup(mapping *yaml.Node, key string) (*yaml.Node, *yaml.Node, error) {
+	if mapping.Style&yaml.FlowStyle != 0 {
+		return nil, nil, errors.New("flow-style mappings are not supported")
+	}
+	for i := 0; i+1 < len(mapping.Content); i += 2 {
+		if mapping.Content[i].Value == key {
+			return mapping.Content[i], mapping.Content[i+1], nil
+		}
+	}
+	return nil, nil, nil
+}
+
+// yamlSpan returns the lines [start, end) of a block mapping entry, from its key to the
+// last line of its value. Trailing blank and comment lines are left to what follows.
+func yamlSpan(lines []string, key, value *yaml.Node) (int, int) {
+	start := key.Line - 1
+	indent := key.Column - 1
+	end := start + 1
+	for i := start + 1; i < len(lines); i++ {
+		trimmed := strings.TrimSpace(lines[i])
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		lineIndent := leadingSpaces(lines[i])
+		// Sequences may be indented like their key
+		sequenceItem := value.Kind == yaml.SequenceNode && lineIndent == indent && (trimmed == "-" || strings.HasPrefix(trimmed, "- "))
+		if lineIndent <= indent && !sequenceItem {
+			break
+		}
+		end = i + 1
+	}
+	return start, end
+}
+
+// merge implements structuredFormat
+func (yamlFormat) merge(source, target []byte, path []string) ([]byte, error) {
+	srcRoot, err := yamlRoot(source)
+	if err != nil {
+		return nil, fmt.Errorf("source: %w", err)
+	}
+	if srcRoot == nil {
+		return nil, errors.New("source is empty")
+	}
+	srcLines := splitLines(source)
+
+	// Find the entries along path in the source
+	srcKeys := make([]*yaml.Node, len(path))
+	srcValues := make([]*yaml.Node, len(path))
+	mapping := srcRoot
+	for i, part := range path {
+		key, value, err := yamlLookup(mapping, part)
+		if err != nil {
+			return nil, fmt.Errorf("source %s: %w", strings.Join(path[:i], "."), err)
+		}
+		if key == nil {
+			return nil, fmt.Errorf("%s not found in source", strings.Join(path[:i+1], "."))
+		}
+		srcKeys[i], srcValues[i] = key, value
+		if i < len(path)-1 {
+			if value.Kind != yaml.MappingNode {
+				return nil, fmt.Errorf("source %s is not a mapping", strings.Join(path[:i+1], "."))
+			}
+			mapping = value
+		}
+	}
+	// block returns the source lines of the entry at depth i
+	block := func(i int) ([]string, int) {
+		start, end := yamlSpan(srcLines, srcKeys[i], srcValues[i])
+		return srcLines[start:end], srcKeys[i].Column - 1
+	}
+	// nested returns the entry at depth i with only the selected key inside it: the key
+	// lines of the parents down to depth i, then the block of the selected key
+	nested := func(i int) ([]string, int) {
+		leaf, leafFrom := block(len(path) - 1)
+		from := srcKeys[i].Column - 1
+		step := indentStep(source)
+		var entry []string
+		for j := i; j < len(path)-1; j++ {
+			entry = append(entry, strings.Repeat(" ", from)+strings.Repeat(step, j-i)+strings.TrimLeft(srcLines[srcKeys[j].Line-1], " "))
+		}
+		to := strings.Repeat(" ", from) + strings.Repeat(step, len(path)-1-i)
+		return append(entry, reindentLines(leaf, strings.Repeat(" ", leafFrom), to, step, step)...), from
+	}
+
+	root, err := yamlRoot(target)
+	if err != nil {
+		return nil, fmt.Errorf("target: %w", err)
+	}
+	lines := splitLines(target)
+
+	var merged []byte
+	var parentKey *yaml.Node // key of the target mapping searched, nil for the document
+	mapping = root
+	for i, part := range path {
+		var key, value *yaml.Node
+		if mapping != nil {
+			if key, value, err = yamlLookup(mapping, part); err != nil {
+				return nil, fmt.Errorf("target %s: %w", strings.Join(path[:i], "."), err)
+			}
+		}
+
+		if key == nil {
+			// Insert the source entry at the end of the mapping, indented like its siblings
+			entry, from := nested(i)
+			to, at := 0, len(lines)
+			switch {
+			case mapping != nil && len(mapping.Content) > 0:
+				to = mapping.Content[0].Column - 1
+				lastKey, lastValue := mapping.Content[len(mapping.Content)-2], mapping.Content[len(mapping.Content)-1]
+				_, at = yamlSpan(lines, lastKey, lastValue)
+			case parentKey != nil:
+				to = parentKey.Column - 1 + len(indentStep(target))
+				at = parentKey.Line
+			}
+			merged = spliceLines(lines, at, at, reindentYAML(source, target, entry, from, to))
+			break
+		}
+
+		if i == len(path)-1 {
+			start, end := yamlSpan(lines, key, value)
+			entry, from := block(i)
+			merged = spliceLines(lines, start, end, reindentYAML(source, target, entry, from, key.Column-1))
+			break
+		}
+
+		switch {
+		case value.Kind == yaml.MappingNode:
+			mapping = value
+		case value.Kind == yaml.ScalarNode && value.Tag == "!!null" && value.Value == "":
+			mapping = nil // an empty "key:" gets the entry as its first child
+		default:
+			return nil, fmt.Errorf("target %s is not a mapping", strings.Join(path[:i+1], "."))
+		}
+		parentKey = key
+	}
+
+	if _, err := yamlRoot(merged); err != nil {
+		return nil, fmt.Errorf("merged YAML is invalid: %w", err)
+	}
+	return merged, nil
+}
+
+// reindentYAML moves source lines from column from to column to, in the indentation step
+// of the target
+func reindentYAML(source, target []byte, lines []string, from, to int) []string {
+	return reindentLines(lines, strings.Repeat(" ", from), strings.Repeat(" ", to), indentStep(source), indentStep(target))
+}
+
+// leaves implements structuredFormat
+func (yamlFormat) leaves(content []byte) (map[string]string, error) {
+	out := make(map[string]string)
+	var value any
+	if err := yaml.Unmarshal(content, &value); err != nil {
+		return nil, fmt.Errorf("invalid YAML: %w", err)
+	}
+	flattenLeaves("", value, out)
+	return out, nil
+}
@@ -0,0 +1,744 @@
+package filemirror
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// syncOptions holds the parsed arguments for the headless sync command
+type syncOptions struct {
+	WorkDir       string
+	Source        string
+	Targets       []string // file paths or glob patterns, relative to WorkDir
+	GitEnabled    bool
+	BranchName    string // template, see gitTemplateData
+	CommitMessage string // template, see gitTemplateData
+	Ticket        string // ticket ID available to the templates as {{.Ticket}}
+	Push          bool
+	Jobs          int       // repositories processed at the same time (0: default)
+	PR            PROptions // pull requests to open for pushed branches
+	Base          string    // ref new branches start from: "" for the default branch, "HEAD" or a ref
+	Fetch         bool      // fetch origin before creating branches
+	Transactional bool      // write all targets or none of them
+	GitOnly       bool      // commit on the branch only, leave the working trees untouched
+	DryRun        bool
+	ShowHelp      bool
+	ManifestPath  string   // manifest to run instead of --source/--target
+	Groups        []string // manifest groups to run (default: all)
+	Section       string   // managed section to mirror instead of the whole file
+	InsertMissing bool     // append the section to targets without its markers
+	Keys          []string // key paths to mirror instead of the whole file
+	Format        string   // format of the keyed file: json, yaml or toml (default: from the extension)
+	Merge         bool     // merge with local changes since the last sync instead of overwriting
+	Force         bool     // write merges with conflicts, conflict markers included
+
+	Template *sourceTemplate // renders the source per target, set for --section, --key, --merge and templated, section, keyed or merged manifest groups
+}
+
+// targetResult is the outcome of syncing the source into a single target
+type targetResult struct {
+	Path string
+	Err  error
+}
+
+// parseSyncArgs parses the arguments following "fmr sync"
+// Returns an error if arguments are invalid
+func parseSyncArgs(args []string) (syncOptions, error) {
+	var opts syncOptions
+
+	// value returns the argument following a flag, or an error if it is missing
+	value := func(i int, flag, what string) (string, error) {
+		if i+1 >= len(args) {
+			return "", fmt.Errorf("%s requires %s", flag, what)
+		}
+		return args[i+1], nil
+	}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch arg {
+		case "-h", "--help":
+			opts.ShowHelp = true
+			return opts, nil
+		case "-p", "--path":
+			v, err := value(i, "--path", "a directory argument")
+			if err != nil {
+				return opts, err
+			}
+			opts.WorkDir = v
+			i++
+		case "-s", "--source":
+			v, err := value(i, "--source", "a file argument")
+			if err != nil {
+				return opts, err
+			}
+			opts.Source = v
+			i++
+		case "-t", "--target":
+			v, err := value(i, "--target", "a file or glob argument")
+			if err != nil {
+				return opts, err
+			}
+			opts.Targets = append(opts.Targets, v)
+			i++
+		case "-b", "--branch":
+			v, err := value(i, "--branch", "a branch name")
+			if err != nil {
+				return opts, err
+			}
+			opts.BranchName = v
+			opts.GitEnabled = true
+			i++
+		case "-m", "--message":
+			v, err := value(i, "--message", "a commit message")
+			if err != nil {
+				return opts, err
+			}
+			opts.CommitMessage = v
+			opts.GitEnabled = true
+			i++
+		case "--ticket":
+			v, err := value(i, "--ticket", "a ticket ID")
+			if err != nil {
+				return opts, err
+			}
+			opts.Ticket = v
+			opts.GitEnabled = true
+			i++
+		case "--manifest":
+			v, err := value(i, "--manifest", "a file argument")
+			if err != nil {
+				return opts, err
+			}
+			opts.ManifestPath = v
+			i++
+		case "-g", "--group":
+			v, err := value(i, "--group", "a group name")
+			if err != nil {
+				return opts, err
+			}
+			opts.Groups = append(opts.Groups, v)
+			i++
+		case "--git":
+			opts.GitEnabled = true
+		case "--base":
+			v, err := value(i, "--base", "a ref")
+			if err != nil {
+				return opts, err
+			}
+			opts.Base = v
+			opts.GitEnabled = true
+			i++
+		case "--fetch":
+			opts.Fetch = true
+			opts.GitEnabled = true
+		case "--push":
+			opts.Push = true
+			opts.GitEnabled = true
+		case "-j", "--jobs":
+			v, err := value(i, "--jobs", "a number of repositories")
+			if err != nil {
+				return opts, err
+			}
+			jobs, err := parseJobs(v)
+			if err != nil {
+				return opts, err
+			}
+			opts.Jobs = jobs
+			i++
+		case "--transactional":
+			opts.Transactional = true
+		case "--git-only":
+			opts.GitOnly = true
+			opts.GitEnabled = true
+		case "--section":
+			v, err := value(i, "--section", "a section name")
+			if err != nil {
+				return opts, err
+			}
+			if err := validateSectionName(v); err != nil {
+				return opts, err
+			}
+			opts.Section = v
+			i++
+		case "--insert-missing":
+			opts.InsertMissing = true
+		case "-k", "--key":
+			v, err := value(i, "--key", "a key path")
+			if err != nil {
+				return opts, err
+			}
+			opts.Keys = append(opts.Keys, v)
+			i++
+		case "--format":
+			v, err := value(i, "--format", "json, yaml or toml")
+			if err != nil {
+				return opts, err
+			}
+			opts.Format = v
+			i++
+		case "--merge":
+			opts.Merge = true
+		case "--force":
+			opts.Force = true
+		case "-n", "--dry-run":
+			opts.DryRun = true
+		default:
+			n, err := parsePRFlag(args, i, &opts.PR)
+			if err != nil {
+				return opts, err
+			}
+			if n == 0 {
+				return opts, fmt.Errorf("unknown argument %q", arg)
+			}
+			i += n - 1
+		}
+	}
+
+	// Pull requests need a pushed branch
+	if opts.PR.Enabled {
+		opts.Push = true
+		opts.GitEnabled = true
+	}
+	if err := opts.PR.validate(); err != nil {
+		return opts, err
+	}
+	if err := (GitTemplates{Branch: opts.BranchName, CommitMessage: opts.CommitMessage}).validate(); err != nil {
+		return opts, err
+	}
+	if opts.GitOnly && opts.Transactional {
+		return opts, errors.New("--git-only cannot be combined with --transactional")
+	}
+
+	if opts.ManifestPath != "" && (opts.Source != "" || len(opts.Targets) > 0) {
+		return opts, errors.New("--manifest cannot be combined with --source/--target")
+	}
+	if len(opts.Groups) > 0 && opts.Source != "" {
+		return opts, errors.New("--group requires a manifest")
+	}
+	if opts.Source == "" && len(opts.Targets) > 0 {
+		return opts, errors.New("--source is required")
+	}
+	if opts.Source != "" && len(opts.Targets) == 0 {
+		return opts, errors.New("at least one --target is required")
+	}
+	if opts.Section != "" && opts.Source == "" {
+		return opts, errors.New("--section requires --source (manifest groups set section instead)")
+	}
+	if opts.InsertMissing && opts.Section == "" {
+		return opts, errors.New("--insert-missing requires --section")
+	}
+	if err := validateKeyFlags(opts.Source, opts.Keys, opts.Format, opts.Section); err != nil {
+		return opts, err
+	}
+	if opts.Force && !opts.Merge && opts.Source != "" {
+		return opts, errors.New("--force requires --merge (manifest groups set merge instead)")
+	}
+
+	return opts, nil
+}
+
+// resolveTargets expands target paths and glob patterns relative to workDir.
+// The source file itself is never returned as a target.
+// Returns absolute paths sorted alphabetically, or an error if a pattern matches nothing.
+func resolveTargets(workDir, source string, patterns []string) ([]string, error) {
+	seen := make(map[string]bool)
+	var targets []string
+
+	for _, pattern := range patterns {
+		absPattern := pattern
+		if !filepath.IsAbs(absPattern) {
+			absPattern = filepath.Join(workDir, pattern)
+		}
+
+		matches, err := filepath.Glob(absPattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid target pattern %q: %w", pattern, err)
+		}
+		// A plain path that does not exist yet is still a valid target
+		if len(matches) == 0 && !strings.ContainsAny(pattern, "*?[") {
+			matches = []string{absPattern}
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("target pattern %q matched no files", pattern)
+		}
+
+		for _, match := range matches {
+			if info, err := os.Stat(match); err == nil && info.IsDir() {
+				continue
+			}
+			if match == source || seen[match] {
+				continue
+			}
+			seen[match] = true
+			targets = append(targets, match)
+		}
+	}
+
+	sort.Strings(targets)
+	return targets, nil
+}
+
+// relativeTo returns path relative to base for display, or path itself if that fails
+func relativeTo(base, path string) string {
+	rel, err := filepath.Rel(base, path)
+	if err != nil {
+		return path
+	}
+	return rel
+}
+
+// runSync runs the headless sync command and returns an exit code
+func runSync(args []string, stdout, stderr io.Writer) int {
+	opts, err := parseSyncArgs(args)
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "Error: %v\n", err)                  //nolint:errcheck // Error writing to stderr is not actionable
+		_, _ = fmt.Fprintln(stderr, "Run 'fmr sync --help' for usage.") //nolint:errcheck // Error writing to stderr is not actionable
+		return 1
+	}
+
+	if opts.ShowHelp {
+		printSyncHelp(stdout)
+		return 0
+	}
+
+	workDir := opts.WorkDir
+	if workDir == "" {
+		workDir = "."
+	}
+	workDir, err = filepath.Abs(workDir)
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "Error: invalid path %q: %v\n", opts.WorkDir, err) //nolint:errcheck // Error writing to stderr is not actionable
+		return 1
+	}
+
+	if opts.Source == "" {
+		return runManifestSync(workDir, opts, stdout, stderr)
+	}
+
+	source := opts.Source
+	if !filepath.IsAbs(source) {
+		source = filepath.Join(workDir, source)
+	}
+	info, err := os.Stat(source)
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "Error: cannot read source file: %v\n", err) //nolint:errcheck // Error writing to stderr is not actionable
+		return 1
+	}
+	if info.IsDir() {
+		_, _ = fmt.Fprintf(stderr, "Error: source %q is a directory\n", opts.Source) //nolint:errcheck // Error writing to stderr is not actionable
+		return 1
+	}
+
+	targets, err := resolveTargets(workDir, source, opts.Targets)
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "Error: %v\n", err) //nolint:errcheck // Error writing to stderr is not actionable
+		return 1
+	}
+	if len(targets) == 0 {
+		_, _ = fmt.Fprintln(stderr, "Error: no targets to sync") //nolint:errcheck // Error writing to stderr is not actionable
+		return 1
+	}
+
+	if opts.Section != "" {
+		opts.Template = newSectionTemplate(source, opts.Section, opts.InsertMissing)
+	}
+	if len(opts.Keys) > 0 {
+		opts.Template = newKeysTemplate(source, opts.Keys, opts.Format)
+	}
+	opts.Template = opts.Template.withMerge(opts.Merge, opts.Force)
+
+	j := newJournal(workDir)
+	if opts.DryRun {
+		j = nil
+	}
+	code := executeSync(workDir, source, targets, opts, j, stdout)
+	printUndoHint(j, stdout)
+	return code
+}
+
+// printUndoHint tells how to undo a run that overwrote or created any targets
+func printUndoHint(j *journal, stdout io.Writer) {
+	if j == nil || j.ID == "" {
+		return
+	}
+	_, _ = fmt.Fprintf(stdout, "\nUndo with: fmr undo %s\n", j.ID) //nolint:errcheck // Error writing to stdout is not actionable
+}
+
+// runManifestSync syncs every selected group of a manifest and returns an exit code
+func runManifestSync(workDir string, opts syncOptions, stdout, stderr io.Writer) int {
+	manifest, plans, err := planManifest(workDir, opts.ManifestPath, opts.Groups)
+	if err != nil {
+		_, _ = fmt.Fprintf(stderr, "Error: %v\n", err) //nolint:errcheck // Error writing to stderr is not actionable
+		return 1
+	}
+
+	j := newJournal(manifest.Dir)
+	if opts.DryRun {
+		j = nil
+	}
+
+	exitCode := 0
+	for i, plan := range plans {
+		if i > 0 {
+			_, _ = fmt.Fprintln(stdout) //nolint:errcheck // Error writing to stdout is not actionable
+		}
+		_, _ = fmt.Fprintf(stdout, "== %s ==\n", plan.Group.Name) //nolint:errcheck // Error writing to stdout is not actionable
+
+		if plan.Err != nil {
+			_, _ = fmt.Fprintf(stdout, "✗ %v\n", plan.Err) //nolint:errcheck // Error writing to stdout is not actionable
+			exitCode = 1
+			continue
+		}
+
+		groupOpts := opts
+		groupOpts.Template = plan.Template.withMerge(opts.Merge, opts.Force)
+		templates := manifest.gitTemplates(plan.Group)
+		if groupOpts.BranchName == "" {
+			groupOpts.BranchName = templates.Branch
+		}
+		if groupOpts.CommitMessage == "" {
+			groupOpts.CommitMessage = templates.CommitMessage
+		}
+
+		if code := executeSync(manifest.Dir, plan.Source, plan.Targets, groupOpts, j, stdout); code != 0 {
+			exitCode = code
+		}
+	}
+	printUndoHint(j, stdout)
+
+	return exitCode
+}
+
+// executeSync copies source into every target and runs the git workflow if enabled.
+// The original targets are recorded in j, if set, before they are overwritten.
+// Prints a per-target report and returns a non-zero exit code if anything failed.
+func executeSync(workDir, source string, targets []string, opts syncOptions, j *journal, stdout io.Writer) int {
+	w := func(format string, a ...any) {
+		_, _ = fmt.Fprintf(stdout, format, a...) //nolint:errcheck // Error writing to stdout is not actionable
+	}
+
+	w("Source: %s\n\n", relativeTo(workDir, source))
+
+	// A dry run reads the source only to render templates
+	var content []byte
+	if !opts.DryRun || opts.Template != nil {
+		var err error
+		if content, err = os.ReadFile(source); err != nil {
+			w("✗ cannot read source: %v\n", err)
+			return 1
+		}
+	}
+
+	if opts.GitOnly && !opts.DryRun {
+		return executeGitOnly(workDir, source, content, targets, opts, w)
+	}
+
+	if opts.Transactional && !opts.DryRun {
+		if !executeTransaction(workDir, source, content, targets, opts.Template, j, w) {
+			return 1
+		}
+		if opts.GitEnabled && !runSyncGitWorkflow(workDir, source, nil, targets, opts, w) {
+			return 1
+		}
+		return 0
+	}
+
+	results := make([]targetResult, 0, len(targets))
+	for _, target := range targets {
+		result := targetResult{Path: target}
+		if opts.DryRun {
+			// Report templates that cannot be rendered for a target without writing anything
+			_, result.Err = opts.Template.render(content, target)
+		} else {
+			result.Err = mirrorTarget(source, content, target, opts.Template, j)
+		}
+		results = append(results, result)
+	}
+
+	failed := 0
+	var synced []string
+	for _, result := range results {
+		rel := relativeTo(workDir, result.Path)
+		switch {
+		case result.Err != nil:
+			failed++
+			w("✗ %s: %v\n", rel, result.Err)
+		case opts.DryRun:
+			synced = append(synced, result.Path)
+			w("• %s (dry run, not written)\n", rel)
+		default:
+			synced = append(synced, result.Path)
+			w("✓ %s\n", rel)
+		}
+	}
+
+	if opts.DryRun {
+		w("\nDry run: %d target(s) would be synced\n", len(synced))
+	} else {
+		w("\nSynced %d of %d target(s)\n", len(synced), len(results))
+	}
+
+	if opts.GitEnabled && len(synced) > 0 {
+		if !runSyncGitWorkflow(workDir, source, nil, synced, opts, w) {
+			return 1
+		}
+	}
+
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// executeTransaction writes content to all targets or none of them and prints the outcome
+// of every target. Returns false if the transaction did not commit.
+func executeTransaction(workDir, source string, content []byte, targets []string, tmpl *sourceTemplate, j *journal, w func(string, ...any)) bool {
+	mode := os.FileMode(0o644)
+	if info, err := os.Stat(source); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	results, err := syncTransaction(source, content, mode, targets, tmpl, j)
+	rollbackFailed := 0
+	for _, result := range results {
+		rel := relativeTo(workDir, result.Path)
+		switch result.Outcome {
+		case txCommitted:
+			if result.Err != nil {
+				w("✓ %s (%v)\n", rel, result.Err)
+			} else {
+				w("✓ %s\n", rel)
+			}
+		case txFailed:
+			w("✗ %s: %v\n", rel, result.Err)
+		case txRollbackFailed:
+			rollbackFailed++
+			w("✗ %s: written, rollback failed: %v\n", rel, result.Err)
+		default:
+			w("↺ %s (%s)\n", rel, result.Outcome)
+		}
+	}
+
+	switch {
+	case err == nil:
+		w("\nSynced %d of %d target(s) in one transaction\n", len(results), len(results))
+		return true
+	case rollbackFailed > 0:
+		w("\nTransaction failed and %d target(s) could not be rolled back\n", rollbackFailed)
+	default:
+		w("\nTransaction failed: no target was changed\n")
+	}
+	return false
+}
+
+// executeGitOnly commits content to every target on the sync branch without writing the
+// working trees. Targets outside a git repository fail. Returns a non-zero exit code if
+// any target or repository failed.
+func executeGitOnly(workDir, source string, content []byte, targets []string, opts syncOptions, w func(string, ...any)) int {
+	failed := 0
+	var inRepo []string
+	for _, target := range targets {
+		rel := relativeTo(workDir, target)
+		if _, err := detectGitRoot(target); err != nil {
+			failed++
+			w("✗ %s: not in a git repository (git-only mode)\n", rel)
+			continue
+		}
+		inRepo = append(inRepo, target)
+		w("• %s (branch only, working tree untouched)\n", rel)
+	}
+
+	if len(inRepo) > 0 && !runSyncGitWorkflow(workDir, source, content, inRepo, opts, w) {
+		return 1
+	}
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// runSyncGitWorkflow commits the synced targets per repository and reports the outcome.
+// With content set, the targets are written only in the worktree of the sync branch.
+// Returns false if the git workflow reported any errors.
+func runSyncGitWorkflow(workDir, source string, content []byte, synced []string, opts syncOptions, w func(string, ...any)) bool {
+	relSource := relativeTo(workDir, source)
+	relTargets := make([]string, 0, len(synced))
+	for _, target := range synced {
+		relTargets = append(relTargets, relativeTo(workDir, target))
+	}
+	data := newGitTemplateData(source, relSource, relTargets, opts.Ticket)
+
+	branchName := defaultBranchName(relSource)
+	if opts.BranchName != "" {
+		var err error
+		if branchName, err = renderBranchName(opts.BranchName, data); err != nil {
+			w("\n✗ Invalid branch name: %v\n", err)
+			return false
+		}
+	}
+
+	commitMsg := defaultCommitMessage(relSource, relTargets)
+	if opts.CommitMessage != "" {
+		var err error
+		if commitMsg, err = renderGitTemplate("commit message", opts.CommitMessage, data); err != nil {
+			w("\n✗ %v\n", err)
+			return false
+		}
+	}
+
+	repos := groupFilesByRepo(synced)
+	if len(repos) == 0 {
+		w("\nGit: no git repositories detected for the synced targets\n")
+		return true
+	}
+
+	w("\nGit Workflow (branch %s):\n", branchName)
+	if opts.DryRun {
+		repoPaths := make([]string, 0, len(repos))
+		for repo := range repos {
+			repoPaths = append(repoPaths, repo)
+		}
+		sort.Strings(repoPaths)
+		for _, repo := range repoPaths {
+			base, err := resolveBaseRef(repo, opts.Base, opts.Fetch)
+			if err != nil {
+				base = opts.Base + " (not found locally)"
+			}
+			w("• %s: would commit %d file(s) on top of %s", repo, len(repos[repo]), base)
+			if opts.Push {
+				w(" and push")
+			}
+			w("\n")
+		}
+		return true
+	}
+
+	lock, err := newLockSource(source)
+	if err != nil {
+		w("✗ %v\n", err)
+		return false
+	}
+
+	jobs := opts.Jobs
+	if jobs == 0 {
+		jobs = defaultGitWorkers
+	}
+	results := runGitWorkflow(repos, gitWorkflowOptions{
+		Branch:   branchName,
+		Message:  commitMsg,
+		Push:     opts.Push,
+		Workers:  jobs,
+		PR:       opts.PR,
+		Base:     opts.Base,
+		Fetch:    opts.Fetch,
+		Content:  content,
+		Template: opts.Template,
+		Lock:     lock,
+	})
+	ok := true
+	for _, res := range results {
+		if res.Success {
+			w("✓ %s\n", res.Repo)
+		}
+		if res.PRURL != "" {
+			w("  Pull request: %s\n", res.PRURL)
+		}
+	}
+	for _, res := range results {
+		if res.Err != nil {
+			ok = false
+			w("✗ %v\n", res.Err)
+		}
+	}
+	return ok
+}
+
+// printSyncHelp displays the help message for the sync command
+func printSyncHelp(w io.Writer) {
+	help := `fmr sync - Copy a source file to targets without the interactive interface
+
+USAGE:
+    fmr sync --source FILE --target FILE|GLOB [--target ...] [OPTIONS]
+    fmr sync [--manifest FILE] [--group NAME ...] [OPTIONS]
+
+OPTIONS:
+    -s, --source FILE      Canonical file to copy from
+    -t, --target PATTERN   Target file or glob pattern (repeatable),
+                           e.g. 'services/*/config.yaml'
+    -p, --path PATH        Resolve relative paths from PATH (default: current directory)
+        --manifest FILE    Sync the mirror groups of a manifest
+                           Default without --source: .fmr.yaml in PATH
+    -g, --group NAME       Only sync the named manifest group (repeatable)
+        --git              Commit the synced files on a new branch per repository,
+                           along with the source's provenance in .fmr.lock (see
+                           'fmr status'); syncs without git record no provenance
+    -b, --branch NAME      Branch name for the commit (implies --git)
+                           Default: chore/filesync-<source name>
+    -m, --message MSG      Commit message (implies --git)
+                           Branch and message are templates with the fields
+                           .SourceName .SourceFile .SourcePath .SourceRepo
+                           .SourceCommit .Date .Targets .Ticket
+        --ticket ID        Ticket ID for the templates, e.g.
+                           --branch 'fix/{{.Ticket}}-{{.SourceName}}' (implies --git)
+        --base REF         Start new branches from REF (implies --git): HEAD for the
+                           current HEAD, or a branch, tag or commit
+                           Default: the local default branch of each repository,
+                           not the branch checked out
+        --fetch            Fetch origin first and start from origin's default branch
+                           (implies --git)
+        --push             Push the branch to origin after committing (implies --git)
+    -j, --jobs N           Repositories to commit and push at the same time (default: 4)
+        --pr               Open a pull request for every pushed branch (implies --push)
+                           The flags below all imply --pr
+        --pr-backend NAME  auto (default), github, gitlab, gh or glab
+                           The github/gitlab backends use GITHUB_TOKEN/GITLAB_TOKEN
+        --pr-title TMPL    Pull request title template (default: {{.Subject}})
+        --pr-body TMPL     Pull request body template; fields: .Repo .Branch .Base
+                           .CommitMessage .Subject .Description .Files
+        --reviewer NAME    Request a review from NAME (repeatable)
+        --label NAME       Add label NAME (repeatable)
+        --draft            Open draft pull requests
+        --transactional    Write all targets or none: stage and verify every target
+                           first, roll back written targets if one fails
+        --git-only         Commit the source on the sync branch only and leave the
+                           working trees and their current branches untouched
+                           (implies --git)
+        --section NAME     Only mirror the region between the "BEGIN fmr:NAME" and
+                           "END fmr:NAME" marker lines, keeping the rest of each target
+        --insert-missing   Append the section to targets without its markers
+                           (default: such targets fail)
+    -k, --key PATH         Only mirror the value at a dotted key path of a JSON, YAML
+                           or TOML file, e.g. compilerOptions (repeatable); the rest of
+                           each target keeps its formatting, comments and key order
+        --format NAME      json, yaml or toml (default: from the source's extension)
+        --merge            Keep local changes to the targets: merge the changes made
+                           to the source since the last sync into each target
+                           (three-way); targets with conflicts, or changed targets
+                           without a known last sync, are not written
+        --force            Write merge conflicts, with conflict markers, and
+                           overwrite changed targets without a known last sync
+    -n, --dry-run          Show what would be synced without writing anything
+    -h, --help             Show this help message
+
+EXIT STATUS:
+    0  All targets synced (and committed, if requested)
+    1  Invalid arguments, or at least one target or repository failed
+
+EXAMPLES:
+    fmr sync --source a/config.yaml --target b/config.yaml
+    fmr sync -s a/config.yaml -t 'services/*/config.yaml' --branch chore/sync-config --push
+    fmr sync -s LICENSE -t '../*/LICENSE' --dry-run
+    fmr sync --manifest .fmr.yaml --group golangci --git
+    fmr sync -s LICENSE -t '../*/LICENSE' --ticket OPS-42 -b '{{.Ticket}}/license-{{.Date}}'
+    fmr sync -s Makefile -t '../*/Makefile' --section shared-lint --insert-missing
+    fmr sync -s tsconfig.json -t 'packages/*/tsconfig.json' --key compilerOptions
+    fmr sync -s .editorconfig -t '../*/.editorconfig' --merge
+`
+	_, _ = fmt.Fprint(w, help) //nolint:errcheck // Error writing to writer is not actionable
+}
@@ -0,0 +1,514 @@
+package filemirror
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseSyncArgs(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        []string
+		want        syncOptions
+		wantErr     bool
+		errContains string
+	}{
+		{
+			name: "source and single target",
+			args: []string{"--source", "a.yaml", "--target", "b.yaml"},
+			want: syncOptions{Source: "a.yaml", Targets: []string{"b.yaml"}},
+		},
+		{
+			name: "short flags and repeated targets",
+			args: []string{"-s", "a.yaml", "-t", "b.yaml", "-t", "services/*/a.yaml", "-p", "/tmp"},
+			want: syncOptions{WorkDir: "/tmp", Source: "a.yaml", Targets: []string{"b.yaml", "services/*/a.yaml"}},
+		},
+		{
+			name: "branch implies git",
+			args: []string{"-s", "a", "-t", "b", "--branch", "chore/x"},
+			want: syncOptions{Source: "a", Targets: []string{"b"}, BranchName: "chore/x", GitEnabled: true},
+		},
+		{
+			name: "push and message imply git",
+			args: []string{"-s", "a", "-t", "b", "--push", "-m", "msg"},
+			want: syncOptions{Source: "a", Targets: []string{"b"}, CommitMessage: "msg", Push: true, GitEnabled: true},
+		},
+		{
+			name: "dry run",
+			args: []string{"-s", "a", "-t", "b", "--dry-run"},
+			want: syncOptions{Source: "a", Targets: []string{"b"}, DryRun: true},
+		},
+		{
+			name: "parallel repositories",
+			args: []string{"-s", "a", "-t", "b", "--git", "--jobs", "8"},
+			want: syncOptions{Source: "a", Targets: []string{"b"}, GitEnabled: true, Jobs: 8},
+		},
+		{
+			name: "transactional",
+			args: []string{"-s", "a", "-t", "b", "--transactional"},
+			want: syncOptions{Source: "a", Targets: []string{"b"}, Transactional: true},
+		},
+		{
+			name: "base and fetch imply git",
+			args: []string{"-s", "a", "-t", "b", "--base", "HEAD", "--fetch"},
+			want: syncOptions{Source: "a", Targets: []string{"b"}, Base: "HEAD", Fetch: true, GitEnabled: true},
+		},
+		{
+			name:        "base without ref",
+			args:        []string{"-s", "a", "-t", "b", "--base"},
+			wantErr:     true,
+			errContains: "--base requires a ref",
+		},
+		{
+			name: "ticket implies git",
+			args: []string{"-s", "a", "-t", "b", "--ticket", "OPS-1", "-b", "fix/{{.Ticket}}"},
+			want: syncOptions{Source: "a", Targets: []string{"b"}, Ticket: "OPS-1", BranchName: "fix/{{.Ticket}}", GitEnabled: true},
+		},
+		{
+			name:        "invalid branch template",
+			args:        []string{"-s", "a", "-t", "b", "-b", "fix/{{.Ticket"},
+			wantErr:     true,
+			errContains: "invalid branch template",
+		},
+		{
+			name: "git only implies git",
+			args: []string{"-s", "a", "-t", "b", "--git-only"},
+			want: syncOptions{Source: "a", Targets: []string{"b"}, GitOnly: true, GitEnabled: true},
+		},
+		{
+			name:        "git only with transactional",
+			args:        []string{"-s", "a", "-t", "b", "--git-only", "--transactional"},
+			wantErr:     true,
+			errContains: "--git-only cannot be combined with --transactional",
+		},
+		{
+			name: "section",
+			args: []string{"-s", "a", "-t", "b", "--section", "shared-lint", "--insert-missing"},
+			want: syncOptions{Source: "a", Targets: []string{"b"}, Section: "shared-lint", InsertMissing: true},
+		},
+		{
+			name:        "section without source",
+			args:        []string{"--section", "shared-lint"},
+			wantErr:     true,
+			errContains: "--section requires --source",
+		},
+		{
+			name:        "insert missing without section",
+			args:        []string{"-s", "a", "-t", "b", "--insert-missing"},
+			wantErr:     true,
+			errContains: "--insert-missing requires --section",
+		},
+		{
+			name:        "invalid section name",
+			args:        []string{"-s", "a", "-t", "b", "--section", "shared lint"},
+			wantErr:     true,
+			errContains: "invalid section name",
+		},
+		{
+			name: "merge",
+			args: []string{"-s", "a", "-t", "b", "--merge", "--force"},
+			want: syncOptions{Source: "a", Targets: []string{"b"}, Merge: true, Force: true},
+		},
+		{
+			name:        "force without merge",
+			args:        []string{"-s", "a", "-t", "b", "--force"},
+			wantErr:     true,
+			errContains: "--force requires --merge",
+		},
+		{
+			name:        "invalid jobs",
+			args:        []string{"-s", "a", "-t", "b", "-j", "many"},
+			wantErr:     true,
+			errContains: "jobs must be a positive number",
+		},
+		{
+			name: "pull request implies push",
+			args: []string{"-s", "a", "-t", "b", "--pr", "--reviewer", "alice", "--reviewer", "bob", "--label", "chore", "--draft"},
+			want: syncOptions{Source: "a", Targets: []string{"b"}, Push: true, GitEnabled: true, PR: PROptions{
+				Enabled:   true,
+				Reviewers: []string{"alice", "bob"},
+				Labels:    []string{"chore"},
+				Draft:     true,
+			}},
+		},
+		{
+			name: "pull request backend and templates",
+			args: []string{"-s", "a", "-t", "b", "--pr-backend", "gitlab", "--pr-title", "{{.Subject}}", "--pr-body", "{{.Description}}"},
+			want: syncOptions{Source: "a", Targets: []string{"b"}, Push: true, GitEnabled: true, PR: PROptions{
+				Enabled:       true,
+				Backend:       "gitlab",
+				TitleTemplate: "{{.Subject}}",
+				BodyTemplate:  "{{.Description}}",
+			}},
+		},
+		{
+			name:        "invalid pull request backend",
+			args:        []string{"-s", "a", "-t", "b", "--pr-backend", "bitbucket"},
+			wantErr:     true,
+			errContains: "unknown pull request backend",
+		},
+		{
+			name:        "invalid pull request template",
+			args:        []string{"-s", "a", "-t", "b", "--pr-title", "{{.Subject"},
+			wantErr:     true,
+			errContains: "pr-title",
+		},
+		{
+			name: "help",
+			args: []string{"--help"},
+			want: syncOptions{ShowHelp: true},
+		},
+		{
+			name: "manifest with groups",
+			args: []string{"--manifest", "m.yaml", "-g", "lint", "--git"},
+			want: syncOptions{ManifestPath: "m.yaml", GitEnabled: true},
+		},
+		{
+			name: "no source uses manifest from path",
+			args: []string{"--dry-run"},
+			want: syncOptions{DryRun: true},
+		},
+		{
+			name:        "manifest combined with source",
+			args:        []string{"--manifest", "m.yaml", "-s", "a"},
+			wantErr:     true,
+			errContains: "cannot be combined",
+		},
+		{
+			name:        "missing source",
+			args:        []string{"-t", "b"},
+			wantErr:     true,
+			errContains: "--source is required",
+		},
+		{
+			name:        "missing target",
+			args:        []string{"-s", "a"},
+			wantErr:     true,
+			errContains: "at least one --target",
+		},
+		{
+			name:        "flag without value",
+			args:        []string{"-s"},
+			wantErr:     true,
+			errContains: "--source requires",
+		},
+		{
+			name:        "unknown argument",
+			args:        []string{"-s", "a", "-t", "b", "--bogus"},
+			wantErr:     true,
+			errContains: "unknown argument",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSyncArgs(tt.args)
+
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+					t.Errorf("Expected error containing %q, got %v", tt.errContains, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if got.WorkDir != tt.want.WorkDir || got.Source != tt.want.Source ||
+				got.BranchName != tt.want.BranchName || got.CommitMessage != tt.want.CommitMessage ||
+				got.GitEnabled != tt.want.GitEnabled || got.Push != tt.want.Push ||
+				got.DryRun != tt.want.DryRun || got.ShowHelp != tt.want.ShowHelp ||
+				got.ManifestPath != tt.want.ManifestPath || got.Jobs != tt.want.Jobs ||
+				got.Transactional != tt.want.Transactional {
+				t.Errorf("parseSyncArgs() = %+v, want %+v", got, tt.want)
+			}
+			if !reflect.DeepEqual(got.PR, tt.want.PR) {
+				t.Errorf("PR = %+v, want %+v", got.PR, tt.want.PR)
+			}
+			if strings.Join(got.Targets, ",") != strings.Join(tt.want.Targets, ",") {
+				t.Errorf("Targets = %v, want %v", got.Targets, tt.want.Targets)
+			}
+		})
+	}
+}
+
+func TestResolveTargets(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	for _, dir := range []string{"api", "web", "docs"} {
+		if err := os.MkdirAll(filepath.Join(tmpDir, "services", dir), 0o755); err != nil {
+			t.Fatalf("Failed to create dir: %v", err)
+		}
+	}
+	source := filepath.Join(tmpDir, "services", "api", "config.yaml")
+	for _, f := range []string{source, filepath.Join(tmpDir, "services", "web", "config.yaml")} {
+		if err := os.WriteFile(f, []byte("x"), 0o644); err != nil {
+			t.Fatalf("Failed to create file: %v", err)
+		}
+	}
+
+	t.Run("glob skips source and directories", func(t *testing.T) {
+		targets, err := resolveTargets(tmpDir, source, []string{"services/*/config.yaml", "services/*"})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		want := []string{filepath.Join(tmpDir, "services", "web", "config.yaml")}
+		if strings.Join(targets, ",") != strings.Join(want, ",") {
+			t.Errorf("resolveTargets() = %v, want %v", targets, want)
+		}
+	})
+
+	t.Run("plain path that does not exist yet", func(t *testing.T) {
+		targets, err := resolveTargets(tmpDir, source, []string{"services/docs/config.yaml"})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(targets) != 1 || targets[0] != filepath.Join(tmpDir, "services", "docs", "config.yaml") {
+			t.Errorf("resolveTargets() = %v", targets)
+		}
+	})
+
+	t.Run("glob without matches", func(t *testing.T) {
+		if _, err := resolveTargets(tmpDir, source, []string{"nothing/*.yaml"}); err == nil {
+			t.Error("Expected error for glob without matches")
+		}
+	})
+}
+
+func TestRunSync(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	source := filepath.Join(tmpDir, "source.txt")
+	if err := os.WriteFile(source, []byte("canonical"), 0o644); err != nil {
+		t.Fatalf("Failed to create source: %v", err)
+	}
+	writeTargets := func() {
+		for _, name := range []string{"a.txt", "b.txt"} {
+			if err := os.WriteFile(filepath.Join(tmpDir, name), []byte("old"), 0o644); err != nil {
+				t.Fatalf("Failed to create target: %v", err)
+			}
+		}
+	}
+
+	t.Run("copies to all targets", func(t *testing.T) {
+		writeTargets()
+		var stdout, stderr bytes.Buffer
+		code := runSync([]string{"-p", tmpDir, "-s", "source.txt", "-t", "a.txt", "-t", "b.txt"}, &stdout, &stderr)
+		if code != 0 {
+			t.Fatalf("Exit code = %d, stderr: %s", code, stderr.String())
+		}
+		for _, name := range []string{"a.txt", "b.txt"} {
+			content, _ := os.ReadFile(filepath.Join(tmpDir, name))
+			if string(content) != "canonical" {
+				t.Errorf("%s content = %q, want %q", name, content, "canonical")
+			}
+			if !strings.Contains(stdout.String(), "✓ "+name) {
+				t.Errorf("Expected report line for %s, got:\n%s", name, stdout.String())
+			}
+		}
+	})
+
+	t.Run("dry run writes nothing", func(t *testing.T) {
+		writeTargets()
+		var stdout, stderr bytes.Buffer
+		code := runSync([]string{"-p", tmpDir, "-s", "source.txt", "-t", "*.txt", "--dry-run"}, &stdout, &stderr)
+		if code != 0 {
+			t.Fatalf("Exit code = %d, stderr: %s", code, stderr.String())
+		}
+		content, _ := os.ReadFile(filepath.Join(tmpDir, "a.txt"))
+		if string(content) != "old" {
+			t.Errorf("Dry run modified target: %q", content)
+		}
+		if !strings.Contains(stdout.String(), "2 target(s) would be synced") {
+			t.Errorf("Expected dry run summary, got:\n%s", stdout.String())
+		}
+	})
+
+	t.Run("failed target gives non-zero exit code", func(t *testing.T) {
+		writeTargets()
+		var stdout, stderr bytes.Buffer
+		code := runSync([]string{"-p", tmpDir, "-s", "source.txt", "-t", "a.txt", "-t", "missing/dir/c.txt"}, &stdout, &stderr)
+		if code != 1 {
+			t.Errorf("Exit code = %d, want 1", code)
+		}
+		if !strings.Contains(stdout.String(), "✗ "+filepath.Join("missing", "dir", "c.txt")) {
+			t.Errorf("Expected failure line in report, got:\n%s", stdout.String())
+		}
+		content, _ := os.ReadFile(filepath.Join(tmpDir, "a.txt"))
+		if string(content) != "canonical" {
+			t.Errorf("Expected remaining targets to be synced, got %q", content)
+		}
+	})
+
+	t.Run("transactional failure changes nothing", func(t *testing.T) {
+		writeTargets()
+		var stdout, stderr bytes.Buffer
+		code := runSync([]string{"-p", tmpDir, "-s", "source.txt", "-t", "a.txt", "-t", "missing/dir/c.txt", "--transactional"}, &stdout, &stderr)
+		if code != 1 {
+			t.Errorf("Exit code = %d, want 1", code)
+		}
+		for _, want := range []string{"↺ a.txt (not written)", "✗ " + filepath.Join("missing", "dir", "c.txt"), "no target was changed"} {
+			if !strings.Contains(stdout.String(), want) {
+				t.Errorf("Expected %q in report, got:\n%s", want, stdout.String())
+			}
+		}
+		content, _ := os.ReadFile(filepath.Join(tmpDir, "a.txt"))
+		if string(content) != "old" {
+			t.Errorf("Expected a.txt to be untouched, got %q", content)
+		}
+	})
+
+	t.Run("transactional sync", func(t *testing.T) {
+		writeTargets()
+		var stdout, stderr bytes.Buffer
+		code := runSync([]string{"-p", tmpDir, "-s", "source.txt", "-t", "a.txt", "-t", "b.txt", "--transactional"}, &stdout, &stderr)
+		if code != 0 || !strings.Contains(stdout.String(), "Synced 2 of 2 target(s) in one transaction") {
+			t.Errorf("Exit code = %d, stdout:\n%s", code, stdout.String())
+		}
+		content, _ := os.ReadFile(filepath.Join(tmpDir, "b.txt"))
+		if string(content) != "canonical" {
+			t.Errorf("b.txt content = %q, want %q", content, "canonical")
+		}
+	})
+
+	t.Run("missing source", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		code := runSync([]string{"-p", tmpDir, "-s", "nope.txt", "-t", "a.txt"}, &stdout, &stderr)
+		if code != 1 || !strings.Contains(stderr.String(), "cannot read source file") {
+			t.Errorf("Exit code = %d, stderr = %q", code, stderr.String())
+		}
+	})
+}
+
+func TestRunSyncWithGit(t *testing.T) {
+	repo := createTestGitRepo(t)
+	defer os.RemoveAll(repo)
+
+	if err := os.WriteFile(filepath.Join(repo, "source.txt"), []byte("canonical"), 0o644); err != nil {
+		t.Fatalf("Failed to create source: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(repo, "target.txt"), []byte("old"), 0o644); err != nil {
+		t.Fatalf("Failed to create target: %v", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	code := runSync([]string{"-p", repo, "-s", "source.txt", "-t", "target.txt", "--branch", "chore/headless-sync"}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("Exit code = %d\nstdout: %s\nstderr: %s", code, stdout.String(), stderr.String())
+	}
+
+	output, err := exec.Command("git", "-C", repo, "show", "chore/headless-sync:target.txt").Output()
+	if err != nil {
+		t.Fatalf("Expected branch with synced file: %v", err)
+	}
+	if string(output) != "canonical" {
+		t.Errorf("Committed content = %q, want %q", output, "canonical")
+	}
+
+	verifyWorktreesCleanedUp(t, repo)
+}
+
+func TestRunSyncGitOnly(t *testing.T) {
+	repo := createTestGitRepo(t)
+	defer os.RemoveAll(repo)
+	commitFiles(t, repo, map[string]string{"target.txt": "old"})
+	plain := t.TempDir()
+
+	source := filepath.Join(plain, "source.txt")
+	for path, content := range map[string]string{
+		source:                            "canonical",
+		filepath.Join(repo, "target.txt"): "local edit",
+		filepath.Join(plain, "plain.txt"): "plain",
+	} {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+
+	var stdout, stderr bytes.Buffer
+	code := runSync([]string{"-s", source, "-t", filepath.Join(repo, "target.txt"), "-t", filepath.Join(plain, "plain.txt"),
+		"--git-only", "--branch", "chore/git-only"}, &stdout, &stderr)
+	if code != 1 {
+		t.Errorf("Exit code = %d, want 1 for the target outside a repository\nstdout: %s", code, stdout.String())
+	}
+	if !strings.Contains(stdout.String(), "not in a git repository (git-only mode)") {
+		t.Errorf("Expected the plain target to fail, got:\n%s", stdout.String())
+	}
+
+	// The branch has the source, the checkout keeps its content and branch
+	output, err := exec.Command("git", "-C", repo, "show", "chore/git-only:target.txt").Output()
+	if err != nil || string(output) != "canonical" {
+		t.Errorf("Committed content = %q, err = %v", output, err)
+	}
+	for path, want := range map[string]string{filepath.Join(repo, "target.txt"): "local edit", filepath.Join(plain, "plain.txt"): "plain"} {
+		if content, _ := os.ReadFile(path); string(content) != want {
+			t.Errorf("%s = %q, want %q", filepath.Base(path), content, want)
+		}
+	}
+	head, _ := exec.Command("git", "-C", repo, "rev-parse", "--abbrev-ref", "HEAD").Output()
+	if strings.TrimSpace(string(head)) != "main" {
+		t.Errorf("Current branch = %q, want main", head)
+	}
+
+	verifyWorktreesCleanedUp(t, repo)
+}
+
+func TestRunWithArgsDispatchesSync(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := RunWithArgs([]string{"sync", "--help"}, &stdout, &stderr)
+	if code != 0 {
+		t.Errorf("Exit code = %d, want 0", code)
+	}
+	if !strings.Contains(stdout.String(), "fmr sync") {
+		t.Errorf("Expected sync help, got %q", stdout.String())
+	}
+}
+
+func TestRunSyncWithManifest(t *testing.T) {
+	tmpDir := writeManifestTree(t, `groups:
+  - name: config
+    source: canonical/config.yaml
+    targets: ["services/*/config.yaml"]
+    exclude: ["services/legacy/*"]
+  - name: broken
+    source: canonical/missing.yaml
+    targets: [services/api/config.yaml]
+`)
+
+	var stdout, stderr bytes.Buffer
+	code := runSync([]string{"-p", tmpDir, "--group", "config"}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("Exit code = %d\nstdout: %s\nstderr: %s", code, stdout.String(), stderr.String())
+	}
+
+	for _, svc := range []string{"api", "web"} {
+		content, _ := os.ReadFile(filepath.Join(tmpDir, "services", svc, "config.yaml"))
+		if string(content) != "content of canonical/config.yaml" {
+			t.Errorf("services/%s not synced: %q", svc, content)
+		}
+	}
+	content, _ := os.ReadFile(filepath.Join(tmpDir, "services", "legacy", "config.yaml"))
+	if string(content) != "content of services/legacy/config.yaml" {
+		t.Errorf("Excluded target was overwritten: %q", content)
+	}
+
+	stdout.Reset()
+	code = runSync([]string{"-p", tmpDir, "--manifest", manifestFileName}, &stdout, &stderr)
+	if code != 1 {
+		t.Errorf("Exit code = %d, want 1 for group with missing source", code)
+	}
+	if !strings.Contains(stdout.String(), "== broken ==") || !strings.Contains(stdout.String(), "cannot read source") {
+		t.Errorf("Expected report for broken group, got:\n%s", stdout.String())
+	}
+
+	stderr.Reset()
+	code = runSync([]string{"-p", t.TempDir()}, &stdout, &stderr)
+	if code != 1 || !strings.Contains(stderr.String(), "--source is required") {
+		t.Errorf("Exit code = %d, stderr = %q", code, stderr.String())
+	}
+}
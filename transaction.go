@@ -0,0 +1,188 @@
+package filemirror
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// txOutcome is what a transactional sync did to one target
+type txOutcome int
+
+const (
+	txCommitted      txOutcome = iota // new content is in place
+	txFailed                          // staging, verifying or renaming this target failed
+	txNotWritten                      // left untouched because another target failed
+	txRolledBack                      // written, then restored after another target failed
+	txRollbackFailed                  // written, and restoring the original failed
+)
+
+func (o txOutcome) String() string {
+	switch o {
+	case txCommitted:
+		return "committed"
+	case txFailed:
+		return "failed"
+	case txNotWritten:
+		return "not written"
+	case txRolledBack:
+		return "rolled back"
+	default:
+		return "rollback failed"
+	}
+}
+
+// txResult is the outcome of a transactional sync for one target
+type txResult struct {
+	Path    string
+	Outcome txOutcome
+	Err     error // set for txFailed and txRollbackFailed, and for txCommitted if the merge base was not recorded
+}
+
+// txTarget is a target staged by a transactional sync
+type txTarget struct {
+	path     string
+	tmpPath  string
+	existed  bool
+	original []byte
+	content  []byte // what is written: the source, rendered for this target if templated
+	base     []byte // the rendered source before merging, recorded as the next merge base
+	mode     os.FileMode
+}
+
+// renameFile moves a staged file into place; replaced in tests to simulate failures
+var renameFile = os.Rename
+
+// syncTransaction writes content, rendered per target if tmpl is set, to every target or
+// to none of them. Every target is first staged as a temp file next to it and verified;
+// only then are the temp files renamed into place in order. If a rename fails, the targets
+// already written get their original content and mode back. The original targets are
+// recorded in j, if set, and for merge syncs the rendered source as the base of the next
+// merge into each.
+// Returns one result per target, in order, and an error if the transaction did not commit.
+func syncTransaction(source string, content []byte, mode os.FileMode, targets []string, tmpl *sourceTemplate, j *journal) ([]txResult, error) {
+	results := make([]txResult, len(targets))
+	for i, target := range targets {
+		results[i] = txResult{Path: target, Outcome: txNotWritten}
+	}
+
+	staged := make([]txTarget, 0, len(targets))
+	defer func() {
+		for _, t := range staged {
+			_ = os.Remove(t.tmpPath) // Best effort cleanup; committed temp files are gone already
+		}
+	}()
+
+	// Stage and verify every target before touching any of them
+	for i, target := range targets {
+		rendered, err := tmpl.renderSource(content, target, target)
+		var merged []byte
+		if err == nil {
+			merged, err = tmpl.mergeBase(rendered, target, target)
+		}
+		if err != nil {
+			results[i] = txResult{Path: target, Outcome: txFailed, Err: err}
+			return results, fmt.Errorf("failed to render %s: %w", target, err)
+		}
+		t, err := stageTarget(target, merged, tmpl.fileMode(target, mode))
+		if err != nil {
+			results[i] = txResult{Path: target, Outcome: txFailed, Err: err}
+			return results, fmt.Errorf("failed to stage %s: %w", target, err)
+		}
+		t.base = rendered
+		staged = append(staged, t)
+	}
+
+	for i, t := range staged {
+		if err := j.backup(t.path, source); err != nil {
+			results[i] = txResult{Path: t.path, Outcome: txFailed, Err: err}
+			return results, err
+		}
+	}
+
+	// Commit in order, rolling back on the first failure
+	for i, t := range staged {
+		if err := renameFile(t.tmpPath, t.path); err != nil {
+			results[i] = txResult{Path: t.path, Outcome: txFailed, Err: fmt.Errorf("failed to rename temp file: %w", err)}
+			for k := i - 1; k >= 0; k-- {
+				results[k] = rollbackTarget(staged[k])
+			}
+			return results, fmt.Errorf("failed to write %s: %w", t.path, err)
+		}
+		results[i].Outcome = txCommitted
+	}
+
+	// Neither a journal nor a base that cannot be recorded undoes the commit
+	for i, t := range staged {
+		results[i].Err = j.written(t.path, t.content)
+		if results[i].Err == nil && tmpl != nil && tmpl.merge {
+			results[i].Err = recordBase(t.path, t.base)
+		}
+	}
+	return results, nil
+}
+
+// stageTarget records the original of target and writes content to a temp file next to it,
+// then reads the temp file back to verify it has the hash of content
+func stageTarget(target string, content []byte, mode os.FileMode) (txTarget, error) {
+	t := txTarget{path: target, content: content}
+
+	info, err := os.Stat(target)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+	case err != nil:
+		return t, err
+	case !info.Mode().IsRegular():
+		return t, errors.New("not a regular file")
+	default:
+		if t.original, err = os.ReadFile(target); err != nil {
+			return t, err
+		}
+		t.existed = true
+		t.mode = info.Mode().Perm()
+	}
+
+	tmpFile, err := os.CreateTemp(filepath.Dir(target), ".fmr-tmp-*")
+	if err != nil {
+		return t, fmt.Errorf("failed to create temp file: %w", err)
+	}
+	t.tmpPath = tmpFile.Name()
+
+	_, err = tmpFile.Write(content)
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(t.tmpPath, mode)
+	}
+	if err != nil {
+		_ = os.Remove(t.tmpPath) // Best effort cleanup, ignore error
+		return t, fmt.Errorf("failed to write temp file: %w", err)
+	}
+
+	written, err := os.ReadFile(t.tmpPath)
+	if err == nil && hashBytes(written) != hashBytes(content) {
+		err = errors.New("content differs from the source")
+	}
+	if err != nil {
+		_ = os.Remove(t.tmpPath) // Best effort cleanup, ignore error
+		return t, fmt.Errorf("failed to verify temp file: %w", err)
+	}
+
+	return t, nil
+}
+
+// rollbackTarget restores a committed target to the state recorded when it was staged
+func rollbackTarget(t txTarget) txResult {
+	var err error
+	if t.existed {
+		err = writeFileAtomic(t.path, t.original, t.mode)
+	} else {
+		err = os.Remove(t.path)
+	}
+	if err != nil {
+		return txResult{Path: t.path, Outcome: txRollbackFailed, Err: err}
+	}
+	return txResult{Path: t.path, Outcome: txRolledBack}
+}
@@ -0,0 +1,138 @@
+package filemirror
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSyncTransaction(t *testing.T) {
+	setup := func(t *testing.T) (dir string, targets []string) {
+		t.Helper()
+		dir = t.TempDir()
+		targets = []string{
+			filepath.Join(dir, "a.txt"),
+			filepath.Join(dir, "b.sh"),
+			filepath.Join(dir, "new.txt"),
+		}
+		if err := os.WriteFile(targets[0], []byte("old a"), 0o644); err != nil {
+			t.Fatalf("Failed to write target: %v", err)
+		}
+		if err := os.WriteFile(targets[1], []byte("old b"), 0o755); err != nil {
+			t.Fatalf("Failed to write target: %v", err)
+		}
+		return dir, targets
+	}
+	// outcomes lists the outcome of every target, e.g. "committed,failed"
+	outcomes := func(results []txResult) string {
+		names := make([]string, len(results))
+		for i, result := range results {
+			names[i] = result.Outcome.String()
+		}
+		return strings.Join(names, ",")
+	}
+	assertContent := func(t *testing.T, path, want string, wantMode os.FileMode) {
+		t.Helper()
+		content, err := os.ReadFile(path)
+		if want == "" {
+			if !os.IsNotExist(err) {
+				t.Errorf("%s exists, want it missing", filepath.Base(path))
+			}
+			return
+		}
+		if string(content) != want {
+			t.Errorf("%s = %q, want %q", filepath.Base(path), content, want)
+		}
+		if info, err := os.Stat(path); err == nil && info.Mode().Perm() != wantMode {
+			t.Errorf("%s mode = %v, want %v", filepath.Base(path), info.Mode().Perm(), wantMode)
+		}
+	}
+	assertNoTempFiles := func(t *testing.T, dir string) {
+		t.Helper()
+		matches, _ := filepath.Glob(filepath.Join(dir, ".fmr-tmp-*"))
+		if len(matches) > 0 {
+			t.Errorf("Temp files left behind: %v", matches)
+		}
+	}
+
+	t.Run("commits every target", func(t *testing.T) {
+		dir, targets := setup(t)
+
+		results, err := syncTransaction("source", []byte("new"), 0o600, targets, nil, nil)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if got := outcomes(results); got != "committed,committed,committed" {
+			t.Errorf("Outcomes = %s, want all committed", got)
+		}
+		for _, target := range targets {
+			assertContent(t, target, "new", 0o600)
+		}
+		assertNoTempFiles(t, dir)
+	})
+
+	t.Run("staging failure changes nothing", func(t *testing.T) {
+		dir, targets := setup(t)
+		targets = append(targets[:2], filepath.Join(dir, "missing", "c.txt"))
+
+		results, err := syncTransaction("source", []byte("new"), 0o644, targets, nil, nil)
+		if err == nil {
+			t.Fatal("Expected error for a target in a missing directory")
+		}
+		if got := outcomes(results); got != "not written,not written,failed" {
+			t.Errorf("Outcomes = %s", got)
+		}
+		assertContent(t, targets[0], "old a", 0o644)
+		assertContent(t, targets[1], "old b", 0o755)
+		assertNoTempFiles(t, dir)
+	})
+
+	t.Run("rename failure rolls back written targets", func(t *testing.T) {
+		dir, targets := setup(t)
+
+		// The last target cannot be renamed into place
+		origRename := renameFile
+		defer func() { renameFile = origRename }()
+		renameFile = func(oldPath, newPath string) error {
+			if newPath == targets[2] {
+				return errors.New("disk full")
+			}
+			return os.Rename(oldPath, newPath)
+		}
+
+		results, err := syncTransaction("source", []byte("new"), 0o644, targets, nil, nil)
+		if err == nil || !strings.Contains(err.Error(), "disk full") {
+			t.Fatalf("err = %v, want rename failure", err)
+		}
+		if got := outcomes(results); got != "rolled back,rolled back,failed" {
+			t.Errorf("Outcomes = %s", got)
+		}
+		assertContent(t, targets[0], "old a", 0o644)
+		assertContent(t, targets[1], "old b", 0o755)
+		assertContent(t, targets[2], "", 0)
+		assertNoTempFiles(t, dir)
+	})
+
+	t.Run("rolled back new targets are removed", func(t *testing.T) {
+		dir, targets := setup(t)
+		targets = []string{targets[2], targets[0]}
+
+		origRename := renameFile
+		defer func() { renameFile = origRename }()
+		renameFile = func(oldPath, newPath string) error {
+			if newPath == targets[1] {
+				return errors.New("permission denied")
+			}
+			return os.Rename(oldPath, newPath)
+		}
+
+		if _, err := syncTransaction("source", []byte("new"), 0o644, targets, nil, nil); err == nil {
+			t.Fatal("Expected error")
+		}
+		assertContent(t, targets[0], "", 0)
+		assertContent(t, targets[1], "old a", 0o644)
+		assertNoTempFiles(t, dir)
+	})
+}